@@ -0,0 +1,83 @@
+// Copyright 2011 Aaron Jacobs. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crc
+
+// Predefined CRC algorithms, named after their entries in the catalogue.
+var (
+	CRC5_USB = Params{Name: "CRC-5/USB", Width: 5, Poly: 0x05, Init: 0x1f, RefIn: true, RefOut: true, XorOut: 0x1f, Check: 0x19}
+	CRC7_MMC = Params{Name: "CRC-7/MMC", Width: 7, Poly: 0x09, Check: 0x75}
+
+	CRC8_SMBUS     = Params{Name: "CRC-8/SMBUS", Aliases: []string{"CRC-8"}, Width: 8, Poly: 0x07, Check: 0xf4}
+	CRC8_MAXIM_DOW = Params{Name: "CRC-8/MAXIM-DOW", Aliases: []string{"CRC-8/MAXIM", "DOW-CRC"}, Width: 8, Poly: 0x31, RefIn: true, RefOut: true, Check: 0xa1}
+	CRC8_AUTOSAR   = Params{Name: "CRC-8/AUTOSAR", Width: 8, Poly: 0x2f, Init: 0xff, XorOut: 0xff, Check: 0xdf}
+	CRC8_BLUETOOTH = Params{Name: "CRC-8/BLUETOOTH", Width: 8, Poly: 0xa7, RefIn: true, RefOut: true, Check: 0x26}
+	CRC8_CDMA2000  = Params{Name: "CRC-8/CDMA2000", Width: 8, Poly: 0x9b, Init: 0xff, Check: 0xda}
+	CRC8_I_432_1   = Params{Name: "CRC-8/I-432-1", Aliases: []string{"CRC-8/ITU"}, Width: 8, Poly: 0x07, XorOut: 0x55, Check: 0xa1}
+	CRC8_SAE_J1850 = Params{Name: "CRC-8/SAE-J1850", Width: 8, Poly: 0x1d, Init: 0xff, XorOut: 0xff, Check: 0x4b}
+
+	CRC16_ARC         = Params{Name: "CRC-16/ARC", Aliases: []string{"CRC-16", "CRC-16/LHA"}, Width: 16, Poly: 0x8005, RefIn: true, RefOut: true, Check: 0xbb3d}
+	CRC16_MODBUS      = Params{Name: "CRC-16/MODBUS", Width: 16, Poly: 0x8005, Init: 0xffff, RefIn: true, RefOut: true, Check: 0x4b37}
+	CRC16_USB         = Params{Name: "CRC-16/USB", Width: 16, Poly: 0x8005, Init: 0xffff, RefIn: true, RefOut: true, XorOut: 0xffff, Check: 0xb4c8}
+	CRC16_MAXIM_DOW   = Params{Name: "CRC-16/MAXIM-DOW", Aliases: []string{"CRC-16/MAXIM"}, Width: 16, Poly: 0x8005, RefIn: true, RefOut: true, XorOut: 0xffff, Check: 0x44c2}
+	CRC16_KERMIT      = Params{Name: "CRC-16/KERMIT", Aliases: []string{"CRC-16/CCITT", "CRC-16/CCITT-TRUE"}, Width: 16, Poly: 0x1021, RefIn: true, RefOut: true, Check: 0x2189}
+	CRC16_IBM_3740    = Params{Name: "CRC-16/IBM-3740", Aliases: []string{"CRC-16/CCITT-FALSE", "CRC-16/AUTOSAR"}, Width: 16, Poly: 0x1021, Init: 0xffff, Check: 0x29b1}
+	CRC16_XMODEM      = Params{Name: "CRC-16/XMODEM", Aliases: []string{"CRC-16/ACORN", "CRC-16/LTE"}, Width: 16, Poly: 0x1021, Check: 0x31c3}
+	CRC16_IBM_SDLC    = Params{Name: "CRC-16/IBM-SDLC", Aliases: []string{"CRC-16/X-25", "CRC-16/ISO-HDLC", "CRC-16/HDLC"}, Width: 16, Poly: 0x1021, Init: 0xffff, RefIn: true, RefOut: true, XorOut: 0xffff, Check: 0x906e}
+	CRC16_GENIBUS     = Params{Name: "CRC-16/GENIBUS", Aliases: []string{"CRC-16/EPC", "CRC-16/DARC"}, Width: 16, Poly: 0x1021, Init: 0xffff, XorOut: 0xffff, Check: 0xd64e}
+	CRC16_SPI_FUJITSU = Params{Name: "CRC-16/SPI-FUJITSU", Aliases: []string{"CRC-16/AUG-CCITT"}, Width: 16, Poly: 0x1021, Init: 0x1d0f, Check: 0xe5cc}
+	CRC16_DNP         = Params{Name: "CRC-16/DNP", Width: 16, Poly: 0x3d65, RefIn: true, RefOut: true, XorOut: 0xffff, Check: 0xea82}
+
+	CRC32_ISO_HDLC = Params{Name: "CRC-32/ISO-HDLC", Aliases: []string{"CRC-32", "CRC-32/ADCCP"}, Width: 32, Poly: 0x04c11db7, Init: 0xffffffff, RefIn: true, RefOut: true, XorOut: 0xffffffff, Check: 0xcbf43926}
+	CRC32_ISCSI    = Params{Name: "CRC-32/ISCSI", Aliases: []string{"CRC-32C", "CRC-32/CASTAGNOLI"}, Width: 32, Poly: 0x1edc6f41, Init: 0xffffffff, RefIn: true, RefOut: true, XorOut: 0xffffffff, Check: 0xe3069283}
+	CRC32_BZIP2    = Params{Name: "CRC-32/BZIP2", Aliases: []string{"CRC-32/AAL5"}, Width: 32, Poly: 0x04c11db7, Init: 0xffffffff, XorOut: 0xffffffff, Check: 0xfc891918}
+	CRC32_MPEG_2   = Params{Name: "CRC-32/MPEG-2", Width: 32, Poly: 0x04c11db7, Init: 0xffffffff, Check: 0x0376e6e7}
+	CRC32_CKSUM    = Params{Name: "CRC-32/CKSUM", Aliases: []string{"CRC-32/POSIX"}, Width: 32, Poly: 0x04c11db7, XorOut: 0xffffffff, Check: 0x765e7680}
+	CRC32_JAMCRC   = Params{Name: "CRC-32/JAMCRC", Width: 32, Poly: 0x04c11db7, Init: 0xffffffff, RefIn: true, RefOut: true, Check: 0x340bc6d9}
+
+	CRC64_XZ       = Params{Name: "CRC-64/XZ", Aliases: []string{"CRC-64/GO-ECMA"}, Width: 64, Poly: 0x42f0e1eba9ea3693, Init: 0xffffffffffffffff, RefIn: true, RefOut: true, XorOut: 0xffffffffffffffff, Check: 0x995dc9bbdf1939fa}
+	CRC64_ECMA_182 = Params{Name: "CRC-64/ECMA-182", Width: 64, Poly: 0x42f0e1eba9ea3693, Check: 0x6c40df5f0b497347}
+)
+
+// Catalogue lists the predefined algorithms, for looking them up by name.
+var Catalogue = []Params{
+	CRC5_USB,
+	CRC7_MMC,
+	CRC8_SMBUS,
+	CRC8_MAXIM_DOW,
+	CRC8_AUTOSAR,
+	CRC8_BLUETOOTH,
+	CRC8_CDMA2000,
+	CRC8_I_432_1,
+	CRC8_SAE_J1850,
+	CRC16_ARC,
+	CRC16_MODBUS,
+	CRC16_USB,
+	CRC16_MAXIM_DOW,
+	CRC16_KERMIT,
+	CRC16_IBM_3740,
+	CRC16_XMODEM,
+	CRC16_IBM_SDLC,
+	CRC16_GENIBUS,
+	CRC16_SPI_FUJITSU,
+	CRC16_DNP,
+	CRC32_ISO_HDLC,
+	CRC32_ISCSI,
+	CRC32_BZIP2,
+	CRC32_MPEG_2,
+	CRC32_CKSUM,
+	CRC32_JAMCRC,
+	CRC64_XZ,
+	CRC64_ECMA_182,
+}
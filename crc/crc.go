@@ -0,0 +1,224 @@
+// Copyright 2011 Aaron Jacobs. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package crc computes the CRCs and checksums used by serial protocols.
+//
+// CRCs are described by their parameters in the model used by the Catalogue
+// of parametrised CRC algorithms, from which the predefined algorithms and
+// their check values are taken:
+//
+//	https://reveng.sourceforge.io/crc-catalogue/
+//
+// Any CRC of width 1 to 64 can be computed by making a table from its
+// parameters:
+//
+//	table := crc.MakeTable(crc.CRC16_MODBUS)
+//	sum := table.Checksum(frame)
+package crc
+
+import (
+	"hash"
+	"strings"
+)
+
+// Params describes a CRC algorithm.
+type Params struct {
+	// The name of the algorithm in the catalogue, e.g. "CRC-16/MODBUS", and
+	// any other names it is known by.
+	Name    string
+	Aliases []string
+
+	// The width of the CRC in bits, from 1 to 64.
+	Width uint
+
+	// The generator polynomial, without its top bit.
+	Poly uint64
+
+	// The initial value of the register.
+	Init uint64
+
+	// Whether each input byte is reflected, i.e. processed least significant
+	// bit first.
+	RefIn bool
+
+	// Whether the final register is reflected.
+	RefOut bool
+
+	// The value XORed with the final register.
+	XorOut uint64
+
+	// The CRC of the ASCII string "123456789".
+	Check uint64
+}
+
+// Lookup returns the predefined algorithm with the given name or alias,
+// ignoring case.
+func Lookup(name string) (Params, bool) {
+	for _, p := range Catalogue {
+		if strings.EqualFold(p.Name, name) {
+			return p, true
+		}
+
+		for _, alias := range p.Aliases {
+			if strings.EqualFold(alias, name) {
+				return p, true
+			}
+		}
+	}
+
+	return Params{}, false
+}
+
+// Table computes a CRC using a precomputed table. It is safe for concurrent
+// use.
+type Table struct {
+	params Params
+	mask   uint64
+
+	// For reflected input the register holds the reflected CRC in its low
+	// bits; otherwise it holds the CRC in its top bits, so that widths below
+	// eight need no special treatment.
+	table [256]uint64
+}
+
+// MakeTable returns a table for the CRC described by p.
+func MakeTable(p Params) *Table {
+	t := &Table{params: p, mask: ^uint64(0) >> (64 - p.Width)}
+
+	if p.RefIn {
+		poly := reflect(p.Poly, p.Width)
+		for i := range t.table {
+			crc := uint64(i)
+			for j := 0; j < 8; j++ {
+				if crc&1 != 0 {
+					crc = crc>>1 ^ poly
+				} else {
+					crc >>= 1
+				}
+			}
+
+			t.table[i] = crc
+		}
+	} else {
+		poly := p.Poly << (64 - p.Width)
+		for i := range t.table {
+			crc := uint64(i) << 56
+			for j := 0; j < 8; j++ {
+				if crc&(1<<63) != 0 {
+					crc = crc<<1 ^ poly
+				} else {
+					crc <<= 1
+				}
+			}
+
+			t.table[i] = crc
+		}
+	}
+
+	return t
+}
+
+// Params returns the parameters the table was made from.
+func (t *Table) Params() Params {
+	return t.params
+}
+
+// Size returns the size of the CRC in bytes.
+func (t *Table) Size() int {
+	return int(t.params.Width+7) / 8
+}
+
+// Checksum returns the CRC of data.
+func (t *Table) Checksum(data []byte) uint64 {
+	return t.complete(t.update(t.init(), data))
+}
+
+// init returns the initial register value.
+func (t *Table) init() uint64 {
+	if t.params.RefIn {
+		return reflect(t.params.Init&t.mask, t.params.Width)
+	}
+
+	return t.params.Init << (64 - t.params.Width)
+}
+
+func (t *Table) update(crc uint64, data []byte) uint64 {
+	if t.params.RefIn {
+		for _, b := range data {
+			crc = t.table[byte(crc)^b] ^ crc>>8
+		}
+	} else {
+		for _, b := range data {
+			crc = t.table[byte(crc>>56)^b] ^ crc<<8
+		}
+	}
+
+	return crc
+}
+
+// complete returns the CRC given the final register value.
+func (t *Table) complete(crc uint64) uint64 {
+	if !t.params.RefIn {
+		crc >>= 64 - t.params.Width
+	}
+
+	if t.params.RefIn != t.params.RefOut {
+		crc = reflect(crc, t.params.Width)
+	}
+
+	return (crc ^ t.params.XorOut) & t.mask
+}
+
+// reflect reverses the order of the low width bits of v.
+func reflect(v uint64, width uint) uint64 {
+	var r uint64
+	for i := uint(0); i < width; i++ {
+		r = r<<1 | v&1
+		v >>= 1
+	}
+
+	return r
+}
+
+// New returns a hash.Hash64 computing the CRC. Its Sum method appends the
+// CRC in Size bytes, most significant byte first.
+func New(t *Table) hash.Hash64 {
+	d := &digest{t: t}
+	d.Reset()
+	return d
+}
+
+type digest struct {
+	t   *Table
+	crc uint64
+}
+
+func (d *digest) Size() int      { return d.t.Size() }
+func (d *digest) BlockSize() int { return 1 }
+func (d *digest) Reset()         { d.crc = d.t.init() }
+func (d *digest) Sum64() uint64  { return d.t.complete(d.crc) }
+
+func (d *digest) Write(p []byte) (int, error) {
+	d.crc = d.t.update(d.crc, p)
+	return len(p), nil
+}
+
+func (d *digest) Sum(b []byte) []byte {
+	sum := d.Sum64()
+	for i := d.Size() - 1; i >= 0; i-- {
+		b = append(b, byte(sum>>(8*i)))
+	}
+
+	return b
+}
@@ -0,0 +1,108 @@
+package crc
+
+import (
+	"bytes"
+	"hash/crc32"
+	"hash/crc64"
+	"testing"
+)
+
+var checkInput = []byte("123456789")
+
+func TestCatalogue(t *testing.T) {
+	for _, p := range Catalogue {
+		if got := MakeTable(p).Checksum(checkInput); got != p.Check {
+			t.Errorf("%s: expected check value %#x, got %#x", p.Name, p.Check, got)
+		}
+	}
+}
+
+func TestStandardLibrary(t *testing.T) {
+	data := []byte("The quick brown fox jumps over the lazy dog")
+
+	if got, expected := MakeTable(CRC32_ISO_HDLC).Checksum(data), crc32.ChecksumIEEE(data); got != uint64(expected) {
+		t.Errorf("CRC-32: expected %#x, got %#x", expected, got)
+	}
+
+	castagnoli := crc32.MakeTable(crc32.Castagnoli)
+	if got, expected := MakeTable(CRC32_ISCSI).Checksum(data), crc32.Checksum(data, castagnoli); got != uint64(expected) {
+		t.Errorf("CRC-32C: expected %#x, got %#x", expected, got)
+	}
+
+	ecma := crc64.MakeTable(crc64.ECMA)
+	if got, expected := MakeTable(CRC64_XZ).Checksum(data), crc64.Checksum(data, ecma); got != expected {
+		t.Errorf("CRC-64/XZ: expected %#x, got %#x", expected, got)
+	}
+}
+
+func TestMixedReflection(t *testing.T) {
+	// Algorithms reflecting only their input or output are rare, but the
+	// catalogue has one: CRC-12/UMTS.
+	p := Params{Width: 12, Poly: 0x80f, RefOut: true}
+	if got := MakeTable(p).Checksum(checkInput); got != 0xdaf {
+		t.Errorf("expected %#x, got %#x", 0xdaf, got)
+	}
+}
+
+func TestHash(t *testing.T) {
+	table := MakeTable(CRC16_MODBUS)
+	h := New(table)
+
+	h.Write(checkInput[:4])
+	h.Write(checkInput[4:])
+	if got := h.Sum64(); got != CRC16_MODBUS.Check {
+		t.Errorf("expected %#x, got %#x", CRC16_MODBUS.Check, got)
+	}
+
+	if got := h.Sum([]byte{0xaa}); !bytes.Equal(got, []byte{0xaa, 0x4b, 0x37}) {
+		t.Errorf("unexpected Sum: % x", got)
+	}
+
+	h.Reset()
+	h.Write([]byte{0x01, 0x03, 0x00, 0x00, 0x00, 0x01})
+	if got := h.Sum64(); got != 0x0a84 {
+		t.Errorf("expected %#x, got %#x", 0x0a84, got)
+	}
+
+	if h.Size() != 2 || New(MakeTable(CRC5_USB)).Size() != 1 || New(MakeTable(CRC64_XZ)).Size() != 8 {
+		t.Errorf("unexpected sizes")
+	}
+}
+
+func TestLookup(t *testing.T) {
+	testCases := []struct {
+		Name     string
+		Expected string
+	}{
+		{"CRC-16/MODBUS", "CRC-16/MODBUS"},
+		{"crc-16/ccitt-false", "CRC-16/IBM-3740"},
+		{"CRC-32C", "CRC-32/ISCSI"},
+		{"CRC-16/UNKNOWN", ""},
+	}
+
+	for _, testCase := range testCases {
+		p, ok := Lookup(testCase.Name)
+		if ok != (testCase.Expected != "") || p.Name != testCase.Expected {
+			t.Errorf("%s: expected %q, got %q, %v", testCase.Name, testCase.Expected, p.Name, ok)
+		}
+	}
+}
+
+func TestChecksums(t *testing.T) {
+	if got := XOR(checkInput); got != 0x31 {
+		t.Errorf("XOR: expected 0x31, got %#x", got)
+	}
+
+	if got := Sum8(checkInput); got != 0xdd {
+		t.Errorf("Sum8: expected 0xdd, got %#x", got)
+	}
+
+	if got := LRC(checkInput); got != 0x23 {
+		t.Errorf("LRC: expected 0x23, got %#x", got)
+	}
+
+	// Modbus ASCII example: read 10 holding registers from slave 17.
+	if got := LRC([]byte{0x11, 0x03, 0x00, 0x6b, 0x00, 0x03}); got != 0x7e {
+		t.Errorf("LRC: expected 0x7e, got %#x", got)
+	}
+}
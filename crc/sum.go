@@ -0,0 +1,42 @@
+// Copyright 2011 Aaron Jacobs. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crc
+
+// XOR returns the XOR of the bytes of data, as used by NMEA 0183 and many
+// simple binary protocols.
+func XOR(data []byte) byte {
+	var sum byte
+	for _, b := range data {
+		sum ^= b
+	}
+
+	return sum
+}
+
+// Sum8 returns the sum of the bytes of data modulo 256.
+func Sum8(data []byte) byte {
+	var sum byte
+	for _, b := range data {
+		sum += b
+	}
+
+	return sum
+}
+
+// LRC returns the longitudinal redundancy check used by Modbus ASCII: the
+// two's complement of Sum8, so that the sum of data and its LRC is zero.
+func LRC(data []byte) byte {
+	return -Sum8(data)
+}
@@ -0,0 +1,209 @@
+// Copyright 2011 Aaron Jacobs. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package framing
+
+import "io"
+
+// COBSOptions configures a COBS framer.
+type COBSOptions struct {
+	// Use COBS/R, which often saves the final byte of the encoding, rather
+	// than plain COBS.
+	Reduced bool
+
+	// An optional check value following the payload, covering the payload
+	// before encoding.
+	Check *Check
+
+	// The longest payload accepted, after decoding. Defaults to 4096.
+	MaxLength int
+}
+
+// COBS reads and writes frames encoded with Consistent Overhead Byte
+// Stuffing, which removes every zero byte from the payload at the cost of
+// one byte in 254, so that frames can be delimited by zeros. Each frame is
+// followed by a zero.
+//
+// Frames are decoded as they arrive. Empty frames, as produced by repeated
+// zeros, are skipped. Frames that are malformed, longer than MaxLength or
+// fail the check are dropped, and the framer resynchronizes at the next zero.
+//
+// With the Reduced option the framer uses COBS/R, in which the final length
+// code is replaced by the final byte of the payload when that is larger.
+// A COBS/R decoder accepts plain COBS frames too, but not the reverse.
+type COBS struct {
+	counters
+
+	rw      io.ReadWriter
+	options COBSOptions
+	in      buffer
+
+	// The frame being received, decoded, and the number of bytes received for
+	// it so far.
+	frame []byte
+	size  int
+
+	// The length code of the current block, and the number of bytes of it
+	// still to come.
+	code      byte
+	remaining int
+
+	// Whether the frame being received has exceeded MaxLength and is being
+	// skipped.
+	tooLong bool
+}
+
+var _ Framer = &COBS{}
+
+// NewCOBS returns a COBS framer for the port.
+func NewCOBS(rw io.ReadWriter, options COBSOptions) *COBS {
+	if options.MaxLength <= 0 {
+		options.MaxLength = kDefaultMaxLength
+	}
+
+	return &COBS{
+		rw:      rw,
+		options: options,
+		in:      buffer{r: rw},
+	}
+}
+
+func (f *COBS) ReadFrame() ([]byte, error) {
+	for {
+		for i, c := range f.in.buf {
+			if payload, ok := f.decode(c); ok {
+				f.in.consume(i + 1)
+				return payload, nil
+			}
+		}
+
+		f.in.consume(len(f.in.buf))
+		if err := f.in.fill(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// decode processes the next byte received, returning the payload if it
+// completes a valid frame.
+func (f *COBS) decode(c byte) ([]byte, bool) {
+	if c == 0 {
+		return f.endFrame()
+	}
+
+	f.size++
+	if f.remaining > 0 {
+		f.appendByte(c)
+		f.remaining--
+		return nil, false
+	}
+
+	// Each block but the last, unless it is of the maximum length, stands for
+	// its data followed by a zero.
+	if f.size > 1 && f.code < 0xff {
+		f.appendByte(0)
+	}
+
+	f.code = c
+	f.remaining = int(c) - 1
+	return nil, false
+}
+
+// endFrame ends the frame being received at a zero.
+func (f *COBS) endFrame() ([]byte, bool) {
+	size := f.size + 1
+	short := f.remaining > 0
+	defer func() {
+		f.frame = f.frame[:0]
+		f.size = 0
+		f.remaining = 0
+		f.tooLong = false
+	}()
+
+	if size == 1 {
+		return nil, false
+	}
+
+	// A final block shorter than its length code is malformed, except in
+	// COBS/R, where the code is the final byte.
+	if short {
+		if !f.options.Reduced {
+			f.bad(size)
+			return nil, false
+		}
+
+		f.appendByte(f.code)
+	}
+
+	if f.tooLong || !f.options.Check.valid(f.frame, 0) {
+		f.bad(size)
+		return nil, false
+	}
+
+	f.frames.Add(1)
+	payload := f.frame[:len(f.frame)-f.options.Check.size()]
+	return append([]byte(nil), payload...), true
+}
+
+func (f *COBS) appendByte(c byte) {
+	if len(f.frame) < f.options.MaxLength+f.options.Check.size() {
+		f.frame = append(f.frame, c)
+	} else {
+		f.tooLong = true
+	}
+}
+
+func (f *COBS) WriteFrame(payload []byte) error {
+	if len(payload) > f.options.MaxLength {
+		return ErrFrameSize
+	}
+
+	data := f.options.Check.appendSum(payload[:len(payload):len(payload)], payload)
+	frame := appendCOBS(make([]byte, 0, len(data)+len(data)/254+2), data, f.options.Reduced)
+	frame = append(frame, 0)
+	return write(f.rw, frame)
+}
+
+// appendCOBS appends the COBS or COBS/R encoding of data to dst.
+func appendCOBS(dst, data []byte, reduced bool) []byte {
+	codeAt := len(dst)
+	dst = append(dst, 0)
+	code := byte(1)
+
+	for i, c := range data {
+		if c != 0 {
+			dst = append(dst, c)
+			code++
+		}
+
+		// A zero ends a block, as does reaching the maximum length, unless
+		// that is the end of the data.
+		if c == 0 || code == 0xff && i < len(data)-1 {
+			dst[codeAt] = code
+			codeAt = len(dst)
+			dst = append(dst, 0)
+			code = 1
+		}
+	}
+
+	dst[codeAt] = code
+
+	// In COBS/R, a final byte larger than the final length code replaces it.
+	if last := dst[len(dst)-1]; reduced && code > 1 && last > code {
+		dst[codeAt] = last
+		dst = dst[:len(dst)-1]
+	}
+
+	return dst
+}
@@ -0,0 +1,87 @@
+// Copyright 2011 Aaron Jacobs. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package framing
+
+import (
+	"fmt"
+	"io"
+)
+
+// FixedSizeOptions configures a FixedSize framer.
+type FixedSizeOptions struct {
+	// Optional bytes that begin every record, such as a sync word.
+	Preamble []byte
+
+	// The size of the payload of every record. Required; if it is not
+	// positive, ReadFrame and WriteFrame return ErrInvalidOptions.
+	Size int
+
+	// An optional check value following the payload, covering the payload.
+	Check *Check
+}
+
+// FixedSize reads and writes records made up of an optional preamble, a
+// payload of a fixed size and an optional check value.
+//
+// A record whose check value is wrong is assumed to be out of step: the
+// framer skips a single byte and looks for a record starting at the next one
+// (or at the next preamble, if there is one). Without a preamble or check
+// value the framer cannot resynchronize, and relies on the first byte
+// received being the start of a record.
+type FixedSize struct {
+	counters
+	scanner
+
+	w       io.Writer
+	options FixedSizeOptions
+}
+
+var _ Framer = &FixedSize{}
+
+// NewFixedSize returns a FixedSize framer for the port.
+func NewFixedSize(rw io.ReadWriter, options FixedSizeOptions) *FixedSize {
+	if options.Size <= 0 {
+		err := fmt.Errorf("record size %d not positive: %w", options.Size, ErrInvalidOptions)
+		return &FixedSize{scanner: scanner{invalid: err}}
+	}
+
+	f := &FixedSize{w: rw, options: options}
+	f.scanner = scanner{
+		in:       buffer{r: rw},
+		counters: &f.counters,
+		preamble: options.Preamble,
+		check:    options.Check,
+		length: func([]byte) (int, bool) {
+			return options.Size, true
+		},
+	}
+
+	return f
+}
+
+func (f *FixedSize) WriteFrame(payload []byte) error {
+	if f.invalid != nil {
+		return f.invalid
+	}
+
+	if len(payload) != f.options.Size {
+		return ErrFrameSize
+	}
+
+	frame := append([]byte(nil), f.options.Preamble...)
+	frame = append(frame, payload...)
+	frame = f.options.Check.appendSum(frame, payload)
+	return write(f.w, frame)
+}
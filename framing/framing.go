@@ -0,0 +1,227 @@
+// Copyright 2011 Aaron Jacobs. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package framing splits the byte stream of a serial port into frames, for
+// protocols that send their messages as packets rather than lines.
+//
+// Each Framer reads from and writes to a port, usually one returned by
+// serial.Open. The built-in framers cover the common ways of marking where a
+// frame begins and ends:
+//
+//   - STXETX, for frames between STX and ETX bytes with DLE stuffing.
+//   - LengthPrefixed, for frames that begin with their length.
+//   - FixedSize, for records of a known size.
+//   - IdleGap, for frames separated by a pause in transmission.
+//   - SLIP, for the packets of RFC 1055.
+//   - COBS, for frames delimited by zeros, with COBS or COBS/R encoding.
+//   - HDLC, for the asynchronous HDLC-like framing of PPP.
+//
+// HDLC frames always end with a frame check sequence, and the other framers
+// except SLIP can validate a check value, such as a CRC, carried at the end
+// of each frame. Frames that fail validation and any garbage between frames
+// are skipped, and the framer resynchronizes on the next frame.
+//
+// When the port's Read returns no data and a nil error, as ports opened with
+// serial.OpenOptions.ReadTimeout do when it expires, ReadFrame returns
+// ErrTimeout. Any partial frame is kept, so that ReadFrame may be called
+// again to wait for the rest of it. Ports opened with the older
+// InterCharacterTimeout are not suitable: on Linux a read that times out
+// with it returns io.EOF, which ReadFrame returns as an error.
+package framing
+
+import (
+	"errors"
+	"io"
+	"sync/atomic"
+
+	"github.com/jacobsa/go-serial/crc"
+)
+
+var (
+	// ErrTimeout is returned by ReadFrame when a read from the port returns
+	// no data before a whole frame has arrived.
+	ErrTimeout = errors.New("timed out waiting for a frame")
+
+	// ErrFrameSize is returned by WriteFrame when the payload cannot be sent
+	// in a frame of the configured format.
+	ErrFrameSize = errors.New("payload size not supported by frame format")
+
+	// ErrInvalidOptions is returned by every ReadFrame and WriteFrame call on
+	// a framer created with options that cannot describe a frame format, such
+	// as a FixedSize framer without a size.
+	ErrInvalidOptions = errors.New("invalid framer options")
+)
+
+// Framer reads and writes whole frames. A Framer may be used by one reader
+// and one writer concurrently.
+type Framer interface {
+	// ReadFrame returns the payload of the next valid frame, without any
+	// delimiters, header or check value.
+	ReadFrame() ([]byte, error)
+
+	// WriteFrame encodes the payload as a frame and writes it to the port in
+	// a single call to Write.
+	WriteFrame(payload []byte) error
+}
+
+// Check describes a check value, such as a CRC, carried at the end of each
+// frame. Which bytes it covers is documented by each framer.
+type Check struct {
+	// The size of the check value in bytes, from 1 to 8.
+	Size int
+
+	// Sum computes the check value of the data.
+	Sum func(data []byte) uint64
+
+	// Whether the check value is sent least significant byte first, as in
+	// Modbus, rather than most significant byte first.
+	LittleEndian bool
+}
+
+// CRC returns a Check for the CRC computed by the table, sent least
+// significant byte first if littleEndian is set. For example, Modbus RTU
+// frames end with
+//
+//	framing.CRC(crc.MakeTable(crc.CRC16_MODBUS), true)
+func CRC(table *crc.Table, littleEndian bool) *Check {
+	return &Check{
+		Size:         table.Size(),
+		Sum:          table.Checksum,
+		LittleEndian: littleEndian,
+	}
+}
+
+func (c *Check) size() int {
+	if c == nil {
+		return 0
+	}
+
+	return c.Size
+}
+
+// appendSum appends the check value of data to b.
+func (c *Check) appendSum(b []byte, data []byte) []byte {
+	if c == nil {
+		return b
+	}
+
+	return putUint(b, c.Sum(data), c.Size, c.LittleEndian)
+}
+
+// valid reports whether frame ends with the correct check value for the
+// bytes before it. The data covered begins skip bytes into the frame.
+func (c *Check) valid(frame []byte, skip int) bool {
+	if c == nil {
+		return true
+	}
+
+	if len(frame)-skip < c.Size {
+		return false
+	}
+
+	data := frame[skip : len(frame)-c.Size]
+	expected := c.appendSum(nil, data)
+	return string(expected) == string(frame[len(frame)-c.Size:])
+}
+
+// Stats counts what a framer has received.
+type Stats struct {
+	// Valid frames returned by ReadFrame.
+	Frames uint64
+
+	// Frames dropped because they failed the check, were too long or were
+	// otherwise malformed.
+	BadFrames uint64
+
+	// Bytes skipped while looking for the start of a frame, including those
+	// of bad frames.
+	DiscardedBytes uint64
+}
+
+// counters are embedded by the framers to count what they receive.
+type counters struct {
+	frames         atomic.Uint64
+	badFrames      atomic.Uint64
+	discardedBytes atomic.Uint64
+}
+
+// Stats returns the counts of frames and bytes received so far. It may be
+// called concurrently with ReadFrame.
+func (c *counters) Stats() Stats {
+	return Stats{
+		Frames:         c.frames.Load(),
+		BadFrames:      c.badFrames.Load(),
+		DiscardedBytes: c.discardedBytes.Load(),
+	}
+}
+
+// bad records a frame of n bytes that was dropped.
+func (c *counters) bad(n int) {
+	c.badFrames.Add(1)
+	c.discardedBytes.Add(uint64(n))
+}
+
+// buffer holds data read from the port that has not yet been consumed.
+type buffer struct {
+	r   io.Reader
+	buf []byte
+
+	// An error returned along with data, to be returned by the next fill.
+	err error
+}
+
+// fill reads more data from the port, returning ErrTimeout if the read
+// returns none.
+func (b *buffer) fill() error {
+	if err := b.err; err != nil {
+		b.err = nil
+		return err
+	}
+
+	if cap(b.buf)-len(b.buf) < 256 {
+		grown := make([]byte, len(b.buf), 2*cap(b.buf)+256)
+		copy(grown, b.buf)
+		b.buf = grown
+	}
+
+	n, err := b.r.Read(b.buf[len(b.buf):cap(b.buf)])
+	b.buf = b.buf[:len(b.buf)+n]
+
+	switch {
+	case n > 0:
+		b.err = err
+		return nil
+
+	case err != nil:
+		return err
+
+	default:
+		return ErrTimeout
+	}
+}
+
+// consume removes n bytes from the front of the buffer.
+func (b *buffer) consume(n int) {
+	b.buf = b.buf[:copy(b.buf, b.buf[n:])]
+}
+
+// write writes a whole frame to w.
+func write(w io.Writer, frame []byte) error {
+	n, err := w.Write(frame)
+	if err == nil && n < len(frame) {
+		err = io.ErrShortWrite
+	}
+
+	return err
+}
@@ -0,0 +1,599 @@
+package framing
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/jacobsa/go-serial/crc"
+	"github.com/jacobsa/go-serial/serial"
+)
+
+var crc32Check = CRC(crc.MakeTable(crc.CRC32_ISO_HDLC), false)
+
+var sum8Check = &Check{
+	Size: 1,
+	Sum:  func(b []byte) uint64 { return uint64(crc.Sum8(b)) },
+}
+
+// chunkedPort returns its chunks from successive reads, with an empty chunk
+// standing for a read that times out, then io.EOF. Writes are recorded.
+type chunkedPort struct {
+	chunks  [][]byte
+	written bytes.Buffer
+}
+
+func (c *chunkedPort) Read(b []byte) (int, error) {
+	if len(c.chunks) == 0 {
+		return 0, io.EOF
+	}
+
+	n := copy(b, c.chunks[0])
+	c.chunks[0] = c.chunks[0][n:]
+	if len(c.chunks[0]) == 0 {
+		c.chunks = c.chunks[1:]
+	}
+
+	return n, nil
+}
+
+func (c *chunkedPort) Write(b []byte) (int, error) {
+	return c.written.Write(b)
+}
+
+// observedPort calls observe before each read.
+type observedPort struct {
+	io.ReadWriter
+	observe func()
+}
+
+func (p *observedPort) Read(b []byte) (int, error) {
+	p.observe()
+	return p.ReadWriter.Read(b)
+}
+
+// encode returns the frames written by the framer for the payloads.
+func encode(t *testing.T, newFramer func(io.ReadWriter) Framer, payloads ...string) []byte {
+	port := &chunkedPort{}
+	f := newFramer(port)
+	for _, p := range payloads {
+		if err := f.WriteFrame([]byte(p)); err != nil {
+			t.Fatalf("WriteFrame(%q): %v", p, err)
+		}
+	}
+
+	return port.written.Bytes()
+}
+
+// readAll returns the payloads of all frames read until an error other than
+// ErrTimeout.
+func readAll(f Framer) ([]string, error) {
+	var payloads []string
+	for {
+		payload, err := f.ReadFrame()
+		if err == ErrTimeout {
+			continue
+		}
+
+		if err != nil {
+			return payloads, err
+		}
+
+		payloads = append(payloads, string(payload))
+	}
+}
+
+func join(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+func checkFrames(t *testing.T, name string, f interface {
+	Framer
+	Stats() Stats
+}, expected []string, stats Stats) {
+	payloads, err := readAll(f)
+	if err != io.EOF {
+		t.Errorf("%s: expected EOF, got %v", name, err)
+	}
+
+	if len(payloads) != len(expected) {
+		t.Errorf("%s: expected %q, got %q", name, expected, payloads)
+	} else {
+		for i := range payloads {
+			if payloads[i] != expected[i] {
+				t.Errorf("%s: expected %q, got %q", name, expected, payloads)
+				break
+			}
+		}
+	}
+
+	if got := f.Stats(); got != stats {
+		t.Errorf("%s: expected stats %+v, got %+v", name, stats, got)
+	}
+}
+
+func TestSTXETXEncoding(t *testing.T) {
+	testCases := []struct {
+		Options  STXETXOptions
+		Payload  string
+		Expected []byte
+	}{
+		{STXETXOptions{}, "", []byte{STX, ETX}},
+		{STXETXOptions{}, "ab", []byte{STX, 'a', 'b', ETX}},
+		{STXETXOptions{}, "\x02\x03\x10x", []byte{STX, DLE, STX, DLE, ETX, DLE, DLE, 'x', ETX}},
+		{STXETXOptions{Check: sum8Check}, "\x01\x02", []byte{STX, 0x01, DLE, STX, DLE, ETX, ETX}},
+	}
+
+	for _, testCase := range testCases {
+		got := encode(t, func(rw io.ReadWriter) Framer { return NewSTXETX(rw, testCase.Options) }, testCase.Payload)
+		if !bytes.Equal(got, testCase.Expected) {
+			t.Errorf("%q: expected % x, got % x", testCase.Payload, testCase.Expected, got)
+		}
+	}
+}
+
+func TestSTXETX(t *testing.T) {
+	newFramer := func(rw io.ReadWriter) Framer {
+		return NewSTXETX(rw, STXETXOptions{Check: crc32Check, MaxLength: 8})
+	}
+
+	good := encode(t, newFramer, "one", "\x02\x10\x03")
+	bad := encode(t, newFramer, "two")
+	bad[2] ^= 0xff
+
+	// Garbage, an abandoned frame, a frame with a bad check, a frame that is
+	// too long and the good frames, split across reads.
+	input := join([]byte("junk"), []byte{STX, 'x', 'y'}, bad, []byte("\x02far too long a frame\x03"), good)
+	port := &chunkedPort{}
+	for i := 0; i < len(input); i += 3 {
+		port.chunks = append(port.chunks, input[i:min(i+3, len(input))], nil)
+	}
+
+	checkFrames(t, "STXETX", NewSTXETX(port, STXETXOptions{Check: crc32Check, MaxLength: 8}),
+		[]string{"one", "\x02\x10\x03"},
+		Stats{Frames: 2, BadFrames: 3, DiscardedBytes: 4 + 3 + uint64(len(bad)) + 22})
+
+	if err := NewSTXETX(port, STXETXOptions{MaxLength: 2}).WriteFrame([]byte("abc")); err != ErrFrameSize {
+		t.Errorf("expected ErrFrameSize, got %v", err)
+	}
+}
+
+func TestLengthPrefixedEncoding(t *testing.T) {
+	testCases := []struct {
+		Options  LengthPrefixedOptions
+		Expected []byte
+	}{
+		{LengthPrefixedOptions{}, []byte{0x00, 0x02, 'h', 'i'}},
+		{LengthPrefixedOptions{HeaderSize: 1}, []byte{0x02, 'h', 'i'}},
+		{LengthPrefixedOptions{HeaderSize: 4, LittleEndian: true}, []byte{0x02, 0x00, 0x00, 0x00, 'h', 'i'}},
+		{
+			LengthPrefixedOptions{Preamble: []byte{0xaa, 0x55}, HeaderSize: 1, Check: sum8Check},
+			[]byte{0xaa, 0x55, 0x02, 'h', 'i', 0x02 + 'h' + 'i'},
+		},
+	}
+
+	for _, testCase := range testCases {
+		got := encode(t, func(rw io.ReadWriter) Framer { return NewLengthPrefixed(rw, testCase.Options) }, "hi")
+		if !bytes.Equal(got, testCase.Expected) {
+			t.Errorf("%+v: expected % x, got % x", testCase.Options, testCase.Expected, got)
+		}
+	}
+
+	f := NewLengthPrefixed(&chunkedPort{}, LengthPrefixedOptions{HeaderSize: 1})
+	if err := f.WriteFrame(make([]byte, 256)); err != ErrFrameSize {
+		t.Errorf("expected ErrFrameSize, got %v", err)
+	}
+}
+
+func TestLengthPrefixed(t *testing.T) {
+	options := LengthPrefixedOptions{Check: crc32Check, MaxLength: 100}
+	newFramer := func(rw io.ReadWriter) Framer { return NewLengthPrefixed(rw, options) }
+	good := encode(t, newFramer, "one", "", "three")
+	bad := encode(t, newFramer, "two")
+	bad[3] ^= 0xff
+
+	// A header claiming too much data, and one whose check fails, are each
+	// skipped a byte at a time until the next frame.
+	port := &chunkedPort{chunks: [][]byte{{0xff, 0xff}, bad, good[:4], nil, good[4:]}}
+	checkFrames(t, "LengthPrefixed", NewLengthPrefixed(port, options),
+		[]string{"one", "", "three"},
+		Stats{Frames: 3, BadFrames: uint64(2 + len(bad)), DiscardedBytes: uint64(2 + len(bad))})
+}
+
+func TestLengthPrefixedPreamble(t *testing.T) {
+	options := LengthPrefixedOptions{Preamble: []byte("SYNC"), HeaderSize: 1, LittleEndian: true}
+	good := encode(t, func(rw io.ReadWriter) Framer { return NewLengthPrefixed(rw, options) }, "one", "two")
+
+	port := &chunkedPort{chunks: [][]byte{[]byte("garbageSY"), good[:6], good[6:]}}
+	checkFrames(t, "preamble", NewLengthPrefixed(port, options),
+		[]string{"one", "two"},
+		Stats{Frames: 2, DiscardedBytes: 7 + 2})
+}
+
+func TestFixedSize(t *testing.T) {
+	options := FixedSizeOptions{Size: 3, Check: sum8Check}
+	newFramer := func(rw io.ReadWriter) Framer { return NewFixedSize(rw, options) }
+	good := encode(t, newFramer, "abc", "def")
+
+	if !bytes.Equal(good[:4], []byte{'a', 'b', 'c', ('a' + 'b' + 'c') & 0xff}) {
+		t.Errorf("unexpected encoding: % x", good)
+	}
+
+	// A stray byte throws the records out of step until the check passes.
+	port := &chunkedPort{chunks: [][]byte{{0x00}, good}}
+	checkFrames(t, "FixedSize", NewFixedSize(port, options),
+		[]string{"abc", "def"},
+		Stats{Frames: 2, BadFrames: 1, DiscardedBytes: 1})
+
+	if err := NewFixedSize(port, options).WriteFrame([]byte("ab")); err != ErrFrameSize {
+		t.Errorf("expected ErrFrameSize, got %v", err)
+	}
+}
+
+func TestInvalidOptions(t *testing.T) {
+	testCases := []struct {
+		Name   string
+		Framer Framer
+	}{
+		{"negative header", NewLengthPrefixed(&chunkedPort{}, LengthPrefixedOptions{HeaderSize: -1})},
+		{"large header", NewLengthPrefixed(&chunkedPort{}, LengthPrefixedOptions{HeaderSize: 9})},
+		{"zero size", NewFixedSize(&chunkedPort{}, FixedSizeOptions{})},
+		{"negative size", NewFixedSize(&chunkedPort{}, FixedSizeOptions{Size: -1})},
+	}
+
+	for _, testCase := range testCases {
+		if _, err := testCase.Framer.ReadFrame(); !errors.Is(err, ErrInvalidOptions) {
+			t.Errorf("%s: ReadFrame: expected ErrInvalidOptions, got %v", testCase.Name, err)
+		}
+
+		if err := testCase.Framer.WriteFrame([]byte("x")); !errors.Is(err, ErrInvalidOptions) {
+			t.Errorf("%s: WriteFrame: expected ErrInvalidOptions, got %v", testCase.Name, err)
+		}
+	}
+}
+
+func TestTimeoutKeepsPartialFrame(t *testing.T) {
+	port := &chunkedPort{chunks: [][]byte{{STX, 'a'}, nil, {'b', ETX}}}
+	f := NewSTXETX(port, STXETXOptions{})
+
+	if _, err := f.ReadFrame(); err != ErrTimeout {
+		t.Fatalf("expected ErrTimeout, got %v", err)
+	}
+
+	if payload, err := f.ReadFrame(); string(payload) != "ab" || err != nil {
+		t.Errorf("expected %q, nil; got %q, %v", "ab", payload, err)
+	}
+}
+
+func TestIdleGap(t *testing.T) {
+	port, err := serial.OpenPort(serial.OpenOptions{
+		PortName:    "loop://",
+		BaudRate:    9600,
+		DataBits:    8,
+		StopBits:    1,
+		ReadTimeout: 5 * time.Millisecond,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer port.Close()
+
+	f := NewIdleGap(port, IdleGapOptions{Gap: 50 * time.Millisecond, Check: sum8Check})
+
+	// Data written in pieces less than the gap apart makes up one frame.
+	go func() {
+		f.WriteFrame([]byte("one"))
+		time.Sleep(100 * time.Millisecond)
+		port.Write([]byte("tw"))
+		time.Sleep(10 * time.Millisecond)
+		port.Write([]byte{'o', ('t' + 'w' + 'o') & 0xff})
+		time.Sleep(100 * time.Millisecond)
+		port.Write([]byte("bad"))
+		time.Sleep(100 * time.Millisecond)
+		f.WriteFrame([]byte("three"))
+	}()
+
+	var payloads []string
+	deadline := time.Now().Add(5 * time.Second)
+	for len(payloads) < 3 && time.Now().Before(deadline) {
+		payload, err := f.ReadFrame()
+		if err == ErrTimeout {
+			continue
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		payloads = append(payloads, string(payload))
+	}
+
+	expected := []string{"one", "two", "three"}
+	if len(payloads) != 3 || payloads[0] != expected[0] || payloads[1] != expected[1] || payloads[2] != expected[2] {
+		t.Errorf("expected %q, got %q", expected, payloads)
+	}
+
+	if stats := f.Stats(); stats != (Stats{Frames: 3, BadFrames: 1, DiscardedBytes: 3}) {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestIdleGapEOF(t *testing.T) {
+	// The last frame ends when the port does.
+	port := &chunkedPort{chunks: [][]byte{[]byte("ab"), []byte("c")}}
+	checkFrames(t, "IdleGap", NewIdleGap(port, IdleGapOptions{Gap: time.Hour}), []string{"abc"}, Stats{Frames: 1})
+
+	// A frame that is too long is dropped at the next gap, without being kept
+	// in full.
+	port = &chunkedPort{}
+	for i := 0; i < 100; i++ {
+		port.chunks = append(port.chunks, bytes.Repeat([]byte{'x'}, 100))
+	}
+
+	port.chunks = append(port.chunks, nil, []byte("ok"))
+	var f *IdleGap
+	kept := 0
+	observed := &observedPort{port, func() { kept = max(kept, len(f.frame)) }}
+	f = NewIdleGap(observed, IdleGapOptions{MaxLength: 10})
+	checkFrames(t, "too long", f, []string{"ok"}, Stats{Frames: 1, BadFrames: 1, DiscardedBytes: 10000})
+	if kept > 10 {
+		t.Errorf("kept %d bytes of a frame that is too long", kept)
+	}
+
+	// With no gap, every timed-out read ends a frame.
+	port = &chunkedPort{chunks: [][]byte{[]byte("ab"), []byte("c"), nil, []byte("d")}}
+	checkFrames(t, "zero gap", NewIdleGap(port, IdleGapOptions{}), []string{"abc", "d"}, Stats{Frames: 2})
+}
+
+func TestSLIPEncoding(t *testing.T) {
+	testCases := []struct {
+		Options  SLIPOptions
+		Payload  string
+		Expected []byte
+	}{
+		{SLIPOptions{}, "ab", []byte{'a', 'b', SLIP_END}},
+		{SLIPOptions{LeadingEND: true}, "ab", []byte{SLIP_END, 'a', 'b', SLIP_END}},
+		{SLIPOptions{}, "\xc0\xdbx", []byte{SLIP_ESC, SLIP_ESC_END, SLIP_ESC, SLIP_ESC_ESC, 'x', SLIP_END}},
+	}
+
+	for _, testCase := range testCases {
+		got := encode(t, func(rw io.ReadWriter) Framer { return NewSLIP(rw, testCase.Options) }, testCase.Payload)
+		if !bytes.Equal(got, testCase.Expected) {
+			t.Errorf("%q: expected % x, got % x", testCase.Payload, testCase.Expected, got)
+		}
+	}
+
+	if err := NewSLIP(&chunkedPort{}, SLIPOptions{MaxLength: 2}).WritePacket([]byte("abc")); err != ErrFrameSize {
+		t.Errorf("expected ErrFrameSize, got %v", err)
+	}
+}
+
+func TestSLIP(t *testing.T) {
+	newFramer := func(rw io.ReadWriter) Framer { return NewSLIP(rw, SLIPOptions{LeadingEND: true}) }
+	good := encode(t, newFramer, "one", "\xc0\xdb", "three")
+
+	// Line noise before the first leading END, a packet that is too long and
+	// an ESC followed by an ordinary byte, with escape sequences split across
+	// reads.
+	input := join([]byte("noise"), []byte("far too long\xc0"), []byte{'x', SLIP_ESC, 'y', SLIP_END}, good)
+	port := &chunkedPort{}
+	for i := 0; i < len(input); i++ {
+		port.chunks = append(port.chunks, input[i:i+1], nil)
+	}
+
+	f := NewSLIP(port, SLIPOptions{MaxLength: 8})
+	checkFrames(t, "SLIP", f,
+		[]string{"xy", "one", "\xc0\xdb", "three"},
+		Stats{Frames: 4, BadFrames: 1, DiscardedBytes: 18})
+}
+
+func TestSLIPReadPacket(t *testing.T) {
+	// An ESC followed by END is not an empty packet.
+	port := &chunkedPort{chunks: [][]byte{{SLIP_END, SLIP_ESC, SLIP_END, 'a', SLIP_ESC}, nil, {SLIP_ESC_END, SLIP_END}}}
+	f := NewSLIP(port, SLIPOptions{})
+
+	if _, err := f.ReadPacket(); err != ErrTimeout {
+		t.Fatalf("expected ErrTimeout, got %v", err)
+	}
+
+	if packet, err := f.ReadPacket(); string(packet) != "a\xc0" || err != nil {
+		t.Errorf("expected %q, nil; got %q, %v", "a\xc0", packet, err)
+	}
+
+	if stats := f.Stats(); stats != (Stats{Frames: 1, BadFrames: 1, DiscardedBytes: 2}) {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestCOBSEncoding(t *testing.T) {
+	var seq254, seq255, seqFF []byte
+	for i := 1; i <= 0xff; i++ {
+		seqFF = append(seqFF, byte(i))
+	}
+
+	seq254 = seqFF[:254]
+	seq255 = append([]byte{0}, seq254...)
+
+	testCases := []struct {
+		Payload  []byte
+		Expected []byte
+		Reduced  []byte
+	}{
+		{nil, []byte{0x01, 0x00}, nil},
+		{[]byte{0x00}, []byte{0x01, 0x01, 0x00}, nil},
+		{[]byte{0x00, 0x00}, []byte{0x01, 0x01, 0x01, 0x00}, nil},
+		{[]byte{0x00, 0x11, 0x00}, []byte{0x01, 0x02, 0x11, 0x01, 0x00}, nil},
+		{[]byte{0x11, 0x22, 0x00, 0x33}, []byte{0x03, 0x11, 0x22, 0x02, 0x33, 0x00}, []byte{0x03, 0x11, 0x22, 0x33, 0x00}},
+		{[]byte{0x11, 0x22, 0x33, 0x44}, []byte{0x05, 0x11, 0x22, 0x33, 0x44, 0x00}, []byte{0x44, 0x11, 0x22, 0x33, 0x00}},
+		{[]byte{0x11, 0x00, 0x00, 0x00}, []byte{0x02, 0x11, 0x01, 0x01, 0x01, 0x00}, nil},
+		{[]byte{0x01, 0x02}, []byte{0x03, 0x01, 0x02, 0x00}, nil},
+		{seq254, join([]byte{0xff}, seq254, []byte{0x00}), nil},
+		{seq255, join([]byte{0x01, 0xff}, seq254, []byte{0x00}), nil},
+		{seqFF, join([]byte{0xff}, seq254, []byte{0x02, 0xff, 0x00}), join([]byte{0xff}, seq254, []byte{0xff, 0x00})},
+	}
+
+	for _, testCase := range testCases {
+		if testCase.Reduced == nil {
+			testCase.Reduced = testCase.Expected
+		}
+
+		for _, reduced := range []bool{false, true} {
+			expected := testCase.Expected
+			if reduced {
+				expected = testCase.Reduced
+			}
+
+			got := encode(t, func(rw io.ReadWriter) Framer { return NewCOBS(rw, COBSOptions{Reduced: reduced}) }, string(testCase.Payload))
+			if !bytes.Equal(got, expected) {
+				t.Errorf("% x, reduced %v: expected % x, got % x", testCase.Payload, reduced, expected, got)
+			}
+
+			port := &chunkedPort{chunks: [][]byte{got}}
+			checkFrames(t, "COBS", NewCOBS(port, COBSOptions{Reduced: reduced}), []string{string(testCase.Payload)}, Stats{Frames: 1})
+		}
+	}
+}
+
+func TestCOBS(t *testing.T) {
+	options := COBSOptions{Check: crc32Check, MaxLength: 8}
+	newFramer := func(rw io.ReadWriter) Framer { return NewCOBS(rw, options) }
+	good := encode(t, newFramer, "one", "\x00\x00", "")
+	bad := encode(t, newFramer, "two")
+	bad[1] ^= 0xff
+
+	// A truncated block, a frame with a bad check and a frame that is too
+	// long, with repeated zeros between frames, split across reads.
+	tooLong := encode(t, func(rw io.ReadWriter) Framer { return NewCOBS(rw, COBSOptions{Check: crc32Check}) }, "far too long")
+	input := join([]byte{0x00, 0x05, 'a', 0x00, 0x00}, bad, tooLong, []byte{0x00}, good)
+	port := &chunkedPort{}
+	for i := 0; i < len(input); i += 2 {
+		port.chunks = append(port.chunks, input[i:min(i+2, len(input))], nil)
+	}
+
+	checkFrames(t, "COBS", NewCOBS(port, options),
+		[]string{"one", "\x00\x00", ""},
+		Stats{Frames: 3, BadFrames: 3, DiscardedBytes: 3 + uint64(len(bad)+len(tooLong))})
+
+	if err := NewCOBS(port, COBSOptions{MaxLength: 2}).WriteFrame([]byte("abc")); err != ErrFrameSize {
+		t.Errorf("expected ErrFrameSize, got %v", err)
+	}
+}
+
+func TestCOBSReduced(t *testing.T) {
+	// A COBS/R decoder accepts plain COBS, but a plain decoder rejects the
+	// reduced final block.
+	port := &chunkedPort{chunks: [][]byte{{0x05, 0x11, 0x22, 0x33, 0x44, 0x00, 0x44, 0x11, 0x22, 0x33, 0x00}}}
+	checkFrames(t, "reduced", NewCOBS(port, COBSOptions{Reduced: true}),
+		[]string{"\x11\x22\x33\x44", "\x11\x22\x33\x44"},
+		Stats{Frames: 2})
+
+	port = &chunkedPort{chunks: [][]byte{{0x44, 0x11, 0x22, 0x33, 0x00}}}
+	checkFrames(t, "plain", NewCOBS(port, COBSOptions{}), nil, Stats{BadFrames: 1, DiscardedBytes: 5})
+}
+
+func FuzzCOBS(f *testing.F) {
+	f.Add([]byte{}, false)
+	f.Add([]byte{0x00}, true)
+	f.Add([]byte{0x11, 0x22, 0x00, 0x33}, true)
+	f.Add(bytes.Repeat([]byte{0xff}, 600), false)
+
+	f.Fuzz(func(t *testing.T, payload []byte, reduced bool) {
+		options := COBSOptions{Reduced: reduced, MaxLength: len(payload)}
+		encoded := encode(t, func(rw io.ReadWriter) Framer { return NewCOBS(rw, options) }, string(payload))
+		if i := bytes.IndexByte(encoded, 0); i != len(encoded)-1 {
+			t.Fatalf("% x: zero at %d in % x", payload, i, encoded)
+		}
+
+		// Round trip, a byte at a time.
+		port := &chunkedPort{}
+		for i := range encoded {
+			port.chunks = append(port.chunks, encoded[i:i+1])
+		}
+
+		got, err := NewCOBS(port, options).ReadFrame()
+		if err != nil || !bytes.Equal(got, payload) {
+			t.Fatalf("% x: got % x, %v", payload, got, err)
+		}
+
+		// Decoding arbitrary input must not fail other than at its end.
+		port = &chunkedPort{chunks: [][]byte{payload}}
+		if _, err := readAll(NewCOBS(port, COBSOptions{Reduced: reduced, MaxLength: 16})); err != io.EOF {
+			t.Fatalf("% x: expected EOF, got %v", payload, err)
+		}
+	})
+}
+
+func TestHDLCEncoding(t *testing.T) {
+	// An LCP Configure-Request, as sent before the ACCM is negotiated.
+	lcp := []byte{0xff, 0x03, 0xc0, 0x21, 0x01, 0x01, 0x00, 0x04}
+
+	testCases := []struct {
+		Options  HDLCOptions
+		Frame    []byte
+		Expected []byte
+	}{
+		{HDLCOptions{NoACCM: true}, []byte("a\x7eb\x7dc\x01"), []byte{HDLC_FLAG, 'a', 0x7d, 0x5e, 'b', 0x7d, 0x5d, 'c', 0x01}},
+		{HDLCOptions{}, []byte{0x11, 0x13}, []byte{HDLC_FLAG, 0x7d, 0x31, 0x7d, 0x33}},
+		{HDLCOptions{}, lcp, []byte{HDLC_FLAG, 0xff, 0x7d, 0x23, 0xc0, 0x21, 0x7d, 0x21, 0x7d, 0x21, 0x7d, 0x20, 0x7d, 0x24}},
+		{HDLCOptions{ACCM: 1 << 0x11}, []byte{0x11, 0x13}, []byte{HDLC_FLAG, 0x7d, 0x31, 0x13}},
+	}
+
+	for _, testCase := range testCases {
+		got := encode(t, func(rw io.ReadWriter) Framer { return NewHDLC(rw, testCase.Options) }, string(testCase.Frame))
+		if !bytes.HasPrefix(got, testCase.Expected) {
+			t.Errorf("% x: expected % x..., got % x", testCase.Frame, testCase.Expected, got)
+		}
+	}
+
+	// Run over the frame and its FCS, the CRC leaves a fixed residue.
+	for _, testCase := range []struct {
+		Options HDLCOptions
+		Params  crc.Params
+		Residue uint64
+	}{
+		{HDLCOptions{NoACCM: true}, crc.CRC16_IBM_SDLC, 0xf0b8 ^ 0xffff},
+		{HDLCOptions{FCS: FCS_32, NoACCM: true}, crc.CRC32_ISO_HDLC, 0xdebb20e3 ^ 0xffffffff},
+	} {
+		got := encode(t, func(rw io.ReadWriter) Framer { return NewHDLC(rw, testCase.Options) }, "hello")
+		if sum := crc.MakeTable(testCase.Params).Checksum(got[1 : len(got)-1]); sum != testCase.Residue {
+			t.Errorf("%v: unexpected residue %#x in % x", testCase.Options.FCS, sum, got)
+		}
+	}
+
+	if err := NewHDLC(&chunkedPort{}, HDLCOptions{MaxLength: 2}).WriteFrame([]byte("abc")); err != ErrFrameSize {
+		t.Errorf("expected ErrFrameSize, got %v", err)
+	}
+}
+
+func TestHDLC(t *testing.T) {
+	options := HDLCOptions{FCS: FCS_32, MaxLength: 8}
+	newFramer := func(rw io.ReadWriter) Framer { return NewHDLC(rw, options) }
+	good := encode(t, newFramer, "one", "\x7e\x7d\x00")
+	bad := encode(t, newFramer, "two")
+	bad[2] ^= 0x01
+
+	// Noise before the first flag, a frame with a bad FCS, an aborted frame,
+	// a frame too short for an FCS, a frame that is too long and control
+	// characters inserted by the link, split across reads.
+	tooLong := encode(t, func(rw io.ReadWriter) Framer { return NewHDLC(rw, HDLCOptions{}) }, "far too long")
+	input := join([]byte("noise"), bad, []byte{'x', HDLC_ESCAPE, HDLC_FLAG, 'y', HDLC_FLAG}, tooLong,
+		good[:3], []byte{0x11, 0x13}, good[3:])
+
+	port := &chunkedPort{}
+	for i := 0; i < len(input); i += 3 {
+		port.chunks = append(port.chunks, input[i:min(i+3, len(input))], nil)
+	}
+
+	f := NewHDLC(port, options)
+	checkFrames(t, "HDLC", f,
+		[]string{"one", "\x7e\x7d\x00"},
+		Stats{Frames: 2, BadFrames: 4, DiscardedBytes: 5 + uint64(len(bad)-1) + 3 + 2 + uint64(len(tooLong)-1)})
+
+	if n := f.FCSErrors(); n != 1 {
+		t.Errorf("expected 1 FCS error, got %d", n)
+	}
+}
@@ -0,0 +1,236 @@
+// Copyright 2011 Aaron Jacobs. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package framing
+
+import (
+	"io"
+	"sync/atomic"
+
+	"github.com/jacobsa/go-serial/crc"
+)
+
+// The special characters of asynchronous HDLC.
+const (
+	HDLC_FLAG   = 0x7e
+	HDLC_ESCAPE = 0x7d
+	HDLC_XOR    = 0x20
+)
+
+// The ACCM in use before any is negotiated, which escapes every control
+// character.
+const HDLC_DEFAULT_ACCM = 0xffffffff
+
+// Frame check sequences for HDLC. The values are their sizes in bits.
+type HDLCFCS int
+
+const (
+	FCS_16 HDLCFCS = 16 // CRC-16/IBM-SDLC, the default
+	FCS_32 HDLCFCS = 32 // CRC-32/ISO-HDLC
+)
+
+var (
+	fcs16 = CRC(crc.MakeTable(crc.CRC16_IBM_SDLC), true)
+	fcs32 = CRC(crc.MakeTable(crc.CRC32_ISO_HDLC), true)
+)
+
+// HDLCOptions configures an HDLC framer.
+type HDLCOptions struct {
+	// The frame check sequence. Defaults to FCS_16.
+	FCS HDLCFCS
+
+	// The Async-Control-Character-Map: bit n set means that the control
+	// character n, from 0x00 to 0x1f, is escaped when sent and ignored if
+	// received unescaped. Defaults to HDLC_DEFAULT_ACCM, which escapes them
+	// all, as RFC 1662 requires until another map is negotiated.
+	ACCM uint32
+
+	// Escape none of the control characters, as most PPP links negotiate,
+	// rather than those given by ACCM. An ACCM of zero cannot ask for this,
+	// since it means the default.
+	NoACCM bool
+
+	// The longest frame accepted, excluding the FCS. Defaults to 4096.
+	MaxLength int
+}
+
+// HDLC reads and writes frames in the asynchronous HDLC-like framing of RFC
+// 1662, used by PPP. Each frame is sent between flag bytes and ends with a
+// frame check sequence, which is sent least significant byte first. Flags,
+// escapes and the control characters selected by the ACCM are sent as an
+// escape followed by the character XORed with 0x20.
+//
+// The frames read and written include the address and control fields, if
+// any; the framer adds and removes only the flags, escaping and FCS.
+// Repeated flags are ignored. Frames that are aborted by an escape followed
+// by a flag, are too short to hold an FCS, are longer than MaxLength or fail
+// the FCS are dropped.
+type HDLC struct {
+	counters
+	fcsErrors atomic.Uint64
+
+	rw      io.ReadWriter
+	options HDLCOptions
+	check   *Check
+	in      buffer
+
+	// The frame being received, unescaped, and the number of bytes received
+	// for it so far. Data before the first flag is discarded.
+	frame   []byte
+	size    int
+	synced  bool
+	escaped bool
+	tooLong bool
+}
+
+var _ Framer = &HDLC{}
+
+// NewHDLC returns an HDLC framer for the port.
+func NewHDLC(rw io.ReadWriter, options HDLCOptions) *HDLC {
+	if options.MaxLength <= 0 {
+		options.MaxLength = kDefaultMaxLength
+	}
+
+	switch {
+	case options.NoACCM:
+		options.ACCM = 0
+	case options.ACCM == 0:
+		options.ACCM = HDLC_DEFAULT_ACCM
+	}
+
+	check := fcs16
+	if options.FCS == FCS_32 {
+		check = fcs32
+	}
+
+	return &HDLC{
+		rw:      rw,
+		options: options,
+		check:   check,
+		in:      buffer{r: rw},
+	}
+}
+
+// FCSErrors returns the number of frames dropped because their FCS was
+// wrong, which are also counted in Stats as bad frames. It may be called
+// concurrently with ReadFrame.
+func (f *HDLC) FCSErrors() uint64 {
+	return f.fcsErrors.Load()
+}
+
+func (f *HDLC) ReadFrame() ([]byte, error) {
+	for {
+		for i, c := range f.in.buf {
+			if frame, ok := f.decode(c); ok {
+				f.in.consume(i + 1)
+				return frame, nil
+			}
+		}
+
+		f.in.consume(len(f.in.buf))
+		if err := f.in.fill(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// decode processes the next byte received, returning the frame if it
+// completes a valid one.
+func (f *HDLC) decode(c byte) ([]byte, bool) {
+	switch {
+	case c == HDLC_FLAG:
+		return f.endFrame()
+
+	case !f.synced:
+		f.discardedBytes.Add(1)
+		return nil, false
+	}
+
+	f.size++
+
+	switch {
+	case c < 0x20 && f.options.ACCM&(1<<c) != 0:
+		// Inserted by the link; not part of the frame.
+
+	case f.escaped:
+		f.escaped = false
+		f.appendByte(c ^ HDLC_XOR)
+
+	case c == HDLC_ESCAPE:
+		f.escaped = true
+
+	default:
+		f.appendByte(c)
+	}
+
+	return nil, false
+}
+
+// endFrame ends the frame being received at a flag, which also begins the
+// next.
+func (f *HDLC) endFrame() ([]byte, bool) {
+	size := f.size + 1
+	frame := f.frame
+	aborted := f.escaped || f.tooLong
+	wasSynced := f.synced
+
+	f.frame = f.frame[:0]
+	f.size = 0
+	f.synced = true
+	f.escaped = false
+	f.tooLong = false
+
+	switch {
+	case !wasSynced || size == 1:
+		return nil, false
+
+	case aborted || len(frame) < f.check.size():
+		f.bad(size)
+		return nil, false
+
+	case !f.check.valid(frame, 0):
+		f.fcsErrors.Add(1)
+		f.bad(size)
+		return nil, false
+	}
+
+	f.frames.Add(1)
+	return append([]byte(nil), frame[:len(frame)-f.check.size()]...), true
+}
+
+func (f *HDLC) appendByte(c byte) {
+	if len(f.frame) < f.options.MaxLength+f.check.size() {
+		f.frame = append(f.frame, c)
+	} else {
+		f.tooLong = true
+	}
+}
+
+func (f *HDLC) WriteFrame(frame []byte) error {
+	if len(frame) > f.options.MaxLength {
+		return ErrFrameSize
+	}
+
+	encoded := []byte{HDLC_FLAG}
+	for _, c := range f.check.appendSum(frame[:len(frame):len(frame)], frame) {
+		if c == HDLC_FLAG || c == HDLC_ESCAPE || c < 0x20 && f.options.ACCM&(1<<c) != 0 {
+			encoded = append(encoded, HDLC_ESCAPE, c^HDLC_XOR)
+		} else {
+			encoded = append(encoded, c)
+		}
+	}
+
+	encoded = append(encoded, HDLC_FLAG)
+	return write(f.rw, encoded)
+}
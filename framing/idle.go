@@ -0,0 +1,167 @@
+// Copyright 2011 Aaron Jacobs. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package framing
+
+import (
+	"io"
+	"time"
+)
+
+// IdleGapOptions configures an IdleGap framer.
+type IdleGapOptions struct {
+	// The shortest silence that ends a frame. Zero means that a frame ends
+	// whenever a read returns no data, leaving the port's InterByteTimeout to
+	// determine the gap.
+	Gap time.Duration
+
+	// The longest payload accepted. Defaults to 4096.
+	MaxLength int
+
+	// An optional check value following the payload, covering the payload.
+	Check *Check
+}
+
+// IdleGap reads and writes frames separated by pauses in transmission, as in
+// Modbus RTU. A frame ends once no data has been received for Gap.
+//
+// The gap is measured between the returns of successive reads of the port,
+// so the port should be opened with a ReadTimeout shorter than the gap for
+// the end of a frame to be noticed promptly. Data that arrives while no read
+// is in progress is timed when it is read, so frames are only separated
+// reliably while ReadFrame is being called.
+//
+// Frames with a wrong check value or longer than MaxLength are dropped, and
+// the framer resynchronizes at the next gap. WriteFrame does not wait for
+// the gap before writing; protocols that alternate between request and
+// response leave it naturally.
+type IdleGap struct {
+	counters
+
+	rw      io.ReadWriter
+	options IdleGapOptions
+
+	// The frame being received, the number of bytes received for it and when
+	// its last data arrived. Bytes beyond MaxLength are counted but not kept.
+	frame []byte
+	size  int
+	last  time.Time
+
+	chunk []byte
+	err   error
+}
+
+var _ Framer = &IdleGap{}
+
+// NewIdleGap returns an IdleGap framer for the port.
+func NewIdleGap(rw io.ReadWriter, options IdleGapOptions) *IdleGap {
+	if options.MaxLength <= 0 {
+		options.MaxLength = kDefaultMaxLength
+	}
+
+	return &IdleGap{
+		rw:      rw,
+		options: options,
+		chunk:   make([]byte, 256),
+	}
+}
+
+func (f *IdleGap) ReadFrame() ([]byte, error) {
+	for {
+		if f.err != nil {
+			err := f.err
+			f.err = nil
+			return nil, err
+		}
+
+		n, err := f.rw.Read(f.chunk)
+		now := time.Now()
+
+		// A read that returns data after the gap has passed ends the previous
+		// frame and begins the next.
+		var payload []byte
+		var ok bool
+		if n > 0 && f.size > 0 && now.Sub(f.last) >= f.options.Gap && f.options.Gap > 0 {
+			payload, ok = f.finish()
+		}
+
+		if n > 0 {
+			f.appendData(f.chunk[:n])
+			f.last = now
+		}
+
+		switch {
+		case ok:
+			f.err = err
+			return payload, nil
+
+		case n > 0 && err == nil:
+			continue
+
+		case f.size == 0 && err != nil:
+			return nil, err
+
+		case f.size == 0:
+			return nil, ErrTimeout
+
+		// Whatever has been received is complete if the port fails or the
+		// gap has passed.
+		case err != nil || n == 0 && now.Sub(f.last) >= f.options.Gap:
+			payload, ok = f.finish()
+			if ok {
+				f.err = err
+				return payload, nil
+			}
+
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// appendData adds data to the frame being received, keeping no more than the
+// longest valid frame.
+func (f *IdleGap) appendData(data []byte) {
+	f.size += len(data)
+	if limit := f.options.MaxLength + f.options.Check.size(); len(f.frame) < limit {
+		f.frame = append(f.frame, data[:min(len(data), limit-len(f.frame))]...)
+	}
+}
+
+// finish ends the frame being received, returning its payload if it is
+// valid.
+func (f *IdleGap) finish() ([]byte, bool) {
+	frame, size := f.frame, f.size
+	f.frame = nil
+	f.size = 0
+
+	if size > f.options.MaxLength+f.options.Check.size() || !f.options.Check.valid(frame, 0) {
+		f.bad(size)
+		return nil, false
+	}
+
+	f.frames.Add(1)
+	return frame[:len(frame)-f.options.Check.size()], true
+}
+
+func (f *IdleGap) WriteFrame(payload []byte) error {
+	if len(payload) > f.options.MaxLength {
+		return ErrFrameSize
+	}
+
+	frame := append([]byte(nil), payload...)
+	frame = f.options.Check.appendSum(frame, payload)
+	return write(f.rw, frame)
+}
@@ -0,0 +1,245 @@
+// Copyright 2011 Aaron Jacobs. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package framing
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// LengthPrefixedOptions configures a LengthPrefixed framer.
+type LengthPrefixedOptions struct {
+	// Optional bytes that begin every frame, such as a sync word, before the
+	// header.
+	Preamble []byte
+
+	// The size of the header holding the payload length, in bytes, from 1 to
+	// 8. Defaults to 2; if it is out of range, ReadFrame and WriteFrame
+	// return ErrInvalidOptions.
+	HeaderSize int
+
+	// Whether the header is least significant byte first.
+	LittleEndian bool
+
+	// The longest payload accepted. Defaults to the largest the header can
+	// hold, or 65535 if that is larger.
+	MaxLength int
+
+	// An optional check value following the payload, covering the header
+	// and payload.
+	Check *Check
+}
+
+// LengthPrefixed reads and writes frames made up of an optional preamble, a
+// header giving the length of the payload, the payload and an optional check
+// value.
+//
+// A frame whose header exceeds MaxLength or whose check value is wrong is
+// assumed to be garbage: the framer skips a single byte and looks for a frame
+// starting at the next one (or at the next preamble, if there is one). Use a
+// preamble or a check value to resynchronize reliably.
+type LengthPrefixed struct {
+	counters
+	scanner
+
+	w       io.Writer
+	options LengthPrefixedOptions
+}
+
+var _ Framer = &LengthPrefixed{}
+
+// NewLengthPrefixed returns a LengthPrefixed framer for the port.
+func NewLengthPrefixed(rw io.ReadWriter, options LengthPrefixedOptions) *LengthPrefixed {
+	if options.HeaderSize == 0 {
+		options.HeaderSize = 2
+	}
+
+	if options.HeaderSize < 1 || options.HeaderSize > 8 {
+		err := fmt.Errorf("header size %d not in 1-8: %w", options.HeaderSize, ErrInvalidOptions)
+		return &LengthPrefixed{scanner: scanner{invalid: err}}
+	}
+
+	if limit := maxLength(options.HeaderSize); options.MaxLength <= 0 || options.MaxLength > limit {
+		options.MaxLength = limit
+		if limit > 65535 {
+			options.MaxLength = 65535
+		}
+	}
+
+	f := &LengthPrefixed{w: rw, options: options}
+	f.scanner = scanner{
+		in:       buffer{r: rw},
+		counters: &f.counters,
+		preamble: options.Preamble,
+		header:   options.HeaderSize,
+		check:    options.Check,
+		length: func(header []byte) (int, bool) {
+			n := getUint(header, options.LittleEndian)
+			return int(n), n <= uint64(options.MaxLength)
+		},
+	}
+
+	return f
+}
+
+// maxLength returns the largest length a header of the given size can hold,
+// capped to the largest int.
+func maxLength(headerSize int) int {
+	if headerSize >= 8 {
+		return int(^uint(0) >> 1)
+	}
+
+	return 1<<(8*headerSize) - 1
+}
+
+func (f *LengthPrefixed) WriteFrame(payload []byte) error {
+	if f.invalid != nil {
+		return f.invalid
+	}
+
+	if len(payload) > f.options.MaxLength {
+		return ErrFrameSize
+	}
+
+	frame := append([]byte(nil), f.options.Preamble...)
+	frame = putUint(frame, uint64(len(payload)), f.options.HeaderSize, f.options.LittleEndian)
+	frame = append(frame, payload...)
+	frame = f.options.Check.appendSum(frame, frame[len(f.options.Preamble):])
+	return write(f.w, frame)
+}
+
+// scanner finds frames at fixed offsets from an optional preamble, sliding
+// along the input a byte at a time after anything that is not a valid frame.
+type scanner struct {
+	in       buffer
+	counters *counters
+
+	preamble []byte
+	header   int
+	check    *Check
+
+	// length returns the payload length given by a header, and whether it is
+	// acceptable.
+	length func(header []byte) (int, bool)
+
+	// Set if the framer's options are invalid, in which case the other fields
+	// are unset and every call returns this error.
+	invalid error
+}
+
+// ReadFrame returns the payload of the next valid frame.
+func (s *scanner) ReadFrame() ([]byte, error) {
+	if s.invalid != nil {
+		return nil, s.invalid
+	}
+
+	for {
+		if payload, ok := s.next(); ok {
+			return payload, nil
+		}
+
+		if err := s.in.fill(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// next returns the first valid frame in the buffer, discarding anything
+// before it, or false if more data is needed.
+func (s *scanner) next() ([]byte, bool) {
+	for {
+		buf := s.in.buf
+		if len(s.preamble) > 0 {
+			i := bytes.Index(buf, s.preamble)
+			if i < 0 {
+				// Keep what could be the start of a preamble.
+				s.discard(max(0, len(buf)-len(s.preamble)+1))
+				return nil, false
+			}
+
+			s.discard(i)
+			buf = s.in.buf
+		}
+
+		start := len(s.preamble)
+		if len(buf) < start+s.header {
+			return nil, false
+		}
+
+		n, ok := s.length(buf[start : start+s.header])
+		if !ok {
+			s.skip()
+			continue
+		}
+
+		end := start + s.header + n + s.check.size()
+		if len(buf) < end {
+			return nil, false
+		}
+
+		if !s.check.valid(buf[:end], start) {
+			s.skip()
+			continue
+		}
+
+		payload := append([]byte(nil), buf[start+s.header:start+s.header+n]...)
+		s.in.consume(end)
+		s.counters.frames.Add(1)
+		return payload, true
+	}
+}
+
+// discard drops n bytes found outside any frame.
+func (s *scanner) discard(n int) {
+	s.in.consume(n)
+	s.counters.discardedBytes.Add(uint64(n))
+}
+
+// skip drops the first byte of a bad frame, to look for another beginning
+// after it.
+func (s *scanner) skip() {
+	s.counters.bad(1)
+	s.in.consume(1)
+}
+
+// getUint decodes an unsigned integer of len(b) bytes.
+func getUint(b []byte, littleEndian bool) uint64 {
+	var n uint64
+	for i := range b {
+		c := b[i]
+		if littleEndian {
+			c = b[len(b)-1-i]
+		}
+
+		n = n<<8 | uint64(c)
+	}
+
+	return n
+}
+
+// putUint appends n encoded in size bytes to b.
+func putUint(b []byte, n uint64, size int, littleEndian bool) []byte {
+	for i := 0; i < size; i++ {
+		shift := 8 * (size - 1 - i)
+		if littleEndian {
+			shift = 8 * i
+		}
+
+		b = append(b, byte(n>>shift))
+	}
+
+	return b
+}
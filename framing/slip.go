@@ -0,0 +1,182 @@
+// Copyright 2011 Aaron Jacobs. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package framing
+
+import "io"
+
+// The special characters of SLIP.
+const (
+	SLIP_END     = 0xc0
+	SLIP_ESC     = 0xdb
+	SLIP_ESC_END = 0xdc
+	SLIP_ESC_ESC = 0xdd
+)
+
+// The default for SLIPOptions.MaxLength: the largest packet RFC 1055 says
+// implementations should expect.
+const kDefaultSLIPMaxLength = 1006
+
+// SLIPOptions configures a SLIP framer.
+type SLIPOptions struct {
+	// Send an END before each packet as well as after it, so that any noise
+	// on the line is received as a separate, discarded packet.
+	LeadingEND bool
+
+	// The longest packet accepted. Defaults to 1006.
+	MaxLength int
+}
+
+// SLIP reads and writes packets in the Serial Line IP framing of RFC 1055.
+// Each packet ends with an END byte, and END and ESC bytes within it are
+// sent as two-byte escape sequences.
+//
+// Empty packets, as produced by a leading END, are skipped. As the RFC
+// recommends, an ESC followed by anything other than ESC_END or ESC_ESC is
+// dropped and the following byte kept. Packets that hold nothing but an ESC,
+// or are longer than MaxLength, are dropped.
+type SLIP struct {
+	counters
+
+	rw      io.ReadWriter
+	options SLIPOptions
+	in      buffer
+
+	// The packet being received, and whether the last byte was an ESC.
+	packet  []byte
+	size    int
+	escaped bool
+}
+
+var _ Framer = &SLIP{}
+
+// NewSLIP returns a SLIP framer for the port.
+func NewSLIP(rw io.ReadWriter, options SLIPOptions) *SLIP {
+	if options.MaxLength <= 0 {
+		options.MaxLength = kDefaultSLIPMaxLength
+	}
+
+	return &SLIP{
+		rw:      rw,
+		options: options,
+		in:      buffer{r: rw},
+	}
+}
+
+// ReadPacket returns the next packet. It is the same as ReadFrame.
+func (f *SLIP) ReadPacket() ([]byte, error) {
+	return f.ReadFrame()
+}
+
+// WritePacket sends a packet. It is the same as WriteFrame.
+func (f *SLIP) WritePacket(packet []byte) error {
+	return f.WriteFrame(packet)
+}
+
+func (f *SLIP) ReadFrame() ([]byte, error) {
+	for {
+		for i, c := range f.in.buf {
+			if packet, ok := f.decode(c); ok {
+				f.in.consume(i + 1)
+				return packet, nil
+			}
+		}
+
+		f.in.consume(len(f.in.buf))
+		if err := f.in.fill(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// decode processes the next byte received, returning the packet if it ends
+// one.
+func (f *SLIP) decode(c byte) ([]byte, bool) {
+	f.size++
+
+	switch {
+	case c == SLIP_END:
+		size := f.size
+		f.size = 0
+		f.escaped = false
+
+		switch {
+		case size == 1:
+			return nil, false
+
+		// Only escapes, which carry no data.
+		case len(f.packet) == 0:
+			f.bad(size)
+			return nil, false
+
+		case len(f.packet) > f.options.MaxLength:
+			f.packet = f.packet[:0]
+			f.bad(size)
+			return nil, false
+		}
+
+		packet := append([]byte(nil), f.packet...)
+		f.packet = f.packet[:0]
+		f.frames.Add(1)
+		return packet, true
+
+	case f.escaped:
+		f.escaped = false
+		switch c {
+		case SLIP_ESC_END:
+			c = SLIP_END
+
+		case SLIP_ESC_ESC:
+			c = SLIP_ESC
+		}
+
+	case c == SLIP_ESC:
+		f.escaped = true
+		return nil, false
+	}
+
+	// Keep one byte more than the limit, to tell that it was exceeded.
+	if len(f.packet) <= f.options.MaxLength {
+		f.packet = append(f.packet, c)
+	}
+
+	return nil, false
+}
+
+func (f *SLIP) WriteFrame(packet []byte) error {
+	if len(packet) > f.options.MaxLength {
+		return ErrFrameSize
+	}
+
+	frame := make([]byte, 0, 2*len(packet)+2)
+	if f.options.LeadingEND {
+		frame = append(frame, SLIP_END)
+	}
+
+	for _, c := range packet {
+		switch c {
+		case SLIP_END:
+			frame = append(frame, SLIP_ESC, SLIP_ESC_END)
+
+		case SLIP_ESC:
+			frame = append(frame, SLIP_ESC, SLIP_ESC_ESC)
+
+		default:
+			frame = append(frame, c)
+		}
+	}
+
+	frame = append(frame, SLIP_END)
+	return write(f.rw, frame)
+}
@@ -0,0 +1,170 @@
+// Copyright 2011 Aaron Jacobs. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package framing
+
+import "io"
+
+// The control characters used by STXETX.
+const (
+	STX = 0x02
+	ETX = 0x03
+	DLE = 0x10
+)
+
+// The default for STXETXOptions.MaxLength.
+const kDefaultMaxLength = 4096
+
+// STXETXOptions configures an STXETX framer.
+type STXETXOptions struct {
+	// An optional check value following the payload, covering the payload
+	// before stuffing.
+	Check *Check
+
+	// The longest payload accepted, after unstuffing. Defaults to 4096.
+	MaxLength int
+}
+
+// STXETX reads and writes frames delimited by STX and ETX bytes. Any STX,
+// ETX or DLE in the payload or check value is preceded by a DLE, so an
+// unstuffed STX always begins a frame and an unstuffed ETX always ends one.
+// Bytes outside a frame are discarded, and an STX inside a frame abandons it
+// and begins another.
+type STXETX struct {
+	counters
+
+	rw      io.ReadWriter
+	options STXETXOptions
+	in      buffer
+
+	// The frame being received, unstuffed, and the number of bytes received
+	// for it so far.
+	frame   []byte
+	size    int
+	inFrame bool
+	escaped bool
+
+	// Whether the frame being received has exceeded MaxLength and is being
+	// skipped.
+	tooLong bool
+}
+
+var _ Framer = &STXETX{}
+
+// NewSTXETX returns an STXETX framer for the port.
+func NewSTXETX(rw io.ReadWriter, options STXETXOptions) *STXETX {
+	if options.MaxLength <= 0 {
+		options.MaxLength = kDefaultMaxLength
+	}
+
+	return &STXETX{
+		rw:      rw,
+		options: options,
+		in:      buffer{r: rw},
+	}
+}
+
+func (f *STXETX) ReadFrame() ([]byte, error) {
+	for {
+		for i, c := range f.in.buf {
+			if payload, ok := f.decode(c); ok {
+				f.in.consume(i + 1)
+				return payload, nil
+			}
+		}
+
+		f.in.consume(len(f.in.buf))
+		if err := f.in.fill(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// decode processes the next byte received, returning the payload if it
+// completes a valid frame.
+func (f *STXETX) decode(c byte) ([]byte, bool) {
+	if f.inFrame {
+		f.size++
+	}
+
+	switch {
+	case !f.inFrame:
+		if c == STX {
+			f.startFrame()
+		} else {
+			f.discardedBytes.Add(1)
+		}
+
+	case f.escaped:
+		f.escaped = false
+		f.appendByte(c)
+
+	case c == DLE:
+		f.escaped = true
+
+	case c == STX:
+		f.bad(f.size - 1)
+		f.startFrame()
+
+	case c == ETX:
+		f.inFrame = false
+		if f.tooLong || !f.options.Check.valid(f.frame, 0) {
+			f.bad(f.size)
+			return nil, false
+		}
+
+		f.frames.Add(1)
+		payload := f.frame[:len(f.frame)-f.options.Check.size()]
+		return append([]byte(nil), payload...), true
+
+	default:
+		f.appendByte(c)
+	}
+
+	return nil, false
+}
+
+func (f *STXETX) startFrame() {
+	f.frame = f.frame[:0]
+	f.size = 1
+	f.inFrame = true
+	f.escaped = false
+	f.tooLong = false
+}
+
+func (f *STXETX) appendByte(c byte) {
+	if len(f.frame) < f.options.MaxLength+f.options.Check.size() {
+		f.frame = append(f.frame, c)
+	} else {
+		f.tooLong = true
+	}
+}
+
+func (f *STXETX) WriteFrame(payload []byte) error {
+	if len(payload) > f.options.MaxLength {
+		return ErrFrameSize
+	}
+
+	frame := []byte{STX}
+	for _, c := range f.options.Check.appendSum(payload[:len(payload):len(payload)], payload) {
+		if c == STX || c == ETX || c == DLE {
+			frame = append(frame, DLE)
+		}
+
+		frame = append(frame, c)
+	}
+
+	frame = append(frame, ETX)
+	return write(f.rw, frame)
+}
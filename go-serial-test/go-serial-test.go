@@ -9,8 +9,15 @@ import (
 	"flag"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 
+	"github.com/jacobsa/go-serial/pcapng"
 	"github.com/jacobsa/go-serial/serial"
 )
 
@@ -30,14 +37,32 @@ func main() {
 	rs485 := flag.Bool("rs485", false, "enable RS485 RTS for direction control")
 	rs485HighDuringSend := flag.Bool("rs485_high_during_send", false, "RTS signal should be high during send")
 	rs485HighAfterSend := flag.Bool("rs485_high_after_send", false, "RTS signal should be high after send")
-	stopbits := flag.Uint("stopbits", 1, "Stop bits")
+	stopbits := serial.OneStop
+	flag.TextVar(&stopbits, "stopbits", serial.OneStop, "Stop bits: 1, 1.5 or 2")
 	databits := flag.Uint("databits", 8, "Data bits")
 	chartimeout := flag.Uint("chartimeout", 100, "Inter Character timeout (ms)")
 	minread := flag.Uint("minread", 0, "Minimum read count")
 	rx := flag.Bool("rx", false, "Read data received")
+	verbose := flag.Bool("v", false, "log all traffic and modem line changes to stderr")
+	capture := flag.String("capture", "", "write all traffic to this file in pcapng format")
+	rtac := flag.Bool("capture_rtac", false, "use LINKTYPE_RTAC_SERIAL rather than LINKTYPE_USER0 for -capture")
 
 	flag.Parse()
 
+	// What to close before exiting, in reverse order. Deferred calls don't
+	// run on os.Exit, so every exit goes through exit instead.
+	var closers []func()
+	var exitOnce sync.Once
+	exit := func(code int) {
+		exitOnce.Do(func() {
+			for i := len(closers) - 1; i >= 0; i-- {
+				closers[i]()
+			}
+
+			os.Exit(code)
+		})
+	}
+
 	if *port == "" {
 		fmt.Println("Must specify port")
 		usage()
@@ -60,7 +85,7 @@ func main() {
 		PortName:               *port,
 		BaudRate:               *baud,
 		DataBits:               *databits,
-		StopBits:               *stopbits,
+		StopBits:               stopbits,
 		MinimumReadSize:        *minread,
 		InterCharacterTimeout:  *chartimeout,
 		ParityMode:             parity,
@@ -69,21 +94,78 @@ func main() {
 		Rs485RtsHighAfterSend:  *rs485HighAfterSend,
 	}
 
+	var taps []serial.Tap
+
+	if *verbose {
+		handler := slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})
+		taps = append(taps, serial.NewLogTap(slog.New(handler).With("port", *port)))
+	}
+
+	if *capture != "" {
+		out, err := os.Create(*capture)
+		if err != nil {
+			fmt.Println("Error creating capture file: ", err)
+			exit(-1)
+		}
+
+		closers = append(closers, func() {
+			if err := out.Sync(); err != nil {
+				fmt.Println("Error writing capture file: ", err)
+			}
+
+			out.Close()
+		})
+
+		linkType := pcapng.LINKTYPE_USER0
+		if *rtac {
+			linkType = pcapng.LINKTYPE_RTAC_SERIAL
+		}
+
+		w, err := pcapng.NewWriter(out, pcapng.WriterOptions{
+			LinkType:      linkType,
+			InterfaceName: *port,
+			Application:   "go-serial-test",
+		})
+
+		if err != nil {
+			fmt.Println("Error writing capture file: ", err)
+			exit(-1)
+		}
+
+		taps = append(taps, pcapng.NewTap(w))
+	}
+
+	if len(taps) > 0 {
+		options.Tap = serial.MultiTap(taps...)
+	}
+
 	f, err := serial.Open(options)
 
 	if err != nil {
 		fmt.Println("Error opening serial port: ", err)
-		os.Exit(-1)
-	} else {
-		defer f.Close()
+		exit(-1)
 	}
 
+	closers = append(closers, func() { f.Close() })
+
+	// Stop cleanly on ^C, so that the capture file is complete. The receive
+	// loop stops at its next read; if that doesn't return soon, exit anyway.
+	var stopping atomic.Bool
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-interrupt
+		stopping.Store(true)
+		time.Sleep(time.Second)
+		exit(0)
+	}()
+
 	if *txData != "" {
 		txData_, err := hex.DecodeString(*txData)
 
 		if err != nil {
 			fmt.Println("Error decoding hex data: ", err)
-			os.Exit(-1)
+			exit(-1)
 		}
 
 		fmt.Println("Sending: ", hex.EncodeToString(txData_))
@@ -99,12 +181,13 @@ func main() {
 	}
 
 	if *rx {
-		for {
+		for !stopping.Load() {
 			buf := make([]byte, 32)
 			n, err := f.Read(buf)
 			if err != nil {
 				if err != io.EOF {
 					fmt.Println("Error reading from serial port: ", err)
+					exit(-1)
 				}
 			} else {
 				buf = buf[:n]
@@ -112,4 +195,6 @@ func main() {
 			}
 		}
 	}
+
+	exit(0)
 }
@@ -0,0 +1,57 @@
+// Copyright 2011 Aaron Jacobs. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ptytest opens pseudo-terminal pairs for the tests of packages that
+// talk over serial ports, so that both ends of a protocol can be run against
+// each other. Pseudo-terminals are currently supported only on Linux.
+package ptytest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jacobsa/go-serial/serial"
+)
+
+// OpenPair returns the two ends of a new pseudo-terminal, opened with the
+// given number of data bits and a ReadTimeout of 5ms. Both ends are closed
+// when the test finishes.
+func OpenPair(t testing.TB, dataBits uint) (*serial.Port, *serial.Port) {
+	t.Helper()
+
+	options := serial.OpenOptions{
+		PortName:    "pty://",
+		BaudRate:    115200,
+		DataBits:    dataBits,
+		StopBits:    1,
+		ReadTimeout: 5 * time.Millisecond,
+	}
+
+	a, err := serial.OpenPort(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { a.Close() })
+
+	options.PortName = a.PeerName()
+	b, err := serial.OpenPort(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { b.Close() })
+
+	return a, b
+}
@@ -0,0 +1,243 @@
+// Copyright 2011 Aaron Jacobs. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package kiss implements the KISS protocol for talking to a terminal node
+// controller (TNC), such as a packet radio modem, over a port returned by
+// serial.Open:
+//
+//	http://www.ax25.net/kiss.aspx
+//
+// Each KISS frame carries a type byte, giving the TNC port in its high
+// nibble and a command in its low nibble, followed by data. Data frames
+// carry packets to be sent or that have been received, typically AX.25;
+// the other commands set the TNC's timing parameters. Frames are delimited
+// and escaped exactly as SLIP packets are, with FEND, FESC, TFEND and TFESC
+// in place of SLIP's END, ESC, ESC_END and ESC_ESC.
+package kiss
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/jacobsa/go-serial/framing"
+)
+
+// The special characters of KISS.
+const (
+	FEND  = framing.SLIP_END
+	FESC  = framing.SLIP_ESC
+	TFEND = framing.SLIP_ESC_END
+	TFESC = framing.SLIP_ESC_ESC
+)
+
+// Command identifies the type of a frame.
+type Command byte
+
+const (
+	COMMAND_DATA         Command = 0x00
+	COMMAND_TX_DELAY     Command = 0x01
+	COMMAND_PERSISTENCE  Command = 0x02
+	COMMAND_SLOT_TIME    Command = 0x03
+	COMMAND_TX_TAIL      Command = 0x04
+	COMMAND_FULL_DUPLEX  Command = 0x05
+	COMMAND_SET_HARDWARE Command = 0x06
+	COMMAND_RETURN       Command = 0xff // Leave KISS mode; not sent per port
+)
+
+var commandNames = map[Command]string{
+	COMMAND_DATA:         "data",
+	COMMAND_TX_DELAY:     "TXDELAY",
+	COMMAND_PERSISTENCE:  "persistence",
+	COMMAND_SLOT_TIME:    "slot time",
+	COMMAND_TX_TAIL:      "TXtail",
+	COMMAND_FULL_DUPLEX:  "full duplex",
+	COMMAND_SET_HARDWARE: "set hardware",
+	COMMAND_RETURN:       "return",
+}
+
+func (c Command) String() string {
+	if name, ok := commandNames[c]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("command %#02x", byte(c))
+}
+
+var (
+	// ErrInvalidPort is returned when a frame is addressed to a TNC port
+	// above 15.
+	ErrInvalidPort = errors.New("TNC port out of range")
+
+	// ErrInvalidParameter is returned when a parameter cannot be expressed in
+	// a KISS command, or a command in a type byte.
+	ErrInvalidParameter = errors.New("parameter out of range")
+)
+
+// The unit of the timing parameters.
+const kTimeUnit = 10 * time.Millisecond
+
+// Frame is a KISS frame.
+type Frame struct {
+	// The TNC port, from 0 to 15. Most TNCs have only port 0.
+	Port byte
+
+	Command Command
+
+	// The packet, for data frames, or the command's parameter.
+	Data []byte
+}
+
+// ConnOptions configures a Conn.
+type ConnOptions struct {
+	// The longest frame accepted, including its type byte. Defaults to 4096.
+	MaxLength int
+}
+
+// Conn exchanges KISS frames with a TNC. A Conn may be used by one reader
+// and one writer concurrently.
+type Conn struct {
+	slip *framing.SLIP
+}
+
+// NewConn returns a Conn talking to the TNC over the port, which should
+// already be in KISS mode.
+func NewConn(port io.ReadWriter, options ConnOptions) *Conn {
+	if options.MaxLength <= 0 {
+		options.MaxLength = 4096
+	}
+
+	slip := framing.NewSLIP(port, framing.SLIPOptions{
+		LeadingEND: true,
+		MaxLength:  options.MaxLength,
+	})
+
+	return &Conn{slip: slip}
+}
+
+// ReadFrame returns the next frame received from the TNC. Like the framers
+// of the framing package, it returns framing.ErrTimeout if a read from the
+// port returns no data before a whole frame has arrived. Frames without a
+// type byte are skipped.
+func (c *Conn) ReadFrame() (Frame, error) {
+	var packet []byte
+	for len(packet) == 0 {
+		var err error
+		if packet, err = c.slip.ReadPacket(); err != nil {
+			return Frame{}, err
+		}
+	}
+
+	if packet[0] == byte(COMMAND_RETURN) {
+		return Frame{Command: COMMAND_RETURN, Data: packet[1:]}, nil
+	}
+
+	return Frame{
+		Port:    packet[0] >> 4,
+		Command: Command(packet[0] & 0x0f),
+		Data:    packet[1:],
+	}, nil
+}
+
+// WriteFrame sends a frame to the TNC. The port is ignored for
+// COMMAND_RETURN.
+func (c *Conn) WriteFrame(f Frame) error {
+	typ := byte(f.Command)
+	if f.Command != COMMAND_RETURN {
+		switch {
+		case f.Port > 15:
+			return ErrInvalidPort
+
+		case f.Command > 0x0f:
+			return ErrInvalidParameter
+		}
+
+		typ |= f.Port << 4
+	}
+
+	return c.slip.WritePacket(append([]byte{typ}, f.Data...))
+}
+
+// Stats returns the counts of frames and bytes received so far.
+func (c *Conn) Stats() framing.Stats {
+	return c.slip.Stats()
+}
+
+// Send sends a packet for the TNC to transmit on the port.
+func (c *Conn) Send(port byte, packet []byte) error {
+	return c.WriteFrame(Frame{Port: port, Command: COMMAND_DATA, Data: packet})
+}
+
+// setParameter sends a command with a one-byte parameter.
+func (c *Conn) setParameter(port byte, command Command, value byte) error {
+	return c.WriteFrame(Frame{Port: port, Command: command, Data: []byte{value}})
+}
+
+// setDuration sends a command whose parameter is a time in units of 10ms,
+// rounded to the nearest unit.
+func (c *Conn) setDuration(port byte, command Command, d time.Duration) error {
+	units := (d + kTimeUnit/2) / kTimeUnit
+	if d < 0 || units > 255 {
+		return ErrInvalidParameter
+	}
+
+	return c.setParameter(port, command, byte(units))
+}
+
+// SetTXDelay sets how long the TNC waits after keying the transmitter before
+// sending data, from 0 to 2.55s in steps of 10ms.
+func (c *Conn) SetTXDelay(port byte, d time.Duration) error {
+	return c.setDuration(port, COMMAND_TX_DELAY, d)
+}
+
+// SetPersistence sets the persistence parameter P of the TNC's p-persistent
+// CSMA: when the channel is clear, it transmits with probability (P+1)/256.
+func (c *Conn) SetPersistence(port byte, p byte) error {
+	return c.setParameter(port, COMMAND_PERSISTENCE, p)
+}
+
+// SetSlotTime sets how long the TNC waits between attempts to transmit when
+// the persistence test fails, from 0 to 2.55s in steps of 10ms.
+func (c *Conn) SetSlotTime(port byte, d time.Duration) error {
+	return c.setDuration(port, COMMAND_SLOT_TIME, d)
+}
+
+// SetTXTail sets how long the TNC keeps the transmitter keyed after sending
+// data, from 0 to 2.55s in steps of 10ms. The KISS specification considers
+// it obsolete.
+func (c *Conn) SetTXTail(port byte, d time.Duration) error {
+	return c.setDuration(port, COMMAND_TX_TAIL, d)
+}
+
+// SetFullDuplex sets whether the TNC transmits without waiting for the
+// channel to be clear.
+func (c *Conn) SetFullDuplex(port byte, fullDuplex bool) error {
+	var value byte
+	if fullDuplex {
+		value = 1
+	}
+
+	return c.setParameter(port, COMMAND_FULL_DUPLEX, value)
+}
+
+// SetHardware sends a TNC-specific command.
+func (c *Conn) SetHardware(port byte, data []byte) error {
+	return c.WriteFrame(Frame{Port: port, Command: COMMAND_SET_HARDWARE, Data: data})
+}
+
+// ExitKISS asks the TNC to leave KISS mode.
+func (c *Conn) ExitKISS() error {
+	return c.WriteFrame(Frame{Command: COMMAND_RETURN})
+}
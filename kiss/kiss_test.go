@@ -0,0 +1,137 @@
+package kiss
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/jacobsa/go-serial/framing"
+	"github.com/jacobsa/go-serial/serial"
+)
+
+// port records what is written to it and reads from in.
+type port struct {
+	in      bytes.Buffer
+	written bytes.Buffer
+}
+
+func (p *port) Read(b []byte) (int, error)  { return p.in.Read(b) }
+func (p *port) Write(b []byte) (int, error) { return p.written.Write(b) }
+
+func TestCommands(t *testing.T) {
+	testCases := []struct {
+		Name     string
+		Send     func(c *Conn) error
+		Expected []byte
+	}{
+		{"data", func(c *Conn) error { return c.Send(0, []byte{'a', FEND, FESC}) }, []byte{FEND, 0x00, 'a', FESC, TFEND, FESC, TFESC, FEND}},
+		{"port", func(c *Conn) error { return c.Send(5, []byte("b")) }, []byte{FEND, 0x50, 'b', FEND}},
+		{"TXDELAY", func(c *Conn) error { return c.SetTXDelay(0, 500*time.Millisecond) }, []byte{FEND, 0x01, 50, FEND}},
+		{"rounding", func(c *Conn) error { return c.SetTXDelay(1, 304*time.Millisecond) }, []byte{FEND, 0x11, 30, FEND}},
+		{"persistence", func(c *Conn) error { return c.SetPersistence(0, 63) }, []byte{FEND, 0x02, 63, FEND}},
+		{"slot time", func(c *Conn) error { return c.SetSlotTime(2, 100*time.Millisecond) }, []byte{FEND, 0x23, 10, FEND}},
+		{"TXtail", func(c *Conn) error { return c.SetTXTail(0, 2550*time.Millisecond) }, []byte{FEND, 0x04, 255, FEND}},
+		{"full duplex", func(c *Conn) error { return c.SetFullDuplex(0, true) }, []byte{FEND, 0x05, 1, FEND}},
+		{"hardware", func(c *Conn) error { return c.SetHardware(0, []byte{FEND}) }, []byte{FEND, 0x06, FESC, TFEND, FEND}},
+		{"return", func(c *Conn) error { return c.ExitKISS() }, []byte{FEND, 0xff, FEND}},
+	}
+
+	for _, testCase := range testCases {
+		p := &port{}
+		if err := testCase.Send(NewConn(p, ConnOptions{})); err != nil {
+			t.Errorf("%s: %v", testCase.Name, err)
+			continue
+		}
+
+		if got := p.written.Bytes(); !bytes.Equal(got, testCase.Expected) {
+			t.Errorf("%s: expected % x, got % x", testCase.Name, testCase.Expected, got)
+		}
+	}
+}
+
+func TestInvalidCommands(t *testing.T) {
+	c := NewConn(&port{}, ConnOptions{})
+
+	if err := c.Send(16, nil); err != ErrInvalidPort {
+		t.Errorf("expected ErrInvalidPort, got %v", err)
+	}
+
+	if err := c.WriteFrame(Frame{Command: 0x10}); err != ErrInvalidParameter {
+		t.Errorf("expected ErrInvalidParameter, got %v", err)
+	}
+
+	for _, d := range []time.Duration{-time.Millisecond, 2556 * time.Millisecond} {
+		if err := c.SetSlotTime(0, d); err != ErrInvalidParameter {
+			t.Errorf("%v: expected ErrInvalidParameter, got %v", d, err)
+		}
+	}
+}
+
+func TestReadFrame(t *testing.T) {
+	p := &port{}
+	p.in.Write([]byte{FEND, FESC, FEND, FEND, 0x30, 'x', FESC, TFESC, FEND, 0x02, 0x10, FEND, FEND, 0xff, FEND})
+	c := NewConn(p, ConnOptions{})
+
+	expected := []Frame{
+		{Port: 3, Command: COMMAND_DATA, Data: []byte{'x', FESC}},
+		{Command: COMMAND_PERSISTENCE, Data: []byte{0x10}},
+		{Command: COMMAND_RETURN, Data: []byte{}},
+	}
+
+	for _, e := range expected {
+		f, err := c.ReadFrame()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if f.Port != e.Port || f.Command != e.Command || !bytes.Equal(f.Data, e.Data) {
+			t.Errorf("expected %+v, got %+v", e, f)
+		}
+	}
+
+	if stats := c.Stats(); stats != (framing.Stats{Frames: 3, BadFrames: 1, DiscardedBytes: 2}) {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestLoopback(t *testing.T) {
+	p, err := serial.Open(serial.OpenOptions{
+		PortName:    "loop://",
+		BaudRate:    9600,
+		DataBits:    8,
+		StopBits:    1,
+		ReadTimeout: 10 * time.Millisecond,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer p.Close()
+
+	c := NewConn(p, ConnOptions{})
+	packet := []byte{0x00, FEND, 0x01, FESC, 0x02}
+	if err := c.Send(1, packet); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		f, err := c.ReadFrame()
+		if err == framing.ErrTimeout {
+			continue
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if f.Port != 1 || f.Command != COMMAND_DATA || !bytes.Equal(f.Data, packet) {
+			t.Errorf("unexpected frame: %+v", f)
+		}
+
+		return
+	}
+
+	t.Fatal("timed out")
+}
@@ -0,0 +1,198 @@
+// Copyright 2011 Aaron Jacobs. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package metrics publishes the counters of open serial ports, as returned by
+// serial.Port.Stats, so that long-running programs can be monitored.
+//
+// Ports are added to a Registry when opened and removed when closed. The
+// registry can be published via expvar with Publish; see the promcollector
+// subpackage for exporting it to Prometheus.
+package metrics
+
+import (
+	"expvar"
+	"sort"
+	"sync"
+
+	"github.com/jacobsa/go-serial/serial"
+)
+
+// Registry tracks the set of ports whose counters are published. It is safe
+// for concurrent use.
+type Registry struct {
+	mu    sync.Mutex
+	ports map[string]*entry
+}
+
+type entry struct {
+	port      StatsSource
+	usbSerial string
+}
+
+// StatsSource is implemented by *serial.Port, and by anything else that can
+// report port counters.
+type StatsSource interface {
+	Stats() (serial.Stats, error)
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{ports: make(map[string]*entry)}
+}
+
+// DefaultRegistry is the registry used by the package-level Add and Remove
+// functions.
+var DefaultRegistry = NewRegistry()
+
+// Add starts publishing the counters of the port, keyed by its name (usually
+// OpenOptions.PortName). If the port is a USB adapter its serial number is
+// looked up and published alongside, so that a device can be followed when
+// it moves from one port name to another. Adding a name that is already
+// present replaces the earlier port.
+func (r *Registry) Add(name string, port StatsSource) {
+	e := &entry{
+		port:      port,
+		usbSerial: usbSerialNumber(name),
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.ports[name] = e
+}
+
+// Remove stops publishing the counters of the named port.
+func (r *Registry) Remove(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.ports, name)
+}
+
+// Add adds the port to DefaultRegistry.
+func Add(name string, port StatsSource) { DefaultRegistry.Add(name, port) }
+
+// Remove removes the port from DefaultRegistry.
+func Remove(name string) { DefaultRegistry.Remove(name) }
+
+// PortStats holds a snapshot of the counters of one registered port.
+type PortStats struct {
+	// The name the port was registered under.
+	Name string
+
+	// The serial number of the USB device backing the port, or the empty
+	// string if it is not a USB device or the number could not be found.
+	USBSerial string
+
+	serial.Stats
+
+	// Set if the port failed to report its kernel counters, in which case
+	// Stats.Kernel is nil.
+	Err error
+}
+
+// Snapshot returns the current counters of every registered port, sorted by
+// name.
+func (r *Registry) Snapshot() []PortStats {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.ports))
+	entries := make(map[string]*entry, len(r.ports))
+	for name, e := range r.ports {
+		names = append(names, name)
+		entries[name] = e
+	}
+	r.mu.Unlock()
+
+	sort.Strings(names)
+
+	result := make([]PortStats, 0, len(names))
+	for _, name := range names {
+		e := entries[name]
+		stats, err := e.port.Stats()
+		result = append(result, PortStats{
+			Name:      name,
+			USBSerial: e.usbSerial,
+			Stats:     stats,
+			Err:       err,
+		})
+	}
+
+	return result
+}
+
+// The JSON form of a port's counters published through expvar.
+type expvarPort struct {
+	USBSerial    string          `json:"usb_serial,omitempty"`
+	BytesRead    uint64          `json:"bytes_read"`
+	BytesWritten uint64          `json:"bytes_written"`
+	Reads        uint64          `json:"reads"`
+	Writes       uint64          `json:"writes"`
+	ReadErrors   uint64          `json:"read_errors"`
+	WriteErrors  uint64          `json:"write_errors"`
+	Kernel       *expvarCounters `json:"kernel,omitempty"`
+	Error        string          `json:"error,omitempty"`
+}
+
+type expvarCounters struct {
+	CTS        uint64 `json:"cts"`
+	DSR        uint64 `json:"dsr"`
+	RNG        uint64 `json:"rng"`
+	DCD        uint64 `json:"dcd"`
+	RX         uint64 `json:"rx"`
+	TX         uint64 `json:"tx"`
+	Frame      uint64 `json:"frame"`
+	Overrun    uint64 `json:"overrun"`
+	Parity     uint64 `json:"parity"`
+	Brk        uint64 `json:"brk"`
+	BufOverrun uint64 `json:"buf_overrun"`
+}
+
+// Var returns an expvar.Var whose value is a JSON object mapping each
+// registered port name to its counters.
+func (r *Registry) Var() expvar.Var {
+	return expvar.Func(func() interface{} {
+		result := make(map[string]expvarPort)
+		for _, s := range r.Snapshot() {
+			p := expvarPort{
+				USBSerial:    s.USBSerial,
+				BytesRead:    s.BytesRead,
+				BytesWritten: s.BytesWritten,
+				Reads:        s.Reads,
+				Writes:       s.Writes,
+				ReadErrors:   s.ReadErrors,
+				WriteErrors:  s.WriteErrors,
+			}
+
+			if k := s.Kernel; k != nil {
+				c := expvarCounters(*k)
+				p.Kernel = &c
+			}
+
+			if s.Err != nil {
+				p.Error = s.Err.Error()
+			}
+
+			result[s.Name] = p
+		}
+
+		return result
+	})
+}
+
+// Publish publishes the registry's counters under the given expvar name,
+// e.g. "serial". Like expvar.Publish, it panics if the name is already in
+// use.
+func (r *Registry) Publish(name string) {
+	expvar.Publish(name, r.Var())
+}
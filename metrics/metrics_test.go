@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/jacobsa/go-serial/serial"
+)
+
+type fakeSource struct {
+	Stats_ serial.Stats
+	Err    error
+}
+
+func (f *fakeSource) Stats() (serial.Stats, error) { return f.Stats_, f.Err }
+
+func TestSnapshot(t *testing.T) {
+	r := NewRegistry()
+	r.Add("/dev/ttyB", &fakeSource{Stats_: serial.Stats{BytesRead: 2}})
+	r.Add("/dev/ttyA", &fakeSource{Stats_: serial.Stats{BytesRead: 1}})
+	r.Add("/dev/ttyC", &fakeSource{})
+	r.Remove("/dev/ttyC")
+
+	snapshot := r.Snapshot()
+	if len(snapshot) != 2 {
+		t.Fatalf("expected 2 ports, got %d", len(snapshot))
+	}
+
+	if snapshot[0].Name != "/dev/ttyA" || snapshot[0].BytesRead != 1 {
+		t.Errorf("unexpected first entry %+v", snapshot[0])
+	}
+
+	if snapshot[1].Name != "/dev/ttyB" || snapshot[1].BytesRead != 2 {
+		t.Errorf("unexpected second entry %+v", snapshot[1])
+	}
+}
+
+func TestVar(t *testing.T) {
+	r := NewRegistry()
+	r.Add("/dev/ttyA", &fakeSource{
+		Stats_: serial.Stats{
+			BytesWritten: 7,
+			Kernel:       &serial.KernelCounters{Overrun: 3},
+		},
+	})
+
+	r.Add("/dev/ttyB", &fakeSource{Err: errors.New("boom")})
+
+	var decoded map[string]map[string]interface{}
+	if err := json.Unmarshal([]byte(r.Var().String()), &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	a := decoded["/dev/ttyA"]
+	if a["bytes_written"] != 7.0 {
+		t.Errorf("expected bytes_written 7, got %v", a["bytes_written"])
+	}
+
+	kernel, _ := a["kernel"].(map[string]interface{})
+	if kernel["overrun"] != 3.0 {
+		t.Errorf("expected kernel overrun 3, got %v", a["kernel"])
+	}
+
+	if b := decoded["/dev/ttyB"]; b["error"] != "boom" || b["kernel"] != nil {
+		t.Errorf("unexpected entry for failing port: %v", b)
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright 2011 Aaron Jacobs. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package promcollector exports the counters in a metrics.Registry to
+// Prometheus. It lives in its own package so that programs using only expvar
+// do not depend on the Prometheus client library.
+//
+// Every metric carries "port" and "usb_serial" labels. For example:
+//
+//	prometheus.MustRegister(promcollector.New(metrics.DefaultRegistry))
+package promcollector
+
+import (
+	"github.com/jacobsa/go-serial/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	bytesDesc = prometheus.NewDesc(
+		"serial_bytes_total",
+		"Bytes transferred through Read and Write on the port.",
+		[]string{"port", "usb_serial", "direction"},
+		nil)
+
+	callsDesc = prometheus.NewDesc(
+		"serial_calls_total",
+		"Calls to Read and Write on the port.",
+		[]string{"port", "usb_serial", "direction"},
+		nil)
+
+	errorsDesc = prometheus.NewDesc(
+		"serial_errors_total",
+		"Calls to Read and Write on the port that returned an error.",
+		[]string{"port", "usb_serial", "direction"},
+		nil)
+
+	kernelBytesDesc = prometheus.NewDesc(
+		"serial_kernel_bytes_total",
+		"Bytes transferred by the UART, as counted by the driver since it was loaded.",
+		[]string{"port", "usb_serial", "direction"},
+		nil)
+
+	kernelEventsDesc = prometheus.NewDesc(
+		"serial_kernel_events_total",
+		"Receive errors and modem line transitions, as counted by the driver since it was loaded.",
+		[]string{"port", "usb_serial", "event"},
+		nil)
+)
+
+// Collector is a prometheus.Collector for the ports in a metrics.Registry.
+type Collector struct {
+	registry *metrics.Registry
+}
+
+// New returns a collector for the ports in the supplied registry.
+func New(registry *metrics.Registry) *Collector {
+	return &Collector{registry}
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- bytesDesc
+	ch <- callsDesc
+	ch <- errorsDesc
+	ch <- kernelBytesDesc
+	ch <- kernelEventsDesc
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	for _, s := range c.registry.Snapshot() {
+		counter := func(desc *prometheus.Desc, v uint64, label string) {
+			ch <- prometheus.MustNewConstMetric(
+				desc,
+				prometheus.CounterValue,
+				float64(v),
+				s.Name,
+				s.USBSerial,
+				label)
+		}
+
+		counter(bytesDesc, s.BytesRead, "rx")
+		counter(bytesDesc, s.BytesWritten, "tx")
+		counter(callsDesc, s.Reads, "rx")
+		counter(callsDesc, s.Writes, "tx")
+		counter(errorsDesc, s.ReadErrors, "rx")
+		counter(errorsDesc, s.WriteErrors, "tx")
+
+		k := s.Kernel
+		if k == nil {
+			continue
+		}
+
+		counter(kernelBytesDesc, k.RX, "rx")
+		counter(kernelBytesDesc, k.TX, "tx")
+		counter(kernelEventsDesc, k.Frame, "frame")
+		counter(kernelEventsDesc, k.Parity, "parity")
+		counter(kernelEventsDesc, k.Overrun, "overrun")
+		counter(kernelEventsDesc, k.BufOverrun, "buf_overrun")
+		counter(kernelEventsDesc, k.Brk, "brk")
+		counter(kernelEventsDesc, k.CTS, "cts")
+		counter(kernelEventsDesc, k.DSR, "dsr")
+		counter(kernelEventsDesc, k.RNG, "rng")
+		counter(kernelEventsDesc, k.DCD, "dcd")
+	}
+}
@@ -0,0 +1,84 @@
+package promcollector
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jacobsa/go-serial/metrics"
+	"github.com/jacobsa/go-serial/serial"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type fakeSource struct {
+	stats serial.Stats
+}
+
+func (f *fakeSource) Stats() (serial.Stats, error) { return f.stats, nil }
+
+func TestCollector(t *testing.T) {
+	// A loop:// port has no kernel counters, so only the Port's own counters
+	// are exported for it.
+	port, err := serial.OpenURL("loop://")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer port.Close()
+
+	if _, err := port.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := port.Read(make([]byte, 3)); err != nil {
+		t.Fatal(err)
+	}
+
+	r := metrics.NewRegistry()
+	r.Add("loop", port)
+	r.Add("/dev/ttyS0", &fakeSource{
+		stats: serial.Stats{
+			Reads:  4,
+			Kernel: &serial.KernelCounters{RX: 100, TX: 50, Overrun: 2, DCD: 1},
+		},
+	})
+
+	expected := `
+# HELP serial_bytes_total Bytes transferred through Read and Write on the port.
+# TYPE serial_bytes_total counter
+serial_bytes_total{direction="rx",port="/dev/ttyS0",usb_serial=""} 0
+serial_bytes_total{direction="tx",port="/dev/ttyS0",usb_serial=""} 0
+serial_bytes_total{direction="rx",port="loop",usb_serial=""} 3
+serial_bytes_total{direction="tx",port="loop",usb_serial=""} 5
+# HELP serial_calls_total Calls to Read and Write on the port.
+# TYPE serial_calls_total counter
+serial_calls_total{direction="rx",port="/dev/ttyS0",usb_serial=""} 4
+serial_calls_total{direction="tx",port="/dev/ttyS0",usb_serial=""} 0
+serial_calls_total{direction="rx",port="loop",usb_serial=""} 1
+serial_calls_total{direction="tx",port="loop",usb_serial=""} 1
+# HELP serial_errors_total Calls to Read and Write on the port that returned an error.
+# TYPE serial_errors_total counter
+serial_errors_total{direction="rx",port="/dev/ttyS0",usb_serial=""} 0
+serial_errors_total{direction="tx",port="/dev/ttyS0",usb_serial=""} 0
+serial_errors_total{direction="rx",port="loop",usb_serial=""} 0
+serial_errors_total{direction="tx",port="loop",usb_serial=""} 0
+# HELP serial_kernel_bytes_total Bytes transferred by the UART, as counted by the driver since it was loaded.
+# TYPE serial_kernel_bytes_total counter
+serial_kernel_bytes_total{direction="rx",port="/dev/ttyS0",usb_serial=""} 100
+serial_kernel_bytes_total{direction="tx",port="/dev/ttyS0",usb_serial=""} 50
+# HELP serial_kernel_events_total Receive errors and modem line transitions, as counted by the driver since it was loaded.
+# TYPE serial_kernel_events_total counter
+serial_kernel_events_total{event="brk",port="/dev/ttyS0",usb_serial=""} 0
+serial_kernel_events_total{event="buf_overrun",port="/dev/ttyS0",usb_serial=""} 0
+serial_kernel_events_total{event="cts",port="/dev/ttyS0",usb_serial=""} 0
+serial_kernel_events_total{event="dcd",port="/dev/ttyS0",usb_serial=""} 1
+serial_kernel_events_total{event="dsr",port="/dev/ttyS0",usb_serial=""} 0
+serial_kernel_events_total{event="frame",port="/dev/ttyS0",usb_serial=""} 0
+serial_kernel_events_total{event="overrun",port="/dev/ttyS0",usb_serial=""} 2
+serial_kernel_events_total{event="parity",port="/dev/ttyS0",usb_serial=""} 0
+serial_kernel_events_total{event="rng",port="/dev/ttyS0",usb_serial=""} 0
+`
+
+	if err := testutil.CollectAndCompare(New(r), strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
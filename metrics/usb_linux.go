@@ -0,0 +1,54 @@
+// Copyright 2011 Aaron Jacobs. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// usbSerialNumber returns the serial number of the USB device backing the
+// named tty, e.g. "/dev/ttyUSB0" or "/dev/serial/by-id/usb-FTDI_...", by
+// walking up its sysfs device path to the first ancestor that has one. It
+// returns the empty string if there is no such device.
+func usbSerialNumber(portName string) string {
+	// Resolve udev symlinks such as /dev/serial/by-id/... to the real node.
+	if resolved, err := filepath.EvalSymlinks(portName); err == nil {
+		portName = resolved
+	}
+
+	dir, err := filepath.EvalSymlinks(
+		filepath.Join("/sys/class/tty", filepath.Base(portName), "device"))
+	if err != nil {
+		return ""
+	}
+
+	for ; dir != "/" && dir != "."; dir = filepath.Dir(dir) {
+		// The idVendor file distinguishes a USB device from its interfaces.
+		if _, err := os.Stat(filepath.Join(dir, "idVendor")); err != nil {
+			continue
+		}
+
+		b, err := os.ReadFile(filepath.Join(dir, "serial"))
+		if err != nil {
+			return ""
+		}
+
+		return strings.TrimSpace(string(b))
+	}
+
+	return ""
+}
@@ -0,0 +1,142 @@
+// Copyright 2011 Aaron Jacobs. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package modbus
+
+import (
+	"encoding/hex"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/jacobsa/go-serial/crc"
+)
+
+// The longest ASCII frame: a colon, an address, a PDU and an LRC in hex, and
+// CR LF.
+const kMaxASCIIFrame = 1 + 2*(1+kMaxPDU+1) + 2
+
+// ascii is the transport for ASCII mode, in which each frame is a colon
+// followed by the address, PDU and LRC in hexadecimal, ending with CR LF.
+// Ports for ASCII mode are usually opened with seven data bits.
+//
+// A colon always begins a new frame, abandoning any partial one, so that
+// garbage on the line is skipped.
+type ascii struct {
+	port io.ReadWriter
+
+	// Data read but not yet decoded, and the error that came with it.
+	in    []byte
+	err   error
+	chunk []byte
+
+	// The frame being received, after its colon.
+	frame   []byte
+	inFrame bool
+}
+
+func newASCII(port io.ReadWriter) *ascii {
+	return &ascii{
+		port:  port,
+		chunk: make([]byte, kMaxASCIIFrame),
+	}
+}
+
+func (a *ascii) send(unit byte, pdu []byte) error {
+	body := append([]byte{unit}, pdu...)
+	body = append(body, crc.LRC(body))
+	frame := ":" + strings.ToUpper(hex.EncodeToString(body)) + "\r\n"
+
+	// Anything received before the request can't be a response to it.
+	a.in = nil
+	a.inFrame = false
+
+	n, err := io.WriteString(a.port, frame)
+	if err == nil && n < len(frame) {
+		err = io.ErrShortWrite
+	}
+
+	return err
+}
+
+// receive ignores length, since ASCII frames mark their own end.
+func (a *ascii) receive(deadline time.Time, length func(pdu []byte) int) (byte, []byte, error) {
+	for {
+		for len(a.in) > 0 {
+			c := a.in[0]
+			a.in = a.in[1:]
+			if unit, pdu, ok := a.decode(c); ok {
+				return unit, pdu, nil
+			}
+		}
+
+		if err := a.err; err != nil {
+			a.err = nil
+			return 0, nil, err
+		}
+
+		if !deadline.IsZero() && !time.Now().Before(deadline) {
+			a.inFrame = false
+			return 0, nil, ErrTimeout
+		}
+
+		n, err := a.port.Read(a.chunk)
+		a.in = a.chunk[:n]
+		a.err = err
+	}
+}
+
+// decode processes the next character received, returning the address and
+// PDU if it completes a valid frame.
+func (a *ascii) decode(c byte) (byte, []byte, bool) {
+	switch {
+	case c == ':':
+		a.frame = a.frame[:0]
+		a.inFrame = true
+
+	case !a.inFrame:
+		// Noise between frames.
+
+	case c == '\n':
+		a.inFrame = false
+		return parseASCII(a.frame)
+
+	case len(a.frame) >= kMaxASCIIFrame:
+		a.inFrame = false
+
+	default:
+		a.frame = append(a.frame, c)
+	}
+
+	return 0, nil, false
+}
+
+// parseASCII returns the address and PDU of a frame, given what lies
+// between its colon and LF, if it is well formed and its LRC is valid.
+func parseASCII(frame []byte) (byte, []byte, bool) {
+	if len(frame) == 0 || frame[len(frame)-1] != '\r' {
+		return 0, nil, false
+	}
+
+	body := make([]byte, hex.DecodedLen(len(frame)-1))
+	if _, err := hex.Decode(body, frame[:len(frame)-1]); err != nil || len(body) < 3 {
+		return 0, nil, false
+	}
+
+	if crc.LRC(body[:len(body)-1]) != body[len(body)-1] {
+		return 0, nil, false
+	}
+
+	return body[0], body[1 : len(body)-1], true
+}
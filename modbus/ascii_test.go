@@ -0,0 +1,71 @@
+package modbus
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestASCIIFrame(t *testing.T) {
+	line := &fakeLine{}
+	a := newASCII(line)
+
+	// The example from the specification.
+	if err := a.send(17, []byte{0x03, 0x00, 0x6b, 0x00, 0x03}); err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := ":1103006B00037E\r\n"; string(line.written[0]) != expected {
+		t.Errorf("expected %q, got %q", expected, line.written[0])
+	}
+}
+
+func TestASCIIReceive(t *testing.T) {
+	testCases := []struct {
+		Name   string
+		Chunks []string
+		Unit   byte
+		PDU    []byte
+	}{
+		{"whole", []string{":1103006B00037E\r\n"}, 17, []byte{0x03, 0x00, 0x6b, 0x00, 0x03}},
+		{"split", []string{":1103", "006B0", "0037E\r", "\n"}, 17, []byte{0x03, 0x00, 0x6b, 0x00, 0x03}},
+		{"lower case", []string{":1103006b00037e\r\n"}, 17, []byte{0x03, 0x00, 0x6b, 0x00, 0x03}},
+		{"noise", []string{"\x00junk\r\n:110", ":01060001F8\r\n"}, 1, []byte{0x06, 0x00, 0x01}},
+		{"bad LRC", []string{":1103006B00037F\r\n:01060001F8\r\n"}, 1, []byte{0x06, 0x00, 0x01}},
+		{"odd length", []string{":1103006B00037\r\n:01060001F8\r\n"}, 1, []byte{0x06, 0x00, 0x01}},
+		{"no CR", []string{":1103006B00037E\n:01060001F8\r\n"}, 1, []byte{0x06, 0x00, 0x01}},
+	}
+
+	for _, testCase := range testCases {
+		line := &fakeLine{}
+		for _, c := range testCase.Chunks {
+			line.pending = append(line.pending, []byte(c))
+		}
+
+		unit, pdu, err := newASCII(line).receive(time.Now().Add(100*time.Millisecond), nil)
+		if err != nil || unit != testCase.Unit || !bytes.Equal(pdu, testCase.PDU) {
+			t.Errorf("%s: expected %d, % x; got %d, % x, %v", testCase.Name, testCase.Unit, testCase.PDU, unit, pdu, err)
+		}
+	}
+}
+
+func TestASCIIClient(t *testing.T) {
+	// Two frames in one read: the second is left for the next request.
+	line := &fakeLine{respond: reply([]byte(":1103020102E7\r\n:1106000100035\r\n"))}
+	c := NewASCIIClient(line, ClientOptions{Timeout: 100 * time.Millisecond})
+
+	values, err := c.ReadHoldingRegisters(17, 0, 1)
+	if err != nil || len(values) != 1 || values[0] != 0x0102 {
+		t.Errorf("expected [258], nil; got %v, %v", values, err)
+	}
+
+	if expected := ":110300000001EB\r\n"; string(line.written[0]) != expected {
+		t.Errorf("expected %q, got %q", expected, line.written[0])
+	}
+
+	// Leftovers are discarded when the next request is sent.
+	line.respond = nil
+	if err := c.WriteSingleRegister(17, 1, 3); err != ErrTimeout {
+		t.Errorf("expected ErrTimeout, got %v", err)
+	}
+}
@@ -0,0 +1,330 @@
+// Copyright 2011 Aaron Jacobs. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package modbus
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"time"
+)
+
+// ClientOptions configures a Client.
+type ClientOptions struct {
+	// The baud rate the port was opened with, i.e. OpenOptions.BaudRate,
+	// which determines the gap between RTU frames. Zero is treated as a rate
+	// above 19200 baud. Not used in ASCII mode.
+	BaudRate uint
+
+	// How long to wait for each response. Defaults to one second.
+	Timeout time.Duration
+
+	// How many times to resend a request that gets no valid response in time.
+	// Requests that get an exception response are not retried.
+	Retries int
+
+	// How long to wait after a broadcast, which gets no response, before the
+	// next request. Defaults to 100ms.
+	TurnaroundDelay time.Duration
+}
+
+// Client is a Modbus master. It is safe for concurrent use; requests are
+// sent one at a time.
+//
+// Requests to unit 0 are broadcast to all devices, which don't respond, so
+// only write requests may be sent to it.
+type Client struct {
+	mu        sync.Mutex
+	transport transport
+	options   ClientOptions
+}
+
+// NewRTUClient returns a client sending requests in RTU mode over the port.
+func NewRTUClient(port io.ReadWriter, options ClientOptions) *Client {
+	return newClient(newRTU(port, options.BaudRate), options)
+}
+
+// NewASCIIClient returns a client sending requests in ASCII mode over the
+// port. ClientOptions.BaudRate is not needed.
+func NewASCIIClient(port io.ReadWriter, options ClientOptions) *Client {
+	return newClient(newASCII(port), options)
+}
+
+func newClient(t transport, options ClientOptions) *Client {
+	if options.Timeout <= 0 {
+		options.Timeout = time.Second
+	}
+
+	if options.TurnaroundDelay <= 0 {
+		options.TurnaroundDelay = 100 * time.Millisecond
+	}
+
+	return &Client{transport: t, options: options}
+}
+
+// Send sends a request PDU (a function code followed by its data) to the
+// unit and returns the response PDU. Use it for functions that Client has no
+// method for. An exception response is returned as an *ExceptionError.
+func (c *Client) Send(unit byte, request []byte) ([]byte, error) {
+	if len(request) == 0 || len(request) > kMaxPDU {
+		return nil, ErrInvalidRequest
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if unit == 0 {
+		err := c.transport.send(unit, request)
+		time.Sleep(c.options.TurnaroundDelay)
+		return nil, err
+	}
+
+	var err error
+	for attempt := 0; attempt <= c.options.Retries; attempt++ {
+		if err = c.transport.send(unit, request); err != nil {
+			return nil, err
+		}
+
+		var response []byte
+		response, err = c.await(unit, FunctionCode(request[0]))
+		if err != ErrTimeout {
+			return response, err
+		}
+	}
+
+	return nil, err
+}
+
+// await returns the response from the unit, ignoring frames from others.
+func (c *Client) await(unit byte, function FunctionCode) ([]byte, error) {
+	deadline := time.Now().Add(c.options.Timeout)
+	for {
+		u, pdu, err := c.transport.receive(deadline, responseLength)
+		if err != nil {
+			return nil, err
+		}
+
+		if u != unit {
+			continue
+		}
+
+		switch FunctionCode(pdu[0]) {
+		case function:
+			return pdu, nil
+
+		case function | 0x80:
+			if len(pdu) != 2 {
+				return nil, ErrInvalidResponse
+			}
+
+			return nil, &ExceptionError{Unit: unit, Function: function, Code: ExceptionCode(pdu[1])}
+		}
+	}
+}
+
+// readBits implements the functions reading coils and discrete inputs.
+func (c *Client) readBits(unit byte, function FunctionCode, address, quantity uint16) ([]bool, error) {
+	if unit == 0 || quantity < 1 || quantity > kMaxReadBits {
+		return nil, ErrInvalidRequest
+	}
+
+	response, err := c.Send(unit, appendUint16([]byte{byte(function)}, address, quantity))
+	if err != nil {
+		return nil, err
+	}
+
+	count := (int(quantity) + 7) / 8
+	if len(response) != 2+count || int(response[1]) != count {
+		return nil, ErrInvalidResponse
+	}
+
+	return unpackBits(response[2:], int(quantity)), nil
+}
+
+// readRegisters implements the functions reading holding and input
+// registers.
+func (c *Client) readRegisters(unit byte, function FunctionCode, address, quantity uint16) ([]uint16, error) {
+	if unit == 0 || quantity < 1 || quantity > kMaxReadRegisters {
+		return nil, ErrInvalidRequest
+	}
+
+	response, err := c.Send(unit, appendUint16([]byte{byte(function)}, address, quantity))
+	if err != nil {
+		return nil, err
+	}
+
+	return registersFromResponse(response, quantity)
+}
+
+// registersFromResponse returns the values in the response to a read of
+// registers.
+func registersFromResponse(response []byte, quantity uint16) ([]uint16, error) {
+	if len(response) != 2+2*int(quantity) || int(response[1]) != 2*int(quantity) {
+		return nil, ErrInvalidResponse
+	}
+
+	values := make([]uint16, quantity)
+	for i := range values {
+		values[i] = getUint16(response, 2+2*i)
+	}
+
+	return values, nil
+}
+
+// ReadCoils reads quantity coils starting at address.
+func (c *Client) ReadCoils(unit byte, address, quantity uint16) ([]bool, error) {
+	return c.readBits(unit, FUNCTION_READ_COILS, address, quantity)
+}
+
+// ReadDiscreteInputs reads quantity discrete inputs starting at address.
+func (c *Client) ReadDiscreteInputs(unit byte, address, quantity uint16) ([]bool, error) {
+	return c.readBits(unit, FUNCTION_READ_DISCRETE_INPUTS, address, quantity)
+}
+
+// ReadHoldingRegisters reads quantity holding registers starting at address.
+func (c *Client) ReadHoldingRegisters(unit byte, address, quantity uint16) ([]uint16, error) {
+	return c.readRegisters(unit, FUNCTION_READ_HOLDING_REGISTERS, address, quantity)
+}
+
+// ReadInputRegisters reads quantity input registers starting at address.
+func (c *Client) ReadInputRegisters(unit byte, address, quantity uint16) ([]uint16, error) {
+	return c.readRegisters(unit, FUNCTION_READ_INPUT_REGISTERS, address, quantity)
+}
+
+// writeSingle implements the functions writing a single coil or register,
+// whose responses echo their requests.
+func (c *Client) writeSingle(unit byte, function FunctionCode, address, value uint16) error {
+	request := appendUint16([]byte{byte(function)}, address, value)
+	response, err := c.Send(unit, request)
+	if err != nil || unit == 0 {
+		return err
+	}
+
+	if !bytes.Equal(response, request) {
+		return ErrInvalidResponse
+	}
+
+	return nil
+}
+
+// WriteSingleCoil turns the coil at address on or off.
+func (c *Client) WriteSingleCoil(unit byte, address uint16, value bool) error {
+	var v uint16
+	if value {
+		v = 0xff00
+	}
+
+	return c.writeSingle(unit, FUNCTION_WRITE_SINGLE_COIL, address, v)
+}
+
+// WriteSingleRegister sets the holding register at address.
+func (c *Client) WriteSingleRegister(unit byte, address, value uint16) error {
+	return c.writeSingle(unit, FUNCTION_WRITE_SINGLE_REGISTER, address, value)
+}
+
+// writeMultiple implements the functions writing multiple coils or
+// registers, whose responses echo the address and quantity.
+func (c *Client) writeMultiple(unit byte, function FunctionCode, address uint16, quantity int, data []byte) error {
+	request := appendUint16([]byte{byte(function)}, address, uint16(quantity))
+	request = append(request, byte(len(data)))
+	request = append(request, data...)
+
+	response, err := c.Send(unit, request)
+	if err != nil || unit == 0 {
+		return err
+	}
+
+	if !bytes.Equal(response, request[:5]) {
+		return ErrInvalidResponse
+	}
+
+	return nil
+}
+
+// WriteMultipleCoils sets the coils starting at address.
+func (c *Client) WriteMultipleCoils(unit byte, address uint16, values []bool) error {
+	if len(values) < 1 || len(values) > kMaxWriteBits {
+		return ErrInvalidRequest
+	}
+
+	return c.writeMultiple(unit, FUNCTION_WRITE_MULTIPLE_COILS, address, len(values), packBits(values))
+}
+
+// WriteMultipleRegisters sets the holding registers starting at address.
+func (c *Client) WriteMultipleRegisters(unit byte, address uint16, values []uint16) error {
+	if len(values) < 1 || len(values) > kMaxWriteRegisters {
+		return ErrInvalidRequest
+	}
+
+	return c.writeMultiple(unit, FUNCTION_WRITE_MULTIPLE_REGISTERS, address, len(values), appendUint16(nil, values...))
+}
+
+// MaskWriteRegister modifies the holding register at address, setting it to
+// (current AND and) OR (or AND NOT and).
+func (c *Client) MaskWriteRegister(unit byte, address, and, or uint16) error {
+	request := appendUint16([]byte{byte(FUNCTION_MASK_WRITE_REGISTER)}, address, and, or)
+	response, err := c.Send(unit, request)
+	if err != nil || unit == 0 {
+		return err
+	}
+
+	if !bytes.Equal(response, request) {
+		return ErrInvalidResponse
+	}
+
+	return nil
+}
+
+// ReadWriteMultipleRegisters sets the holding registers starting at
+// writeAddress, then reads readQuantity holding registers starting at
+// readAddress, in a single request.
+func (c *Client) ReadWriteMultipleRegisters(
+	unit byte,
+	readAddress, readQuantity uint16,
+	writeAddress uint16,
+	values []uint16) ([]uint16, error) {
+	if unit == 0 || readQuantity < 1 || readQuantity > kMaxReadRegisters || len(values) < 1 || len(values) > kMaxReadWriteRegisters {
+		return nil, ErrInvalidRequest
+	}
+
+	request := appendUint16([]byte{byte(FUNCTION_READ_WRITE_MULTIPLE_REGISTERS)}, readAddress, readQuantity, writeAddress, uint16(len(values)))
+	request = append(request, byte(2*len(values)))
+	request = appendUint16(request, values...)
+
+	response, err := c.Send(unit, request)
+	if err != nil {
+		return nil, err
+	}
+
+	return registersFromResponse(response, readQuantity)
+}
+
+// Diagnostics sends a diagnostics request with the given sub-function and
+// data, returning the data of the response. See the DIAGNOSTIC_* constants.
+func (c *Client) Diagnostics(unit byte, subfunction uint16, data []byte) ([]byte, error) {
+	request := appendUint16([]byte{byte(FUNCTION_DIAGNOSTICS)}, subfunction)
+	request = append(request, data...)
+
+	response, err := c.Send(unit, request)
+	if err != nil || unit == 0 {
+		return nil, err
+	}
+
+	if len(response) < 3 || getUint16(response, 1) != subfunction {
+		return nil, ErrInvalidResponse
+	}
+
+	return response[3:], nil
+}
@@ -0,0 +1,130 @@
+// Copyright 2011 Aaron Jacobs. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package modbus
+
+import "sync"
+
+// Handler handles the requests received by a Server for a unit. The Server
+// checks that quantities are within the limits of the protocol before
+// calling it.
+//
+// Methods may return an ExceptionCode, such as
+// EXCEPTION_ILLEGAL_DATA_ADDRESS, to have it sent to the client. Any other
+// error is sent as EXCEPTION_SERVER_DEVICE_FAILURE.
+type Handler interface {
+	ReadCoils(unit byte, address, quantity uint16) ([]bool, error)
+	ReadDiscreteInputs(unit byte, address, quantity uint16) ([]bool, error)
+	ReadHoldingRegisters(unit byte, address, quantity uint16) ([]uint16, error)
+	ReadInputRegisters(unit byte, address, quantity uint16) ([]uint16, error)
+	WriteCoils(unit byte, address uint16, values []bool) error
+	WriteHoldingRegisters(unit byte, address uint16, values []uint16) error
+}
+
+// RegisterMap is a Handler holding the data of a simulated device in memory.
+// Each table begins at address 0, and requests beyond its end fail with
+// EXCEPTION_ILLEGAL_DATA_ADDRESS. It is safe for concurrent use, so that a
+// simulation may update the inputs while the Server reads them.
+type RegisterMap struct {
+	mu               sync.Mutex
+	coils            []bool
+	discreteInputs   []bool
+	holdingRegisters []uint16
+	inputRegisters   []uint16
+}
+
+var _ Handler = &RegisterMap{}
+
+// NewRegisterMap returns a RegisterMap with tables of the given sizes, with
+// every value zero.
+func NewRegisterMap(coils, discreteInputs, holdingRegisters, inputRegisters int) *RegisterMap {
+	return &RegisterMap{
+		coils:            make([]bool, coils),
+		discreteInputs:   make([]bool, discreteInputs),
+		holdingRegisters: make([]uint16, holdingRegisters),
+		inputRegisters:   make([]uint16, inputRegisters),
+	}
+}
+
+// span returns the range of a table addressed by a request, checking that it
+// lies within the table.
+func span(size int, address uint16, quantity int) (int, int, error) {
+	start, end := int(address), int(address)+quantity
+	if end > size {
+		return 0, 0, EXCEPTION_ILLEGAL_DATA_ADDRESS
+	}
+
+	return start, end, nil
+}
+
+func readTable[T any](m *RegisterMap, table []T, address, quantity uint16) ([]T, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	start, end, err := span(len(table), address, int(quantity))
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]T(nil), table[start:end]...), nil
+}
+
+func writeTable[T any](m *RegisterMap, table []T, address uint16, values []T) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	start, _, err := span(len(table), address, len(values))
+	if err != nil {
+		return err
+	}
+
+	copy(table[start:], values)
+	return nil
+}
+
+func (m *RegisterMap) ReadCoils(unit byte, address, quantity uint16) ([]bool, error) {
+	return readTable(m, m.coils, address, quantity)
+}
+
+func (m *RegisterMap) ReadDiscreteInputs(unit byte, address, quantity uint16) ([]bool, error) {
+	return readTable(m, m.discreteInputs, address, quantity)
+}
+
+func (m *RegisterMap) ReadHoldingRegisters(unit byte, address, quantity uint16) ([]uint16, error) {
+	return readTable(m, m.holdingRegisters, address, quantity)
+}
+
+func (m *RegisterMap) ReadInputRegisters(unit byte, address, quantity uint16) ([]uint16, error) {
+	return readTable(m, m.inputRegisters, address, quantity)
+}
+
+func (m *RegisterMap) WriteCoils(unit byte, address uint16, values []bool) error {
+	return writeTable(m, m.coils, address, values)
+}
+
+func (m *RegisterMap) WriteHoldingRegisters(unit byte, address uint16, values []uint16) error {
+	return writeTable(m, m.holdingRegisters, address, values)
+}
+
+// SetDiscreteInputs sets discrete inputs starting at address, which clients
+// can only read.
+func (m *RegisterMap) SetDiscreteInputs(address uint16, values ...bool) error {
+	return writeTable(m, m.discreteInputs, address, values)
+}
+
+// SetInputRegisters sets input registers starting at address, which clients
+// can only read.
+func (m *RegisterMap) SetInputRegisters(address uint16, values ...uint16) error {
+	return writeTable(m, m.inputRegisters, address, values)
+}
@@ -0,0 +1,171 @@
+// Copyright 2011 Aaron Jacobs. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package modbus implements the Modbus serial line protocol, for talking to
+// PLCs and other field devices over ports returned by serial.Open.
+//
+// A Client is the master of a serial line, sending requests to the devices
+// on it and waiting for their responses. A Server answers requests as one or
+// more devices would, for simulating them, passing them to a Handler such as
+// a RegisterMap. Both work in either of the transmission modes described
+// here:
+//
+//	https://modbus.org/docs/Modbus_over_serial_line_V1_02.pdf
+//
+// RTU mode sends binary frames separated by silences and ending with a
+// CRC-16. ASCII mode sends frames as hexadecimal text, from a colon to CR LF,
+// ending with an LRC; devices using it usually expect seven data bits.
+//
+// The port must be opened with serial.OpenOptions.ReadTimeout set, so that
+// request timeouts can be enforced and Serve can return once the port is
+// closed. In RTU mode a timeout of a few milliseconds also lets the end of
+// frames of unknown length be noticed promptly. The older
+// InterCharacterTimeout will not do: on Linux a read that times out with it
+// returns io.EOF, which ends requests with an error.
+package modbus
+
+import (
+	"errors"
+	"fmt"
+)
+
+// FunctionCode identifies the operation requested of a device.
+type FunctionCode byte
+
+const (
+	FUNCTION_READ_COILS                    FunctionCode = 0x01
+	FUNCTION_READ_DISCRETE_INPUTS          FunctionCode = 0x02
+	FUNCTION_READ_HOLDING_REGISTERS        FunctionCode = 0x03
+	FUNCTION_READ_INPUT_REGISTERS          FunctionCode = 0x04
+	FUNCTION_WRITE_SINGLE_COIL             FunctionCode = 0x05
+	FUNCTION_WRITE_SINGLE_REGISTER         FunctionCode = 0x06
+	FUNCTION_READ_EXCEPTION_STATUS         FunctionCode = 0x07
+	FUNCTION_DIAGNOSTICS                   FunctionCode = 0x08
+	FUNCTION_WRITE_MULTIPLE_COILS          FunctionCode = 0x0f
+	FUNCTION_WRITE_MULTIPLE_REGISTERS      FunctionCode = 0x10
+	FUNCTION_MASK_WRITE_REGISTER           FunctionCode = 0x16
+	FUNCTION_READ_WRITE_MULTIPLE_REGISTERS FunctionCode = 0x17
+)
+
+var functionNames = map[FunctionCode]string{
+	FUNCTION_READ_COILS:                    "read coils",
+	FUNCTION_READ_DISCRETE_INPUTS:          "read discrete inputs",
+	FUNCTION_READ_HOLDING_REGISTERS:        "read holding registers",
+	FUNCTION_READ_INPUT_REGISTERS:          "read input registers",
+	FUNCTION_WRITE_SINGLE_COIL:             "write single coil",
+	FUNCTION_WRITE_SINGLE_REGISTER:         "write single register",
+	FUNCTION_READ_EXCEPTION_STATUS:         "read exception status",
+	FUNCTION_DIAGNOSTICS:                   "diagnostics",
+	FUNCTION_WRITE_MULTIPLE_COILS:          "write multiple coils",
+	FUNCTION_WRITE_MULTIPLE_REGISTERS:      "write multiple registers",
+	FUNCTION_MASK_WRITE_REGISTER:           "mask write register",
+	FUNCTION_READ_WRITE_MULTIPLE_REGISTERS: "read/write multiple registers",
+}
+
+func (f FunctionCode) String() string {
+	if name, ok := functionNames[f]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("function %#02x", byte(f))
+}
+
+// Sub-functions of FUNCTION_DIAGNOSTICS.
+const (
+	DIAGNOSTIC_RETURN_QUERY_DATA           uint16 = 0x00
+	DIAGNOSTIC_RESTART_COMMUNICATIONS      uint16 = 0x01
+	DIAGNOSTIC_RETURN_DIAGNOSTIC_REGISTER  uint16 = 0x02
+	DIAGNOSTIC_FORCE_LISTEN_ONLY_MODE      uint16 = 0x04
+	DIAGNOSTIC_CLEAR_COUNTERS              uint16 = 0x0a
+	DIAGNOSTIC_RETURN_BUS_MESSAGE_COUNT    uint16 = 0x0b
+	DIAGNOSTIC_RETURN_BUS_ERROR_COUNT      uint16 = 0x0c
+	DIAGNOSTIC_RETURN_BUS_EXCEPTION_COUNT  uint16 = 0x0d
+	DIAGNOSTIC_RETURN_SERVER_MESSAGE_COUNT uint16 = 0x0e
+	DIAGNOSTIC_RETURN_SERVER_NO_RESPONSE   uint16 = 0x0f
+	DIAGNOSTIC_RETURN_SERVER_NAK_COUNT     uint16 = 0x10
+	DIAGNOSTIC_RETURN_SERVER_BUSY_COUNT    uint16 = 0x11
+	DIAGNOSTIC_RETURN_BUS_OVERRUN_COUNT    uint16 = 0x12
+)
+
+// ExceptionCode is the reason given by a device for rejecting a request. It
+// implements error, so that a Handler can return one to have the Server send
+// it, and so that errors returned by Client can be tested with errors.Is.
+type ExceptionCode byte
+
+const (
+	EXCEPTION_ILLEGAL_FUNCTION                        ExceptionCode = 0x01
+	EXCEPTION_ILLEGAL_DATA_ADDRESS                    ExceptionCode = 0x02
+	EXCEPTION_ILLEGAL_DATA_VALUE                      ExceptionCode = 0x03
+	EXCEPTION_SERVER_DEVICE_FAILURE                   ExceptionCode = 0x04
+	EXCEPTION_ACKNOWLEDGE                             ExceptionCode = 0x05
+	EXCEPTION_SERVER_DEVICE_BUSY                      ExceptionCode = 0x06
+	EXCEPTION_MEMORY_PARITY_ERROR                     ExceptionCode = 0x08
+	EXCEPTION_GATEWAY_PATH_UNAVAILABLE                ExceptionCode = 0x0a
+	EXCEPTION_GATEWAY_TARGET_DEVICE_FAILED_TO_RESPOND ExceptionCode = 0x0b
+)
+
+var exceptionNames = map[ExceptionCode]string{
+	EXCEPTION_ILLEGAL_FUNCTION:                        "illegal function",
+	EXCEPTION_ILLEGAL_DATA_ADDRESS:                    "illegal data address",
+	EXCEPTION_ILLEGAL_DATA_VALUE:                      "illegal data value",
+	EXCEPTION_SERVER_DEVICE_FAILURE:                   "server device failure",
+	EXCEPTION_ACKNOWLEDGE:                             "acknowledge",
+	EXCEPTION_SERVER_DEVICE_BUSY:                      "server device busy",
+	EXCEPTION_MEMORY_PARITY_ERROR:                     "memory parity error",
+	EXCEPTION_GATEWAY_PATH_UNAVAILABLE:                "gateway path unavailable",
+	EXCEPTION_GATEWAY_TARGET_DEVICE_FAILED_TO_RESPOND: "gateway target device failed to respond",
+}
+
+func (e ExceptionCode) String() string {
+	if name, ok := exceptionNames[e]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("exception %#02x", byte(e))
+}
+
+func (e ExceptionCode) Error() string {
+	return e.String()
+}
+
+// ExceptionError is returned by Client when a device responds to a request
+// with an exception.
+type ExceptionError struct {
+	Unit     byte
+	Function FunctionCode
+	Code     ExceptionCode
+}
+
+func (e *ExceptionError) Error() string {
+	return fmt.Sprintf("unit %d: %v: %v", e.Unit, e.Function, e.Code)
+}
+
+// Unwrap returns Code.
+func (e *ExceptionError) Unwrap() error {
+	return e.Code
+}
+
+// Errors returned by Client.
+var (
+	// No valid response arrived within ClientOptions.Timeout, after any
+	// retries.
+	ErrTimeout = errors.New("timed out waiting for response")
+
+	// The response did not match the request.
+	ErrInvalidResponse = errors.New("invalid response")
+
+	// An address, quantity or value outside the range allowed by the
+	// protocol.
+	ErrInvalidRequest = errors.New("invalid request")
+)
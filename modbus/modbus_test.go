@@ -0,0 +1,359 @@
+package modbus
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// rtuFrame returns the RTU frame for a PDU.
+func rtuFrame(unit byte, pdu ...byte) []byte {
+	frame := append([]byte{unit}, pdu...)
+	sum := modbusCRC.Checksum(frame)
+	return append(frame, byte(sum), byte(sum>>8))
+}
+
+// fakeLine is a serial line to a scripted device. Reads return no data
+// after a millisecond if nothing is waiting, as ports opened with a read
+// timeout do.
+type fakeLine struct {
+	mu      sync.Mutex
+	written [][]byte
+	pending [][]byte
+
+	// Returns the chunks the device sends in reply to a frame.
+	respond func(frame []byte) [][]byte
+}
+
+func (l *fakeLine) Write(b []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.written = append(l.written, append([]byte(nil), b...))
+	if l.respond != nil {
+		l.pending = append(l.pending, l.respond(b)...)
+	}
+
+	return len(b), nil
+}
+
+func (l *fakeLine) Read(b []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.pending) == 0 {
+		l.mu.Unlock()
+		time.Sleep(time.Millisecond)
+		l.mu.Lock()
+		return 0, nil
+	}
+
+	// An empty chunk stands for a pause longer than the frame gap.
+	if len(l.pending[0]) == 0 {
+		l.pending = l.pending[1:]
+		l.mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		l.mu.Lock()
+		return 0, nil
+	}
+
+	n := copy(b, l.pending[0])
+	l.pending[0] = l.pending[0][n:]
+	if len(l.pending[0]) == 0 {
+		l.pending = l.pending[1:]
+	}
+
+	return n, nil
+}
+
+// reply returns a respond function that always sends the given chunks.
+func reply(chunks ...[]byte) func([]byte) [][]byte {
+	return func([]byte) [][]byte { return chunks }
+}
+
+func TestFrameGap(t *testing.T) {
+	testCases := []struct {
+		BaudRate uint
+		Expected time.Duration
+	}{
+		{9600, 4010416 * time.Nanosecond},
+		{19200, 2005208 * time.Nanosecond},
+		{38400, 1750 * time.Microsecond},
+		{0, 1750 * time.Microsecond},
+	}
+
+	for _, testCase := range testCases {
+		if got := FrameGap(testCase.BaudRate); got != testCase.Expected {
+			t.Errorf("%d: expected %v, got %v", testCase.BaudRate, testCase.Expected, got)
+		}
+	}
+}
+
+func TestPDULengths(t *testing.T) {
+	testCases := []struct {
+		PDU      []byte
+		Request  int
+		Response int
+	}{
+		{[]byte{}, 0, 0},
+		{[]byte{0x03}, 5, 0},
+		{[]byte{0x03, 0x06}, 5, 8},
+		{[]byte{0x83}, kUnknownLength, 2},
+		{[]byte{0x05}, 5, 5},
+		{[]byte{0x0f, 0x00, 0x13, 0x00, 0x0a}, 0, 5},
+		{[]byte{0x0f, 0x00, 0x13, 0x00, 0x0a, 0x02}, 8, 5},
+		{[]byte{0x10, 0x00, 0x01, 0x00, 0x02, 0x04}, 10, 5},
+		{[]byte{0x17, 0x00, 0x03, 0x00, 0x06, 0x00, 0x0e, 0x00, 0x03, 0x06}, 16, 2},
+		{[]byte{0x07}, 1, 2},
+		{[]byte{0x08, 0x00}, 0, 0},
+		{[]byte{0x08, 0x00, 0x00}, kUnknownLength, kUnknownLength},
+		{[]byte{0x08, 0x00, 0x0b}, 5, 5},
+		{[]byte{0x2b}, kUnknownLength, kUnknownLength},
+	}
+
+	for _, testCase := range testCases {
+		if got := requestLength(testCase.PDU); got != testCase.Request {
+			t.Errorf("% x: expected request length %d, got %d", testCase.PDU, testCase.Request, got)
+		}
+
+		if got := responseLength(testCase.PDU); got != testCase.Response {
+			t.Errorf("% x: expected response length %d, got %d", testCase.PDU, testCase.Response, got)
+		}
+	}
+}
+
+func TestClientFunctions(t *testing.T) {
+	testCases := []struct {
+		Name     string
+		Call     func(c *Client) (interface{}, error)
+		Request  []byte
+		Response []byte
+		Expected string
+	}{
+		// The examples from the protocol specification.
+		{
+			"read coils",
+			func(c *Client) (interface{}, error) { return c.ReadCoils(17, 19, 19) },
+			[]byte{0x01, 0x00, 0x13, 0x00, 0x13},
+			[]byte{0x01, 0x03, 0xcd, 0x6b, 0x05},
+			"[true false true true false false true true true true false true false true true false true false true]",
+		},
+		{
+			"read discrete inputs",
+			func(c *Client) (interface{}, error) { return c.ReadDiscreteInputs(17, 196, 3) },
+			[]byte{0x02, 0x00, 0xc4, 0x00, 0x03},
+			[]byte{0x02, 0x01, 0x05},
+			"[true false true]",
+		},
+		{
+			"read holding registers",
+			func(c *Client) (interface{}, error) { return c.ReadHoldingRegisters(17, 107, 3) },
+			[]byte{0x03, 0x00, 0x6b, 0x00, 0x03},
+			[]byte{0x03, 0x06, 0x02, 0x2b, 0x00, 0x00, 0x00, 0x64},
+			"[555 0 100]",
+		},
+		{
+			"read input registers",
+			func(c *Client) (interface{}, error) { return c.ReadInputRegisters(17, 8, 1) },
+			[]byte{0x04, 0x00, 0x08, 0x00, 0x01},
+			[]byte{0x04, 0x02, 0x00, 0x0a},
+			"[10]",
+		},
+		{
+			"write single coil",
+			func(c *Client) (interface{}, error) { return nil, c.WriteSingleCoil(17, 172, true) },
+			[]byte{0x05, 0x00, 0xac, 0xff, 0x00},
+			[]byte{0x05, 0x00, 0xac, 0xff, 0x00},
+			"<nil>",
+		},
+		{
+			"write single register",
+			func(c *Client) (interface{}, error) { return nil, c.WriteSingleRegister(17, 1, 3) },
+			[]byte{0x06, 0x00, 0x01, 0x00, 0x03},
+			[]byte{0x06, 0x00, 0x01, 0x00, 0x03},
+			"<nil>",
+		},
+		{
+			"write multiple coils",
+			func(c *Client) (interface{}, error) {
+				return nil, c.WriteMultipleCoils(17, 19, []bool{true, false, true, true, false, false, true, true, true, false})
+			},
+			[]byte{0x0f, 0x00, 0x13, 0x00, 0x0a, 0x02, 0xcd, 0x01},
+			[]byte{0x0f, 0x00, 0x13, 0x00, 0x0a},
+			"<nil>",
+		},
+		{
+			"write multiple registers",
+			func(c *Client) (interface{}, error) {
+				return nil, c.WriteMultipleRegisters(17, 1, []uint16{0x000a, 0x0102})
+			},
+			[]byte{0x10, 0x00, 0x01, 0x00, 0x02, 0x04, 0x00, 0x0a, 0x01, 0x02},
+			[]byte{0x10, 0x00, 0x01, 0x00, 0x02},
+			"<nil>",
+		},
+		{
+			"diagnostics",
+			func(c *Client) (interface{}, error) {
+				return c.Diagnostics(17, DIAGNOSTIC_RETURN_QUERY_DATA, []byte{0xa5, 0x37})
+			},
+			[]byte{0x08, 0x00, 0x00, 0xa5, 0x37},
+			[]byte{0x08, 0x00, 0x00, 0xa5, 0x37},
+			"[165 55]",
+		},
+		{
+			"mismatched echo",
+			func(c *Client) (interface{}, error) { return nil, c.WriteSingleRegister(17, 1, 3) },
+			[]byte{0x06, 0x00, 0x01, 0x00, 0x03},
+			[]byte{0x06, 0x00, 0x01, 0x00, 0x04},
+			ErrInvalidResponse.Error(),
+		},
+		{
+			"short read",
+			func(c *Client) (interface{}, error) { return c.ReadHoldingRegisters(17, 107, 3) },
+			[]byte{0x03, 0x00, 0x6b, 0x00, 0x03},
+			[]byte{0x03, 0x02, 0x02, 0x2b},
+			ErrInvalidResponse.Error(),
+		},
+	}
+
+	for _, testCase := range testCases {
+		line := &fakeLine{respond: reply(rtuFrame(17, testCase.Response...))}
+		c := NewRTUClient(line, ClientOptions{BaudRate: 115200, Timeout: 100 * time.Millisecond})
+
+		result, err := testCase.Call(c)
+		got := "<nil>"
+		if err != nil {
+			got = err.Error()
+		} else if result != nil {
+			got = fmt.Sprint(result)
+		}
+
+		if got != testCase.Expected {
+			t.Errorf("%s: expected %s, got %s", testCase.Name, testCase.Expected, got)
+		}
+
+		if len(line.written) != 1 || !bytes.Equal(line.written[0], rtuFrame(17, testCase.Request...)) {
+			t.Errorf("%s: expected request % x, got % x", testCase.Name, rtuFrame(17, testCase.Request...), line.written)
+		}
+	}
+}
+
+func TestRequestFrame(t *testing.T) {
+	// The CRC is sent low byte first.
+	if got := rtuFrame(17, 0x03, 0x00, 0x6b, 0x00, 0x03); !bytes.Equal(got, []byte{0x11, 0x03, 0x00, 0x6b, 0x00, 0x03, 0x76, 0x87}) {
+		t.Errorf("unexpected frame % x", got)
+	}
+}
+
+func TestClientException(t *testing.T) {
+	line := &fakeLine{respond: reply(rtuFrame(17, 0x83, 0x02))}
+	c := NewRTUClient(line, ClientOptions{Retries: 3})
+
+	_, err := c.ReadHoldingRegisters(17, 0, 1)
+	var exception *ExceptionError
+	if !errors.As(err, &exception) || *exception != (ExceptionError{17, FUNCTION_READ_HOLDING_REGISTERS, EXCEPTION_ILLEGAL_DATA_ADDRESS}) {
+		t.Fatalf("expected exception, got %v", err)
+	}
+
+	if err.Error() != "unit 17: read holding registers: illegal data address" {
+		t.Errorf("unexpected message %q", err.Error())
+	}
+
+	// Exceptions aren't retried.
+	if len(line.written) != 1 {
+		t.Errorf("expected 1 request, got %d", len(line.written))
+	}
+}
+
+func TestClientRetries(t *testing.T) {
+	response := rtuFrame(17, 0x06, 0x00, 0x01, 0x00, 0x03)
+	corrupt := append([]byte(nil), response...)
+	corrupt[3] ^= 0x01
+
+	// The device's first reply is corrupt and its second comes from another
+	// unit, so only the third request gets a response.
+	var requests int
+	line := &fakeLine{respond: func([]byte) [][]byte {
+		requests++
+		switch requests {
+		case 1:
+			return [][]byte{corrupt}
+		case 2:
+			return [][]byte{rtuFrame(18, 0x06, 0x00, 0x01, 0x00, 0x03)}
+		}
+
+		return [][]byte{response}
+	}}
+
+	c := NewRTUClient(line, ClientOptions{Timeout: 30 * time.Millisecond, Retries: 1})
+	if err := c.WriteSingleRegister(17, 1, 3); err != ErrTimeout {
+		t.Errorf("expected ErrTimeout, got %v", err)
+	}
+
+	if err := c.WriteSingleRegister(17, 1, 3); err != nil {
+		t.Errorf("expected success on retry, got %v", err)
+	}
+
+	if requests != 3 {
+		t.Errorf("expected 3 requests, got %d", requests)
+	}
+}
+
+func TestClientFraming(t *testing.T) {
+	response := rtuFrame(17, 0x03, 0x02, 0x12, 0x34)
+
+	// Leftovers from an earlier frame and a response split across reads.
+	line := &fakeLine{respond: reply([]byte{0x01, 0x02}, nil, response[:3], response[3:])}
+	c := NewRTUClient(line, ClientOptions{BaudRate: 115200, Timeout: 100 * time.Millisecond})
+
+	values, err := c.ReadHoldingRegisters(17, 0, 1)
+	if err != nil || len(values) != 1 || values[0] != 0x1234 {
+		t.Errorf("expected [4660], nil; got %v, %v", values, err)
+	}
+
+	// A response of unknown length ends at the gap.
+	echo := rtuFrame(17, 0x08, 0x00, 0x00, 0x01, 0x02, 0x03)
+	line.respond = reply(echo[:4], echo[4:], nil)
+	data, err := c.Diagnostics(17, DIAGNOSTIC_RETURN_QUERY_DATA, []byte{0x01, 0x02, 0x03})
+	if err != nil || !bytes.Equal(data, []byte{0x01, 0x02, 0x03}) {
+		t.Errorf("expected 01 02 03, got % x, %v", data, err)
+	}
+}
+
+func TestClientBroadcast(t *testing.T) {
+	line := &fakeLine{}
+	c := NewRTUClient(line, ClientOptions{TurnaroundDelay: 20 * time.Millisecond})
+
+	start := time.Now()
+	if err := c.WriteSingleRegister(0, 1, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("broadcast returned after %v", elapsed)
+	}
+
+	if _, err := c.ReadHoldingRegisters(0, 1, 1); err != ErrInvalidRequest {
+		t.Errorf("expected ErrInvalidRequest for broadcast read, got %v", err)
+	}
+}
+
+func TestClientInvalidRequests(t *testing.T) {
+	c := NewRTUClient(&fakeLine{}, ClientOptions{})
+
+	for name, err := range map[string]error{
+		"no coils":       func() error { _, err := c.ReadCoils(1, 0, 0); return err }(),
+		"2001 coils":     func() error { _, err := c.ReadCoils(1, 0, 2001); return err }(),
+		"126 registers":  func() error { _, err := c.ReadHoldingRegisters(1, 0, 126); return err }(),
+		"write 124":      c.WriteMultipleRegisters(1, 0, make([]uint16, 124)),
+		"write no coils": c.WriteMultipleCoils(1, 0, nil),
+		"empty PDU":      func() error { _, err := c.Send(1, nil); return err }(),
+	} {
+		if err != ErrInvalidRequest {
+			t.Errorf("%s: expected ErrInvalidRequest, got %v", name, err)
+		}
+	}
+}
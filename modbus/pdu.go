@@ -0,0 +1,169 @@
+// Copyright 2011 Aaron Jacobs. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package modbus
+
+// Limits on the quantities in a request, from the protocol specification.
+const (
+	kMaxReadBits       = 2000
+	kMaxReadRegisters  = 125
+	kMaxWriteBits      = 1968
+	kMaxWriteRegisters = 123
+
+	// The most registers written by read/write multiple registers.
+	kMaxReadWriteRegisters = 121
+
+	// The largest PDU that fits in a serial line frame.
+	kMaxPDU = 253
+)
+
+// The result of a length function when the length of a PDU cannot be
+// determined from its first bytes.
+const kUnknownLength = -1
+
+// responseLength returns the length of the response PDU beginning with pdu,
+// 0 if more bytes are needed to tell, or kUnknownLength.
+func responseLength(pdu []byte) int {
+	if len(pdu) < 1 {
+		return 0
+	}
+
+	function := FunctionCode(pdu[0])
+	switch {
+	case function&0x80 != 0:
+		return 2
+
+	case function == FUNCTION_READ_EXCEPTION_STATUS:
+		return 2
+
+	case function == FUNCTION_WRITE_SINGLE_COIL,
+		function == FUNCTION_WRITE_SINGLE_REGISTER,
+		function == FUNCTION_WRITE_MULTIPLE_COILS,
+		function == FUNCTION_WRITE_MULTIPLE_REGISTERS:
+		return 5
+
+	case function == FUNCTION_MASK_WRITE_REGISTER:
+		return 7
+
+	case function == FUNCTION_READ_COILS,
+		function == FUNCTION_READ_DISCRETE_INPUTS,
+		function == FUNCTION_READ_HOLDING_REGISTERS,
+		function == FUNCTION_READ_INPUT_REGISTERS,
+		function == FUNCTION_READ_WRITE_MULTIPLE_REGISTERS:
+		if len(pdu) < 2 {
+			return 0
+		}
+
+		return 2 + int(pdu[1])
+	}
+
+	return diagnosticLength(pdu)
+}
+
+// requestLength returns the length of the request PDU beginning with pdu, 0
+// if more bytes are needed to tell, or kUnknownLength.
+func requestLength(pdu []byte) int {
+	if len(pdu) < 1 {
+		return 0
+	}
+
+	// The offset of the byte count, if any.
+	countAt := 0
+
+	switch FunctionCode(pdu[0]) {
+	case FUNCTION_READ_EXCEPTION_STATUS:
+		return 1
+
+	case FUNCTION_READ_COILS,
+		FUNCTION_READ_DISCRETE_INPUTS,
+		FUNCTION_READ_HOLDING_REGISTERS,
+		FUNCTION_READ_INPUT_REGISTERS,
+		FUNCTION_WRITE_SINGLE_COIL,
+		FUNCTION_WRITE_SINGLE_REGISTER:
+		return 5
+
+	case FUNCTION_MASK_WRITE_REGISTER:
+		return 7
+
+	case FUNCTION_WRITE_MULTIPLE_COILS, FUNCTION_WRITE_MULTIPLE_REGISTERS:
+		countAt = 5
+
+	case FUNCTION_READ_WRITE_MULTIPLE_REGISTERS:
+		countAt = 9
+
+	default:
+		return diagnosticLength(pdu)
+	}
+
+	if len(pdu) <= countAt {
+		return 0
+	}
+
+	return countAt + 1 + int(pdu[countAt])
+}
+
+// diagnosticLength returns the length of a diagnostics request or response,
+// which is the same for both. Only "return query data" carries data of
+// arbitrary length.
+func diagnosticLength(pdu []byte) int {
+	if FunctionCode(pdu[0]) != FUNCTION_DIAGNOSTICS {
+		return kUnknownLength
+	}
+
+	if len(pdu) < 3 {
+		return 0
+	}
+
+	if uint16(pdu[1])<<8|uint16(pdu[2]) == DIAGNOSTIC_RETURN_QUERY_DATA {
+		return kUnknownLength
+	}
+
+	return 5
+}
+
+// packBits packs bools into bytes, least significant bit first.
+func packBits(values []bool) []byte {
+	b := make([]byte, (len(values)+7)/8)
+	for i, v := range values {
+		if v {
+			b[i/8] |= 1 << (i % 8)
+		}
+	}
+
+	return b
+}
+
+// unpackBits returns the first n bools packed into b.
+func unpackBits(b []byte, n int) []bool {
+	values := make([]bool, n)
+	for i := range values {
+		values[i] = b[i/8]&(1<<(i%8)) != 0
+	}
+
+	return values
+}
+
+// appendUint16 appends v to b, most significant byte first.
+func appendUint16(b []byte, v ...uint16) []byte {
+	for _, x := range v {
+		b = append(b, byte(x>>8), byte(x))
+	}
+
+	return b
+}
+
+// getUint16 returns the big-endian value at b[i:].
+func getUint16(b []byte, i int) uint16 {
+	return uint16(b[i])<<8 | uint16(b[i+1])
+}
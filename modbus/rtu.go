@@ -0,0 +1,188 @@
+// Copyright 2011 Aaron Jacobs. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package modbus
+
+import (
+	"io"
+	"time"
+
+	"github.com/jacobsa/go-serial/crc"
+)
+
+// transport sends and receives the frames of a serial line mode.
+type transport interface {
+	// send writes a frame carrying the PDU for the unit.
+	send(unit byte, pdu []byte) error
+
+	// receive returns the unit and PDU of the next valid frame, discarding
+	// anything else. It returns ErrTimeout if the deadline passes first; a
+	// zero deadline means to wait forever. Given the first bytes of a PDU,
+	// length returns its full length if it can tell, 0 if it needs more
+	// bytes or kUnknownLength.
+	receive(deadline time.Time, length func(pdu []byte) int) (byte, []byte, error)
+}
+
+// The largest RTU frame: an address, a PDU and a CRC.
+const kMaxRTUFrame = 1 + kMaxPDU + 2
+
+var modbusCRC = crc.MakeTable(crc.CRC16_MODBUS)
+
+// FrameGap returns the silence that separates RTU frames at the given baud
+// rate: 3.5 character times, or 1.75ms above 19200 baud as the
+// specification recommends.
+func FrameGap(baudRate uint) time.Duration {
+	if baudRate == 0 || baudRate > 19200 {
+		return 1750 * time.Microsecond
+	}
+
+	return 7 * 11 * time.Second / time.Duration(2*baudRate)
+}
+
+// characterTime returns the time taken to send a character of 11 bits, as
+// Modbus characters are with either parity or two stop bits.
+func characterTime(baudRate uint) time.Duration {
+	if baudRate == 0 {
+		return 0
+	}
+
+	return 11 * time.Second / time.Duration(baudRate)
+}
+
+// rtu is the transport for RTU mode, in which frames are binary, end with a
+// CRC-16 and are separated by silences of at least FrameGap.
+//
+// A frame is taken to end once the length given by its function code has
+// arrived with a valid CRC, so that responses are noticed without waiting
+// for the gap. Otherwise it ends when the gap is seen between reads of the
+// port.
+type rtu struct {
+	port     io.ReadWriter
+	gap      time.Duration
+	charTime time.Duration
+
+	// Data received since the last gap, and when the line was last active:
+	// when data was last received or the last frame sent will have finished
+	// transmission.
+	buf   []byte
+	last  time.Time
+	chunk []byte
+}
+
+func newRTU(port io.ReadWriter, baudRate uint) *rtu {
+	return &rtu{
+		port:     port,
+		gap:      FrameGap(baudRate),
+		charTime: characterTime(baudRate),
+		chunk:    make([]byte, kMaxRTUFrame),
+	}
+}
+
+func (r *rtu) send(unit byte, pdu []byte) error {
+	if wait := time.Until(r.last.Add(r.gap)); wait > 0 {
+		time.Sleep(wait)
+	}
+
+	frame := append([]byte{unit}, pdu...)
+	sum := modbusCRC.Checksum(frame)
+	frame = append(frame, byte(sum), byte(sum>>8))
+
+	// Anything received before the request can't be a response to it.
+	r.buf = r.buf[:0]
+
+	n, err := r.port.Write(frame)
+	if err == nil && n < len(frame) {
+		err = io.ErrShortWrite
+	}
+
+	r.last = time.Now().Add(time.Duration(len(frame)) * r.charTime)
+	return err
+}
+
+func (r *rtu) receive(deadline time.Time, length func(pdu []byte) int) (byte, []byte, error) {
+	for {
+		if unit, pdu, ok := r.knownLength(length); ok {
+			return unit, pdu, nil
+		}
+
+		if !deadline.IsZero() && !time.Now().Before(deadline) {
+			r.buf = r.buf[:0]
+			return 0, nil, ErrTimeout
+		}
+
+		n, err := r.port.Read(r.chunk)
+		now := time.Now()
+
+		// A gap ends whatever came before it, which may be a frame whose
+		// length couldn't be determined.
+		if len(r.buf) > 0 && now.Sub(r.last) >= r.gap {
+			frame := r.buf
+			r.buf = append([]byte(nil), r.chunk[:n]...)
+			if n > 0 {
+				r.last = now
+			}
+
+			if unit, pdu, ok := parseRTU(frame); ok {
+				return unit, pdu, nil
+			}
+		} else if n > 0 {
+			r.buf = append(r.buf, r.chunk[:n]...)
+			r.last = now
+		}
+
+		// Nothing valid is this long; drop it and wait for the next gap.
+		if len(r.buf) > kMaxRTUFrame {
+			r.buf = r.buf[:0]
+		}
+
+		if err != nil {
+			return 0, nil, err
+		}
+	}
+}
+
+// knownLength returns the frame at the start of the buffer if its length
+// can be determined from its function code and it has a valid CRC.
+func (r *rtu) knownLength(length func(pdu []byte) int) (byte, []byte, bool) {
+	if len(r.buf) < 2 {
+		return 0, nil, false
+	}
+
+	n := length(r.buf[1:])
+	if n <= 0 || len(r.buf) < 1+n+2 {
+		return 0, nil, false
+	}
+
+	unit, pdu, ok := parseRTU(r.buf[:1+n+2])
+	if ok {
+		r.buf = r.buf[:copy(r.buf, r.buf[1+n+2:])]
+	}
+
+	return unit, pdu, ok
+}
+
+// parseRTU returns the address and PDU of a frame, if its CRC is valid.
+func parseRTU(frame []byte) (byte, []byte, bool) {
+	if len(frame) < 4 {
+		return 0, nil, false
+	}
+
+	body := frame[:len(frame)-2]
+	sum := modbusCRC.Checksum(body)
+	if frame[len(frame)-2] != byte(sum) || frame[len(frame)-1] != byte(sum>>8) {
+		return 0, nil, false
+	}
+
+	return frame[0], append([]byte(nil), body[1:]...), true
+}
@@ -0,0 +1,362 @@
+// Copyright 2011 Aaron Jacobs. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package modbus
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// ServerOptions configures a Server.
+type ServerOptions struct {
+	// The baud rate the port was opened with, i.e. OpenOptions.BaudRate,
+	// which determines the gap between RTU frames. Zero is treated as a rate
+	// above 19200 baud. Not used in ASCII mode.
+	BaudRate uint
+}
+
+// Server is a Modbus slave, answering requests for one or more units with
+// the Handler registered for each. It implements the read and write
+// functions, mask write register, read/write multiple registers and the
+// diagnostics sub-functions returning query data and the message counters.
+// Anything else is answered with EXCEPTION_ILLEGAL_FUNCTION.
+//
+// Requests broadcast to unit 0 are passed to every handler, and not
+// answered.
+type Server struct {
+	transport transport
+
+	mu       sync.Mutex
+	handlers map[byte]Handler
+
+	// The counters returned by the diagnostics function, which are only
+	// touched by Serve.
+	busMessages    uint16
+	serverMessages uint16
+	exceptions     uint16
+	noResponses    uint16
+}
+
+// NewRTUServer returns a server answering requests in RTU mode on the port.
+// Register handlers with Handle, then call Serve.
+func NewRTUServer(port io.ReadWriter, options ServerOptions) *Server {
+	return newServer(newRTU(port, options.BaudRate))
+}
+
+// NewASCIIServer returns a server answering requests in ASCII mode on the
+// port. ServerOptions.BaudRate is not needed.
+func NewASCIIServer(port io.ReadWriter, options ServerOptions) *Server {
+	return newServer(newASCII(port))
+}
+
+func newServer(t transport) *Server {
+	return &Server{
+		transport: t,
+		handlers:  make(map[byte]Handler),
+	}
+}
+
+// Handle registers the handler for requests to the unit, replacing any
+// previous handler. A nil handler stops the server answering for the unit.
+func (s *Server) Handle(unit byte, h Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if h == nil {
+		delete(s.handlers, unit)
+	} else {
+		s.handlers[unit] = h
+	}
+}
+
+// Serve answers requests until reading from or writing to the port fails,
+// as it does once the port is closed, and returns that error.
+func (s *Server) Serve() error {
+	for {
+		unit, request, err := s.transport.receive(time.Time{}, requestLength)
+		if err != nil {
+			return err
+		}
+
+		s.busMessages++
+
+		s.mu.Lock()
+		var handlers []Handler
+		if unit == 0 {
+			for _, h := range s.handlers {
+				handlers = append(handlers, h)
+			}
+		} else if h, ok := s.handlers[unit]; ok {
+			handlers = append(handlers, h)
+		}
+		s.mu.Unlock()
+
+		if len(handlers) == 0 {
+			continue
+		}
+
+		s.serverMessages++
+		if unit == 0 {
+			for _, h := range handlers {
+				s.handle(unit, h, request)
+			}
+
+			s.noResponses++
+			continue
+		}
+
+		if err := s.transport.send(unit, s.handle(unit, handlers[0], request)); err != nil {
+			return err
+		}
+	}
+}
+
+// handle returns the response to a request.
+func (s *Server) handle(unit byte, h Handler, request []byte) []byte {
+	function := FunctionCode(request[0])
+	data, err := s.dispatch(unit, h, function, request[1:])
+	if err != nil {
+		var code ExceptionCode
+		if !errors.As(err, &code) {
+			code = EXCEPTION_SERVER_DEVICE_FAILURE
+		}
+
+		s.exceptions++
+		return []byte{byte(function) | 0x80, byte(code)}
+	}
+
+	return append([]byte{byte(function)}, data...)
+}
+
+// dispatch carries out a request, returning the data of the response.
+func (s *Server) dispatch(unit byte, h Handler, function FunctionCode, data []byte) ([]byte, error) {
+	switch function {
+	case FUNCTION_READ_COILS, FUNCTION_READ_DISCRETE_INPUTS:
+		address, quantity, err := addressRange(data, 4, kMaxReadBits)
+		if err != nil {
+			return nil, err
+		}
+
+		read := h.ReadCoils
+		if function == FUNCTION_READ_DISCRETE_INPUTS {
+			read = h.ReadDiscreteInputs
+		}
+
+		values, err := read(unit, address, quantity)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(values) != int(quantity) {
+			return nil, EXCEPTION_SERVER_DEVICE_FAILURE
+		}
+
+		bits := packBits(values)
+		return append([]byte{byte(len(bits))}, bits...), nil
+
+	case FUNCTION_READ_HOLDING_REGISTERS, FUNCTION_READ_INPUT_REGISTERS:
+		address, quantity, err := addressRange(data, 4, kMaxReadRegisters)
+		if err != nil {
+			return nil, err
+		}
+
+		read := h.ReadHoldingRegisters
+		if function == FUNCTION_READ_INPUT_REGISTERS {
+			read = h.ReadInputRegisters
+		}
+
+		return readRegisters(read, unit, address, quantity)
+
+	case FUNCTION_WRITE_SINGLE_COIL:
+		if len(data) != 4 {
+			return nil, EXCEPTION_ILLEGAL_DATA_VALUE
+		}
+
+		value := getUint16(data, 2)
+		if value != 0 && value != 0xff00 {
+			return nil, EXCEPTION_ILLEGAL_DATA_VALUE
+		}
+
+		return data, h.WriteCoils(unit, getUint16(data, 0), []bool{value != 0})
+
+	case FUNCTION_WRITE_SINGLE_REGISTER:
+		if len(data) != 4 {
+			return nil, EXCEPTION_ILLEGAL_DATA_VALUE
+		}
+
+		return data, h.WriteHoldingRegisters(unit, getUint16(data, 0), []uint16{getUint16(data, 2)})
+
+	case FUNCTION_WRITE_MULTIPLE_COILS:
+		address, quantity, err := addressRange(data, -1, kMaxWriteBits)
+		if err != nil {
+			return nil, err
+		}
+
+		count := (int(quantity) + 7) / 8
+		if len(data) != 5+count || int(data[4]) != count {
+			return nil, EXCEPTION_ILLEGAL_DATA_VALUE
+		}
+
+		return data[:4], h.WriteCoils(unit, address, unpackBits(data[5:], int(quantity)))
+
+	case FUNCTION_WRITE_MULTIPLE_REGISTERS:
+		address, quantity, err := addressRange(data, -1, kMaxWriteRegisters)
+		if err != nil {
+			return nil, err
+		}
+
+		values, err := registerValues(data[4:], quantity)
+		if err != nil {
+			return nil, err
+		}
+
+		return data[:4], h.WriteHoldingRegisters(unit, address, values)
+
+	case FUNCTION_MASK_WRITE_REGISTER:
+		if len(data) != 6 {
+			return nil, EXCEPTION_ILLEGAL_DATA_VALUE
+		}
+
+		address := getUint16(data, 0)
+		current, err := h.ReadHoldingRegisters(unit, address, 1)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(current) != 1 {
+			return nil, EXCEPTION_SERVER_DEVICE_FAILURE
+		}
+
+		and, or := getUint16(data, 2), getUint16(data, 4)
+		value := current[0]&and | or&^and
+		return data, h.WriteHoldingRegisters(unit, address, []uint16{value})
+
+	case FUNCTION_READ_WRITE_MULTIPLE_REGISTERS:
+		readAddress, readQuantity, err := addressRange(data, -1, kMaxReadRegisters)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(data) < 9 {
+			return nil, EXCEPTION_ILLEGAL_DATA_VALUE
+		}
+
+		writeAddress, writeQuantity, err := addressRange(data[4:], -1, kMaxReadWriteRegisters)
+		if err != nil {
+			return nil, err
+		}
+
+		values, err := registerValues(data[8:], writeQuantity)
+		if err != nil {
+			return nil, err
+		}
+
+		// The write is performed before the read.
+		if err := h.WriteHoldingRegisters(unit, writeAddress, values); err != nil {
+			return nil, err
+		}
+
+		return readRegisters(h.ReadHoldingRegisters, unit, readAddress, readQuantity)
+
+	case FUNCTION_DIAGNOSTICS:
+		return s.diagnostics(data)
+	}
+
+	return nil, EXCEPTION_ILLEGAL_FUNCTION
+}
+
+// diagnostics answers the diagnostics sub-functions.
+func (s *Server) diagnostics(data []byte) ([]byte, error) {
+	if len(data) < 2 {
+		return nil, EXCEPTION_ILLEGAL_DATA_VALUE
+	}
+
+	subfunction := getUint16(data, 0)
+	counters := map[uint16]uint16{
+		DIAGNOSTIC_RETURN_BUS_MESSAGE_COUNT:    s.busMessages,
+		DIAGNOSTIC_RETURN_BUS_EXCEPTION_COUNT:  s.exceptions,
+		DIAGNOSTIC_RETURN_SERVER_MESSAGE_COUNT: s.serverMessages,
+		DIAGNOSTIC_RETURN_SERVER_NO_RESPONSE:   s.noResponses,
+	}
+
+	switch {
+	case subfunction == DIAGNOSTIC_RETURN_QUERY_DATA:
+		return data, nil
+
+	case subfunction == DIAGNOSTIC_CLEAR_COUNTERS:
+		s.busMessages, s.serverMessages, s.exceptions, s.noResponses = 0, 0, 0, 0
+		return data, nil
+	}
+
+	if count, ok := counters[subfunction]; ok {
+		return appendUint16(nil, subfunction, count), nil
+	}
+
+	return nil, EXCEPTION_ILLEGAL_FUNCTION
+}
+
+// addressRange parses the address and quantity at the start of a request's
+// data, which must be size bytes long (or any length if size is negative),
+// and checks them against the limits of the protocol.
+func addressRange(data []byte, size int, maxQuantity uint16) (uint16, uint16, error) {
+	if len(data) < 4 || size >= 0 && len(data) != size {
+		return 0, 0, EXCEPTION_ILLEGAL_DATA_VALUE
+	}
+
+	address, quantity := getUint16(data, 0), getUint16(data, 2)
+	if quantity < 1 || quantity > maxQuantity {
+		return 0, 0, EXCEPTION_ILLEGAL_DATA_VALUE
+	}
+
+	if int(address)+int(quantity) > 0x10000 {
+		return 0, 0, EXCEPTION_ILLEGAL_DATA_ADDRESS
+	}
+
+	return address, quantity, nil
+}
+
+// registerValues parses the byte count and register values of a write
+// request.
+func registerValues(data []byte, quantity uint16) ([]uint16, error) {
+	if len(data) != 1+2*int(quantity) || int(data[0]) != 2*int(quantity) {
+		return nil, EXCEPTION_ILLEGAL_DATA_VALUE
+	}
+
+	values := make([]uint16, quantity)
+	for i := range values {
+		values[i] = getUint16(data, 1+2*i)
+	}
+
+	return values, nil
+}
+
+// readRegisters returns the response data for a read of registers.
+func readRegisters(
+	read func(unit byte, address, quantity uint16) ([]uint16, error),
+	unit byte,
+	address, quantity uint16) ([]byte, error) {
+	values, err := read(unit, address, quantity)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(values) != int(quantity) {
+		return nil, EXCEPTION_SERVER_DEVICE_FAILURE
+	}
+
+	return appendUint16([]byte{byte(2 * quantity)}, values...), nil
+}
@@ -0,0 +1,128 @@
+package modbus
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/jacobsa/go-serial/internal/ptytest"
+)
+
+func TestClientServer(t *testing.T) {
+	modes := []struct {
+		Name      string
+		DataBits  uint
+		NewClient func(io.ReadWriter, ClientOptions) *Client
+		NewServer func(io.ReadWriter, ServerOptions) *Server
+	}{
+		{"RTU", 8, NewRTUClient, NewRTUServer},
+		{"ASCII", 7, NewASCIIClient, NewASCIIServer},
+	}
+
+	for _, mode := range modes {
+		t.Run(mode.Name, func(t *testing.T) {
+			clientPort, serverPort := ptytest.OpenPair(t, mode.DataBits)
+
+			testClientServer(t,
+				mode.NewClient(clientPort, ClientOptions{
+					BaudRate:        115200,
+					Timeout:         200 * time.Millisecond,
+					TurnaroundDelay: 20 * time.Millisecond,
+				}),
+				mode.NewServer(serverPort, ServerOptions{BaudRate: 115200}),
+				serverPort)
+		})
+	}
+}
+
+func testClientServer(t *testing.T, client *Client, server *Server, serverPort io.Closer) {
+	m1 := NewRegisterMap(20, 8, 10, 4)
+	m2 := NewRegisterMap(0, 0, 2, 0)
+	m1.SetDiscreteInputs(0, true, false, true)
+	m1.SetInputRegisters(2, 1000, 2000)
+
+	server.Handle(1, m1)
+	server.Handle(2, m2)
+
+	served := make(chan error, 1)
+	go func() { served <- server.Serve() }()
+
+	check := func(name string, got interface{}, err error, expected string) {
+		t.Helper()
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+		} else if s := fmt.Sprint(got); s != expected {
+			t.Errorf("%s: expected %s, got %s", name, expected, s)
+		}
+	}
+
+	check("write coils", nil, client.WriteMultipleCoils(1, 3, []bool{true, true, false, true}), "<nil>")
+	check("write coil", nil, client.WriteSingleCoil(1, 19, true), "<nil>")
+	coils, err := client.ReadCoils(1, 0, 20)
+	check("read coils", coils, err, "[false false false true true false true false false false false false false false false false false false false true]")
+
+	inputs, err := client.ReadDiscreteInputs(1, 0, 4)
+	check("read discrete inputs", inputs, err, "[true false true false]")
+
+	check("write registers", nil, client.WriteMultipleRegisters(1, 0, []uint16{10, 20, 30}), "<nil>")
+	check("write register", nil, client.WriteSingleRegister(1, 9, 0xffff), "<nil>")
+	check("mask write", nil, client.MaskWriteRegister(1, 0, 0xfff0, 0x0005), "<nil>")
+	registers, err := client.ReadHoldingRegisters(1, 0, 10)
+	check("read holding registers", registers, err, "[5 20 30 0 0 0 0 0 0 65535]")
+
+	registers, err = client.ReadWriteMultipleRegisters(1, 1, 2, 2, []uint16{99})
+	check("read/write registers", registers, err, "[20 99]")
+
+	registers, err = client.ReadInputRegisters(1, 2, 2)
+	check("read input registers", registers, err, "[1000 2000]")
+
+	data, err := client.Diagnostics(1, DIAGNOSTIC_RETURN_QUERY_DATA, []byte("ping"))
+	check("query data", string(data), err, "ping")
+
+	// Exceptions.
+	_, err = client.ReadHoldingRegisters(1, 8, 5)
+	if !errors.Is(err, EXCEPTION_ILLEGAL_DATA_ADDRESS) {
+		t.Errorf("expected illegal data address, got %v", err)
+	}
+
+	_, err = client.Send(1, []byte{0x2b, 0x0e, 0x01, 0x00})
+	if !errors.Is(err, EXCEPTION_ILLEGAL_FUNCTION) {
+		t.Errorf("expected illegal function, got %v", err)
+	}
+
+	// Units are kept apart, and units without a handler don't answer.
+	check("write unit 2", nil, client.WriteSingleRegister(2, 1, 7), "<nil>")
+	registers, err = client.ReadHoldingRegisters(2, 0, 2)
+	check("read unit 2", registers, err, "[0 7]")
+
+	if _, err := client.ReadHoldingRegisters(3, 0, 1); err != ErrTimeout {
+		t.Errorf("expected ErrTimeout for unit 3, got %v", err)
+	}
+
+	// Broadcasts reach every unit.
+	check("broadcast", nil, client.WriteSingleRegister(0, 0, 42), "<nil>")
+	registers, err = client.ReadHoldingRegisters(1, 0, 1)
+	check("read broadcast 1", registers, err, "[42]")
+	registers, err = client.ReadHoldingRegisters(2, 0, 1)
+	check("read broadcast 2", registers, err, "[42]")
+
+	// The counters include the request being answered.
+	data, err = client.Diagnostics(1, DIAGNOSTIC_RETURN_BUS_EXCEPTION_COUNT, []byte{0, 0})
+	check("exception count", data, err, "[0 2]")
+	data, err = client.Diagnostics(1, DIAGNOSTIC_RETURN_SERVER_NO_RESPONSE, []byte{0, 0})
+	check("no response count", data, err, "[0 1]")
+
+	// Closing the port stops the server.
+	serverPort.Close()
+	select {
+	case err := <-served:
+		if err == nil {
+			t.Error("expected an error from Serve")
+		}
+
+	case <-time.After(time.Second):
+		t.Error("Serve didn't return after the port was closed")
+	}
+}
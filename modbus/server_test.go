@@ -0,0 +1,98 @@
+package modbus
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// failingHandler fails every request with an error that isn't an exception
+// code.
+type failingHandler struct {
+	*RegisterMap
+}
+
+func (failingHandler) ReadHoldingRegisters(unit byte, address, quantity uint16) ([]uint16, error) {
+	return nil, errors.New("sensor offline")
+}
+
+func TestServerRequests(t *testing.T) {
+	testCases := []struct {
+		Name     string
+		Request  []byte
+		Response []byte
+	}{
+		{"read coils", []byte{0x01, 0x00, 0x00, 0x00, 0x0a}, []byte{0x01, 0x02, 0x05, 0x02}},
+		{"read discrete inputs", []byte{0x02, 0x00, 0x01, 0x00, 0x02}, []byte{0x02, 0x01, 0x02}},
+		{"read holding registers", []byte{0x03, 0x00, 0x01, 0x00, 0x02}, []byte{0x03, 0x04, 0x12, 0x34, 0x00, 0x00}},
+		{"read input registers", []byte{0x04, 0x00, 0x00, 0x00, 0x01}, []byte{0x04, 0x02, 0x00, 0x2a}},
+		{"write single coil", []byte{0x05, 0x00, 0x03, 0xff, 0x00}, []byte{0x05, 0x00, 0x03, 0xff, 0x00}},
+		{"write single register", []byte{0x06, 0x00, 0x02, 0xbe, 0xef}, []byte{0x06, 0x00, 0x02, 0xbe, 0xef}},
+		{"write multiple coils", []byte{0x0f, 0x00, 0x04, 0x00, 0x09, 0x02, 0xff, 0x01}, []byte{0x0f, 0x00, 0x04, 0x00, 0x09}},
+		{"write multiple registers", []byte{0x10, 0x00, 0x00, 0x00, 0x01, 0x02, 0x00, 0x07}, []byte{0x10, 0x00, 0x00, 0x00, 0x01}},
+		{"mask write register", []byte{0x16, 0x00, 0x01, 0x00, 0xf2, 0x00, 0x25}, []byte{0x16, 0x00, 0x01, 0x00, 0xf2, 0x00, 0x25}},
+		{
+			"read/write multiple registers",
+			[]byte{0x17, 0x00, 0x00, 0x00, 0x02, 0x00, 0x01, 0x00, 0x01, 0x02, 0x00, 0x09},
+			[]byte{0x17, 0x04, 0x00, 0x00, 0x00, 0x09},
+		},
+		{"query data", []byte{0x08, 0x00, 0x00, 0xa5, 0x37}, []byte{0x08, 0x00, 0x00, 0xa5, 0x37}},
+		{"bus message count", []byte{0x08, 0x00, 0x0b, 0x00, 0x00}, []byte{0x08, 0x00, 0x0b, 0x00, 0x00}},
+
+		// Exceptions.
+		{"unknown function", []byte{0x2b, 0x0e, 0x01, 0x00}, []byte{0xab, 0x01}},
+		{"unknown diagnostic", []byte{0x08, 0x00, 0x63, 0x00, 0x00}, []byte{0x88, 0x01}},
+		{"beyond table", []byte{0x03, 0x00, 0x0f, 0x00, 0x02}, []byte{0x83, 0x02}},
+		{"beyond address space", []byte{0x03, 0xff, 0xff, 0x00, 0x02}, []byte{0x83, 0x02}},
+		{"no registers", []byte{0x03, 0x00, 0x00, 0x00, 0x00}, []byte{0x83, 0x03}},
+		{"too many registers", []byte{0x04, 0x00, 0x00, 0x00, 0x7e}, []byte{0x84, 0x03}},
+		{"bad coil value", []byte{0x05, 0x00, 0x03, 0x12, 0x34}, []byte{0x85, 0x03}},
+		{"bad byte count", []byte{0x10, 0x00, 0x00, 0x00, 0x01, 0x03, 0x00, 0x07}, []byte{0x90, 0x03}},
+		{"truncated", []byte{0x06, 0x00, 0x02}, []byte{0x86, 0x03}},
+	}
+
+	for _, testCase := range testCases {
+		m := NewRegisterMap(16, 16, 16, 16)
+		m.WriteCoils(0, 0, []bool{true, false, true, false, false, false, false, false, false, true})
+		m.SetDiscreteInputs(1, false, true)
+		m.WriteHoldingRegisters(0, 1, []uint16{0x1234})
+		m.SetInputRegisters(0, 42)
+
+		s := newServer(nil)
+		if got := s.handle(1, m, testCase.Request); !bytes.Equal(got, testCase.Response) {
+			t.Errorf("%s: expected % x, got % x", testCase.Name, testCase.Response, got)
+		}
+	}
+}
+
+func TestServerHandlerErrors(t *testing.T) {
+	s := newServer(nil)
+	h := failingHandler{NewRegisterMap(0, 0, 0, 0)}
+
+	if got := s.handle(1, h, []byte{0x03, 0x00, 0x00, 0x00, 0x01}); !bytes.Equal(got, []byte{0x83, 0x04}) {
+		t.Errorf("expected server device failure, got % x", got)
+	}
+
+	if got := s.handle(1, h, []byte{0x01, 0x00, 0x00, 0x00, 0x01}); !bytes.Equal(got, []byte{0x81, 0x02}) {
+		t.Errorf("expected illegal data address, got % x", got)
+	}
+
+	if s.exceptions != 2 {
+		t.Errorf("expected 2 exceptions counted, got %d", s.exceptions)
+	}
+}
+
+func TestRegisterMapWrites(t *testing.T) {
+	m := NewRegisterMap(0, 0, 4, 0)
+	s := newServer(nil)
+
+	// Mask write, with the example from the specification.
+	m.WriteHoldingRegisters(0, 0, []uint16{0x12})
+	s.handle(1, m, []byte{0x16, 0x00, 0x00, 0x00, 0xf2, 0x00, 0x25})
+
+	// Read/write multiple registers writes before reading.
+	got := s.handle(1, m, []byte{0x17, 0x00, 0x00, 0x00, 0x03, 0x00, 0x01, 0x00, 0x02, 0x04, 0x00, 0x01, 0x00, 0x02})
+	if expected := []byte{0x17, 0x06, 0x00, 0x17, 0x00, 0x01, 0x00, 0x02}; !bytes.Equal(got, expected) {
+		t.Errorf("expected % x, got % x", expected, got)
+	}
+}
@@ -0,0 +1,196 @@
+package pcapng
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/jacobsa/go-serial/serial"
+)
+
+type rawBlock struct {
+	Type uint32
+	Body []byte
+}
+
+// splitBlocks breaks a little-endian pcapng stream into its blocks, checking
+// that the leading and trailing length fields agree.
+func splitBlocks(t *testing.T, b []byte) []rawBlock {
+	var blocks []rawBlock
+	for len(b) > 0 {
+		if len(b) < 12 {
+			t.Fatalf("truncated block header: %d bytes left", len(b))
+		}
+
+		blockType := binary.LittleEndian.Uint32(b[0:4])
+		total := binary.LittleEndian.Uint32(b[4:8])
+		if total%4 != 0 || int(total) > len(b) {
+			t.Fatalf("bad block length %d", total)
+		}
+
+		trailer := binary.LittleEndian.Uint32(b[total-4 : total])
+		if trailer != total {
+			t.Fatalf("trailing length %d does not match %d", trailer, total)
+		}
+
+		blocks = append(blocks, rawBlock{blockType, b[8 : total-4]})
+		b = b[total:]
+	}
+
+	return blocks
+}
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, WriterOptions{InterfaceName: "/dev/ttyUSB0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := time.Unix(1500000000, 123456000)
+	if err := w.WritePacket(ts, DIRECTION_OUTBOUND, []byte{0x01, 0x02, 0x03}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.WritePacket(ts, DIRECTION_INBOUND, []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	blocks := splitBlocks(t, buf.Bytes())
+	if len(blocks) != 4 {
+		t.Fatalf("expected 4 blocks, got %d", len(blocks))
+	}
+
+	if blocks[0].Type != kBlockTypeSHB {
+		t.Errorf("expected SHB, got %#x", blocks[0].Type)
+	}
+
+	if magic := binary.LittleEndian.Uint32(blocks[0].Body); magic != kByteOrderMagic {
+		t.Errorf("bad byte-order magic %#x", magic)
+	}
+
+	if blocks[1].Type != kBlockTypeIDB {
+		t.Errorf("expected IDB, got %#x", blocks[1].Type)
+	}
+
+	if lt := binary.LittleEndian.Uint16(blocks[1].Body); LinkType(lt) != LINKTYPE_USER0 {
+		t.Errorf("expected link type %d, got %d", LINKTYPE_USER0, lt)
+	}
+
+	testCases := []struct {
+		Dir  Direction
+		Data []byte
+	}{
+		{DIRECTION_OUTBOUND, []byte{0x01, 0x02, 0x03}},
+		{DIRECTION_INBOUND, []byte("hello")},
+	}
+
+	for i, testCase := range testCases {
+		b := blocks[2+i]
+		if b.Type != kBlockTypeEPB {
+			t.Fatalf("expected EPB, got %#x", b.Type)
+		}
+
+		body := b.Body
+		micros := uint64(binary.LittleEndian.Uint32(body[4:8]))<<32 |
+			uint64(binary.LittleEndian.Uint32(body[8:12]))
+		if micros != 1500000000123456 {
+			t.Errorf("unexpected timestamp %d", micros)
+		}
+
+		capLen := binary.LittleEndian.Uint32(body[12:16])
+		if int(capLen) != len(testCase.Data) {
+			t.Errorf("expected captured length %d, got %d", len(testCase.Data), capLen)
+		}
+
+		data := body[20 : 20+capLen]
+		if !bytes.Equal(data, testCase.Data) {
+			t.Errorf("expected data %x, got %x", testCase.Data, data)
+		}
+
+		// The epb_flags option follows the padded packet data.
+		opts := body[20+(capLen+3)&^3:]
+		if code := binary.LittleEndian.Uint16(opts[0:2]); code != kOptEpbFlags {
+			t.Fatalf("expected epb_flags option, got code %d", code)
+		}
+
+		if flags := binary.LittleEndian.Uint32(opts[4:8]); Direction(flags&3) != testCase.Dir {
+			t.Errorf("expected direction %d, got flags %#x", testCase.Dir, flags)
+		}
+	}
+}
+
+func TestWriterSnapLen(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, WriterOptions{SnapLen: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.WritePacket(time.Now(), DIRECTION_INBOUND, []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	body := splitBlocks(t, buf.Bytes())[2].Body
+	if capLen := binary.LittleEndian.Uint32(body[12:16]); capLen != 2 {
+		t.Errorf("expected captured length 2, got %d", capLen)
+	}
+
+	if origLen := binary.LittleEndian.Uint32(body[16:20]); origLen != 5 {
+		t.Errorf("expected original length 5, got %d", origLen)
+	}
+}
+
+func TestWriterRTACHeader(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, WriterOptions{LinkType: LINKTYPE_RTAC_SERIAL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := time.Unix(1500000000, 123456000)
+	if err := w.WritePacket(ts, DIRECTION_OUTBOUND, []byte{0xAA}); err != nil {
+		t.Fatal(err)
+	}
+
+	body := splitBlocks(t, buf.Bytes())[2].Body
+	data := body[20 : 20+binary.LittleEndian.Uint32(body[12:16])]
+	if len(data) != 13 {
+		t.Fatalf("expected 13 bytes of packet data, got %d", len(data))
+	}
+
+	if secs := binary.BigEndian.Uint32(data[0:4]); secs != 1500000000 {
+		t.Errorf("unexpected seconds %d", secs)
+	}
+
+	if usecs := binary.BigEndian.Uint32(data[4:8]); usecs != 123456 {
+		t.Errorf("unexpected microseconds %d", usecs)
+	}
+
+	if data[12] != 0xAA {
+		t.Errorf("expected payload 0xAA, got %#x", data[12])
+	}
+}
+
+func TestTap(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, WriterOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tap := NewTap(w)
+	tap.OnWrite([]byte("ping"))
+	tap.OnRead([]byte("pong"))
+	tap.OnModemLines(serial.MODEM_DTR, serial.MODEM_DTR|serial.MODEM_CTS)
+
+	blocks := splitBlocks(t, buf.Bytes())
+	if len(blocks) != 5 {
+		t.Fatalf("expected 5 blocks, got %d", len(blocks))
+	}
+
+	if !bytes.Contains(blocks[4].Body, []byte("modem lines DTR -> DTR|CTS")) {
+		t.Errorf("expected modem line comment, got %q", blocks[4].Body)
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright 2011 Aaron Jacobs. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pcapng
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jacobsa/go-serial/serial"
+)
+
+// tap records the traffic on a port to a Writer.
+type tap struct {
+	w *Writer
+}
+
+// NewTap returns a serial.Tap that records each chunk of data read from or
+// written to a port as a packet, and modem line changes and errors as
+// comments. Set it as OpenOptions.Tap to capture a port's traffic.
+//
+// Errors writing the capture are not reported to callers of the port's
+// methods; use w.Err to check for them.
+func NewTap(w *Writer) serial.Tap {
+	return &tap{w}
+}
+
+func (t *tap) OnRead(b []byte) {
+	t.w.WritePacket(time.Now(), DIRECTION_INBOUND, b)
+}
+
+func (t *tap) OnWrite(b []byte) {
+	t.w.WritePacket(time.Now(), DIRECTION_OUTBOUND, b)
+}
+
+func (t *tap) OnModemLines(old, new serial.ModemLines) {
+	t.w.WriteComment(time.Now(), fmt.Sprintf("modem lines %v -> %v", old, new))
+}
+
+func (t *tap) OnError(op string, err error) {
+	t.w.WriteComment(time.Now(), fmt.Sprintf("%s error: %v", op, err))
+}
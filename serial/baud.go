@@ -0,0 +1,89 @@
+// Copyright 2011 Aaron Jacobs. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package serial
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// BaudRates records the speeds at which a port's driver reports it is
+// running. These may differ from those requested when the hardware cannot
+// divide its clock down to the exact rate.
+type BaudRates struct {
+	Input  uint
+	Output uint
+}
+
+// BaudRates returns the speeds the driver reports having set. It returns an
+// error wrapping errors.ErrUnsupported for ports that are not OS devices, or
+// whose driver cannot report them.
+func (p *Port) BaudRates() (BaudRates, error) {
+	return getBaudRates(p.f)
+}
+
+// requestedBaudRates returns the speeds asked for by the options.
+func requestedBaudRates(options OpenOptions) BaudRates {
+	rates := BaudRates{Input: options.BaudRate, Output: options.BaudRate}
+	if options.InputBaudRate != 0 {
+		rates.Input = options.InputBaudRate
+	}
+
+	return rates
+}
+
+// checkBaudRates compares the speeds achieved by the driver with those
+// requested, failing if either deviates by more than BaudRateTolerance.
+// Ports that cannot report their speeds are not checked.
+func checkBaudRates(f io.ReadWriteCloser, options OpenOptions) error {
+	if options.BaudRateTolerance == 0 {
+		return nil
+	}
+
+	achieved, err := getBaudRates(f)
+	if errors.Is(err, errors.ErrUnsupported) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	requested := requestedBaudRates(options)
+	for _, r := range []struct {
+		direction           string
+		requested, achieved uint
+	}{
+		{"input", requested.Input, achieved.Input},
+		{"output", requested.Output, achieved.Output},
+	} {
+		deviation := math.Abs(float64(r.achieved)-float64(r.requested)) / float64(r.requested)
+		if deviation > options.BaudRateTolerance {
+			return &PortError{
+				Kind: ErrUnsupportedBaudRate,
+				Err: fmt.Errorf(
+					"driver set %s speed to %d baud, %.1f%% from the %d requested",
+					r.direction,
+					r.achieved,
+					deviation*100,
+					r.requested),
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,165 @@
+// Copyright 2011 Aaron Jacobs. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package serial
+
+import (
+	"errors"
+	"io/fs"
+	"runtime"
+	"syscall"
+)
+
+// Errors returned by Open, wrapped in a *PortError, and by
+// OpenOptions.Validate. Test for them with errors.Is.
+var (
+	// Invalid OpenOptions.
+	ErrInvalidDataBits     = errors.New("invalid setting for DataBits")
+	ErrInvalidStopBits     = errors.New("invalid setting for StopBits")
+	ErrInvalidParityMode   = errors.New("invalid setting for ParityMode")
+	ErrInvalidLineState    = errors.New("invalid setting for InitialDTR or InitialRTS")
+	ErrInvalidTimeout      = errors.New("invalid read timeout settings")
+	ErrUnsupportedBaudRate = errors.New("unsupported baud rate")
+	ErrInvalidRS485        = errors.New("invalid RS485 settings")
+	ErrConflictingOptions  = errors.New("conflicting options")
+
+	// Returned by ParseSettings for malformed settings strings.
+	ErrInvalidSettings = errors.New("invalid settings string")
+
+	// Problems with the device.
+	ErrPortNotFound       = errors.New("port not found")
+	ErrPortBusy           = errors.New("port busy")
+	ErrPermissionDenied   = errors.New("permission denied")
+	ErrRS485Unsupported   = errors.New("RS485 mode not supported by this port")
+	ErrLatencyUnsupported = errors.New("latency or divisor settings not supported by this port")
+)
+
+// Returned by the OS-specific code for 1.5 stop bits with other than 5 data
+// bits, which UARTs can't send.
+var errOnePointFiveStop = &PortError{
+	Kind: ErrInvalidStopBits,
+	Err:  errors.New("1.5 stop bits can only be used with 5 data bits"),
+}
+
+// PortError records a failed operation on a serial port.
+//
+// Both Kind and Err are visible to errors.Is and errors.As, so a caller can
+// test for e.g. ErrPermissionDenied or syscall.EACCES alike.
+type PortError struct {
+	// The operation that failed, e.g. "open".
+	Op string
+
+	// The name of the port, as given in OpenOptions.PortName.
+	Port string
+
+	// One of the Err* values defined by this package, or nil if the error
+	// does not fall into any of those categories.
+	Kind error
+
+	// The underlying error, typically a syscall.Errno, or nil if there is none
+	// beyond Kind.
+	Err error
+}
+
+func (e *PortError) Error() string {
+	msg := e.Op + " " + e.Port + ": "
+	if e.Err != nil {
+		msg += e.Err.Error()
+	} else if e.Kind != nil {
+		msg += e.Kind.Error()
+	}
+
+	if e.Kind == ErrPermissionDenied && runtime.GOOS == "linux" {
+		msg += " (is the user in the dialout group?)"
+	}
+
+	return msg
+}
+
+// Unwrap returns Kind and Err, whichever are non-nil.
+func (e *PortError) Unwrap() []error {
+	var errs []error
+	if e.Kind != nil {
+		errs = append(errs, e.Kind)
+	}
+
+	if e.Err != nil {
+		errs = append(errs, e.Err)
+	}
+
+	return errs
+}
+
+// The sentinel errors that may appear as PortError.Kind.
+var errorKinds = []error{
+	ErrInvalidDataBits,
+	ErrInvalidStopBits,
+	ErrInvalidParityMode,
+	ErrInvalidLineState,
+	ErrInvalidTimeout,
+	ErrUnsupportedBaudRate,
+	ErrInvalidRS485,
+	ErrConflictingOptions,
+	ErrPortNotFound,
+	ErrPortBusy,
+	ErrPermissionDenied,
+	ErrRS485Unsupported,
+	ErrLatencyUnsupported,
+}
+
+// newPortError returns a *PortError for an error returned by the OS-specific
+// code, attaching the operation and port name and classifying common syscall
+// errors. The OS-specific code may return a partially filled *PortError when
+// it knows better, e.g. that an ioctl failed because RS485 is unsupported.
+func newPortError(op string, port string, err error) *PortError {
+	var pe *PortError
+	if errors.As(err, &pe) {
+		result := *pe
+		if result.Op == "" {
+			result.Op = op
+		}
+
+		if result.Port == "" {
+			result.Port = port
+		}
+
+		return &result
+	}
+
+	result := &PortError{Op: op, Port: port}
+	for _, kind := range errorKinds {
+		if err == kind {
+			result.Kind = kind
+			return result
+		}
+	}
+
+	// The path is already recorded in the PortError, so avoid repeating it.
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		err = pathErr.Err
+	}
+
+	result.Err = err
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		result.Kind = ErrPortNotFound
+	case errors.Is(err, fs.ErrPermission):
+		result.Kind = ErrPermissionDenied
+	case errors.Is(err, syscall.EBUSY):
+		result.Kind = ErrPortBusy
+	}
+
+	return result
+}
@@ -0,0 +1,77 @@
+package serial
+
+import (
+	"errors"
+	"io/fs"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestNewPortError(t *testing.T) {
+	testCases := []struct {
+		Name string
+		Err  error
+		Kind error
+	}{
+		{"not found", &fs.PathError{Op: "open", Path: "/dev/x", Err: syscall.ENOENT}, ErrPortNotFound},
+		{"permission", &fs.PathError{Op: "open", Path: "/dev/x", Err: syscall.EACCES}, ErrPermissionDenied},
+		{"busy", syscall.EBUSY, ErrPortBusy},
+		{"sentinel", ErrInvalidDataBits, ErrInvalidDataBits},
+		{"partial", &PortError{Kind: ErrRS485Unsupported, Err: syscall.ENOTTY}, ErrRS485Unsupported},
+		{"unclassified", errors.New("boom"), nil},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			err := newPortError("open", "/dev/x", testCase.Err)
+
+			if err.Port != "/dev/x" || err.Op != "open" {
+				t.Errorf("expected op and port to be set, got %+v", err)
+			}
+
+			if err.Kind != testCase.Kind {
+				t.Errorf("expected kind %v, got %v", testCase.Kind, err.Kind)
+			}
+
+			if testCase.Kind != nil && !errors.Is(err, testCase.Kind) {
+				t.Errorf("expected errors.Is(%v, %v)", err, testCase.Kind)
+			}
+
+			if !strings.HasPrefix(err.Error(), "open /dev/x: ") {
+				t.Errorf("unexpected message %q", err.Error())
+			}
+		})
+	}
+}
+
+func TestPortErrorUnwrapsSyscallError(t *testing.T) {
+	err := newPortError("open", "/dev/x", &fs.PathError{Op: "open", Path: "/dev/x", Err: syscall.EACCES})
+
+	if !errors.Is(err, syscall.EACCES) {
+		t.Errorf("expected errors.Is(%v, EACCES)", err)
+	}
+
+	if strings.Count(err.Error(), "/dev/x") != 1 {
+		t.Errorf("expected the port name once in %q", err.Error())
+	}
+}
+
+func TestOpenNonexistentPort(t *testing.T) {
+	_, err := Open(OpenOptions{
+		PortName:        "/nonexistent/tty",
+		BaudRate:        9600,
+		DataBits:        8,
+		StopBits:        1,
+		MinimumReadSize: 1,
+	})
+
+	if !errors.Is(err, ErrPortNotFound) {
+		t.Fatalf("expected ErrPortNotFound, got %v", err)
+	}
+
+	var portErr *PortError
+	if !errors.As(err, &portErr) || portErr.Port != "/nonexistent/tty" {
+		t.Errorf("expected a *PortError for /nonexistent/tty, got %#v", err)
+	}
+}
@@ -81,6 +81,9 @@ func TestIncrementAndEcho(t *testing.T) {
 	defer circuit.Close()
 
 	// Pause for a few seconds to deal with the Arduino's annoying startup delay.
+	// Opening the port resets the board the first time after it's plugged in,
+	// whatever InitialDTR says, since open(2) asserts DTR; later opens leave
+	// it alone because HangupOnClose is off.
 	time.Sleep(3e9)
 
 	// Write some bytes.
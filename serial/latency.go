@@ -0,0 +1,118 @@
+// Copyright 2011 Aaron Jacobs. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package serial
+
+import (
+	"io"
+	"math"
+	"time"
+)
+
+// The largest latency timer accepted by USB serial adapters.
+const kMaxLatencyTimer = 255 * time.Millisecond
+
+// applyLatencyOptions applies the LowLatency and LatencyTimer options to a
+// newly opened port.
+func applyLatencyOptions(f io.ReadWriteCloser, options OpenOptions) error {
+	if options.LowLatency {
+		if err := setLowLatency(f, true); err != nil {
+			return err
+		}
+	}
+
+	if options.LatencyTimer != 0 {
+		if err := setLatencyTimer(f, options.LatencyTimer); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// LowLatency reports whether the driver's ASYNC_LOW_LATENCY flag is set.
+// See OpenOptions.LowLatency.
+func (p *Port) LowLatency() (bool, error) {
+	on, err := getLowLatency(p.f)
+	if err != nil {
+		return false, newPortError("get low latency", p.name, err)
+	}
+
+	return on, nil
+}
+
+// SetLowLatency sets or clears the driver's ASYNC_LOW_LATENCY flag. See
+// OpenOptions.LowLatency.
+func (p *Port) SetLowLatency(on bool) error {
+	if err := setLowLatency(p.f, on); err != nil {
+		return newPortError("set low latency", p.name, err)
+	}
+
+	return nil
+}
+
+// LatencyTimer returns the USB adapter's latency timer. See
+// OpenOptions.LatencyTimer.
+func (p *Port) LatencyTimer() (time.Duration, error) {
+	d, err := getLatencyTimer(p.f)
+	if err != nil {
+		return 0, newPortError("get latency timer", p.name, err)
+	}
+
+	return d, nil
+}
+
+// SetLatencyTimer sets the USB adapter's latency timer, which is rounded up
+// to whole milliseconds. See OpenOptions.LatencyTimer.
+func (p *Port) SetLatencyTimer(d time.Duration) error {
+	if d < time.Millisecond || d > kMaxLatencyTimer {
+		return newPortError("set latency timer", p.name, ErrInvalidTimeout)
+	}
+
+	if err := setLatencyTimer(p.f, d); err != nil {
+		return newPortError("set latency timer", p.name, err)
+	}
+
+	return nil
+}
+
+// CustomDivisor returns the divisor set with SetCustomDivisor, or zero if
+// none is in effect, and the driver's base baud rate that it divides.
+func (p *Port) CustomDivisor() (divisor, baudBase int, err error) {
+	divisor, baudBase, err = getCustomDivisor(p.f)
+	if err != nil {
+		return 0, 0, newPortError("get custom divisor", p.name, err)
+	}
+
+	return divisor, baudBase, nil
+}
+
+// SetCustomDivisor sets the ASYNC_SPD_CUST flag and custom_divisor with the
+// TIOCSSERIAL ioctl, so that while the port's baud rate is 38400 it instead
+// runs at the base baud rate (see CustomDivisor) divided by divisor. Zero
+// clears the flag. This is the old way of getting a non-standard rate from
+// 8250 and FTDI drivers; OpenOptions.BaudRate can usually request any rate
+// directly. Like LowLatency it is supported only on Linux, and only by
+// some drivers, failing with ErrLatencyUnsupported otherwise.
+func (p *Port) SetCustomDivisor(divisor int) error {
+	if divisor < 0 || divisor > math.MaxInt32 {
+		return newPortError("set custom divisor", p.name, ErrUnsupportedBaudRate)
+	}
+
+	if err := setCustomDivisor(p.f, divisor); err != nil {
+		return newPortError("set custom divisor", p.name, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,192 @@
+// Copyright 2011 Aaron Jacobs. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package serial
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// From linux/tty_flags.h.
+const (
+	aSYNC_SPD_CUST    = 0x0030
+	aSYNC_SPD_MASK    = 0x1030
+	aSYNC_LOW_LATENCY = 1 << 13
+)
+
+// The serial_struct used by TIOCGSERIAL and TIOCSSERIAL, from
+// linux/serial.h.
+type serial_struct struct {
+	typ             int32
+	line            int32
+	port            uint32
+	irq             int32
+	flags           int32
+	xmit_fifo_size  int32
+	custom_divisor  int32
+	baud_base       int32
+	close_delay     uint16
+	io_type         int8
+	reserved_char   int8
+	hub6            int32
+	closing_wait    uint16
+	closing_wait2   uint16
+	iomem_base      uintptr
+	iomem_reg_shift uint16
+	port_high       uint32
+	iomap_base      uintptr
+}
+
+// serialIoctl makes the TIOCGSERIAL or TIOCSSERIAL ioctl, reporting drivers
+// that don't implement it (such as the pty driver) as unsupported.
+func serialIoctl(port io.ReadWriteCloser, request uintptr, ss *serial_struct) error {
+	file, ok := port.(fder)
+	if !ok {
+		return ErrLatencyUnsupported
+	}
+
+	_, _, errno := syscall.Syscall(
+		syscall.SYS_IOCTL,
+		file.Fd(),
+		request,
+		uintptr(unsafe.Pointer(ss)))
+
+	if errno == syscall.ENOTTY || errno == syscall.EINVAL {
+		return &PortError{
+			Kind: ErrLatencyUnsupported,
+			Err:  os.NewSyscallError("SYS_IOCTL (serial_struct)", errno),
+		}
+	}
+
+	if errno != 0 {
+		return os.NewSyscallError("SYS_IOCTL (serial_struct)", errno)
+	}
+
+	return nil
+}
+
+func getLowLatency(port io.ReadWriteCloser) (bool, error) {
+	var ss serial_struct
+	if err := serialIoctl(port, unix.TIOCGSERIAL, &ss); err != nil {
+		return false, err
+	}
+
+	return ss.flags&aSYNC_LOW_LATENCY != 0, nil
+}
+
+func setLowLatency(port io.ReadWriteCloser, on bool) error {
+	var ss serial_struct
+	if err := serialIoctl(port, unix.TIOCGSERIAL, &ss); err != nil {
+		return err
+	}
+
+	if on {
+		ss.flags |= aSYNC_LOW_LATENCY
+	} else {
+		ss.flags &^= aSYNC_LOW_LATENCY
+	}
+
+	return serialIoctl(port, unix.TIOCSSERIAL, &ss)
+}
+
+func getCustomDivisor(port io.ReadWriteCloser) (int, int, error) {
+	var ss serial_struct
+	if err := serialIoctl(port, unix.TIOCGSERIAL, &ss); err != nil {
+		return 0, 0, err
+	}
+
+	if ss.flags&aSYNC_SPD_MASK != aSYNC_SPD_CUST {
+		return 0, int(ss.baud_base), nil
+	}
+
+	return int(ss.custom_divisor), int(ss.baud_base), nil
+}
+
+func setCustomDivisor(port io.ReadWriteCloser, divisor int) error {
+	var ss serial_struct
+	if err := serialIoctl(port, unix.TIOCGSERIAL, &ss); err != nil {
+		return err
+	}
+
+	ss.flags &^= aSYNC_SPD_MASK
+	ss.custom_divisor = int32(divisor)
+	if divisor != 0 {
+		ss.flags |= aSYNC_SPD_CUST
+	}
+
+	return serialIoctl(port, unix.TIOCSSERIAL, &ss)
+}
+
+// latencyTimerPath returns the sysfs file holding the latency timer of the
+// USB serial adapter behind the port, as found by the name of the device
+// node the port's file descriptor refers to.
+func latencyTimerPath(port io.ReadWriteCloser) (string, error) {
+	file, ok := port.(fder)
+	if !ok {
+		return "", ErrLatencyUnsupported
+	}
+
+	dev, err := os.Readlink(fmt.Sprintf("/proc/self/fd/%d", file.Fd()))
+	if err != nil {
+		return "", err
+	}
+
+	path := filepath.Join("/sys/class/tty", filepath.Base(dev), "device", "latency_timer")
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		return "", &PortError{Kind: ErrLatencyUnsupported}
+	}
+
+	return path, nil
+}
+
+func getLatencyTimer(port io.ReadWriteCloser) (time.Duration, error) {
+	path, err := latencyTimerPath(port)
+	if err != nil {
+		return 0, err
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	ms, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+func setLatencyTimer(port io.ReadWriteCloser, d time.Duration) error {
+	path, err := latencyTimerPath(port)
+	if err != nil {
+		return err
+	}
+
+	ms := (d + time.Millisecond - 1) / time.Millisecond
+	return os.WriteFile(path, []byte(strconv.Itoa(int(ms))), 0)
+}
@@ -0,0 +1,46 @@
+// Copyright 2011 Aaron Jacobs. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !linux
+
+package serial
+
+import (
+	"io"
+	"time"
+)
+
+func getLowLatency(port io.ReadWriteCloser) (bool, error) {
+	return false, ErrLatencyUnsupported
+}
+
+func setLowLatency(port io.ReadWriteCloser, on bool) error {
+	return ErrLatencyUnsupported
+}
+
+func getCustomDivisor(port io.ReadWriteCloser) (int, int, error) {
+	return 0, 0, ErrLatencyUnsupported
+}
+
+func setCustomDivisor(port io.ReadWriteCloser, divisor int) error {
+	return ErrLatencyUnsupported
+}
+
+func getLatencyTimer(port io.ReadWriteCloser) (time.Duration, error) {
+	return 0, ErrLatencyUnsupported
+}
+
+func setLatencyTimer(port io.ReadWriteCloser, d time.Duration) error {
+	return ErrLatencyUnsupported
+}
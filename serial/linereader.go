@@ -0,0 +1,300 @@
+// Copyright 2011 Aaron Jacobs. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package serial
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// Errors returned by LineReader.ReadLine.
+var (
+	ErrLineTooLong = errors.New("line too long")
+	ErrLineTimeout = errors.New("timed out waiting for a line")
+)
+
+// The defaults for LineReaderOptions.
+const kDefaultMaxLineLength = 4096
+
+var kDefaultTerminators = []string{"\r\n", "\r", "\n"}
+
+// LineReaderOptions configures a LineReader.
+type LineReaderOptions struct {
+	// The byte sequences that end a line. When several match at the same
+	// place, the longest wins, so "\r\n" is a single terminator even if "\r"
+	// and "\n" are also listed. Defaults to "\r\n", "\r" and "\n".
+	Terminators []string
+
+	// How long ReadLine waits for a complete line, or zero to wait forever.
+	// This is checked each time a Read of the underlying port returns, so the
+	// port must be opened with ReadTimeout for it to take effect while no
+	// data arrives. (With InterCharacterTimeout instead, a read that times out
+	// returns io.EOF on Linux, which ReadLine returns as an error.)
+	Timeout time.Duration
+
+	// The longest line, not counting its terminator, that ReadLine returns
+	// whole. Defaults to 4096.
+	MaxLength int
+
+	// Discard data written with LineReader.Write when the device echoes it
+	// back, as many command interpreters do.
+	StripEcho bool
+
+	// Don't return empty lines, such as those from devices that end lines
+	// with "\n\r".
+	SkipEmptyLines bool
+}
+
+// LineReader splits the data received from a port into lines, for devices
+// that speak a line-oriented ASCII protocol. It may be used by one reader and
+// one writer concurrently.
+type LineReader struct {
+	rw      io.ReadWriter
+	options LineReaderOptions
+
+	mu  sync.Mutex
+	buf []byte
+
+	// Bytes written that the device is expected to echo, and where in buf
+	// the echo will begin.
+	echo    []byte
+	echoPos int
+
+	// The remainders of terminators longer than the one that ended the last
+	// line, which are skipped if they turn up next. This deals with a "\r\n"
+	// split across reads without delaying lines ended by a lone "\r".
+	pending []string
+
+	// The error from the last read of the port, returned once the buffer has
+	// been drained.
+	err error
+}
+
+// NewLineReader returns a LineReader reading lines from rw, which is usually
+// a *Port. Data may be written to the port directly, except that only data
+// written with LineReader.Write is stripped when echoed.
+func NewLineReader(rw io.ReadWriter, options LineReaderOptions) *LineReader {
+	if len(options.Terminators) == 0 {
+		options.Terminators = kDefaultTerminators
+	}
+
+	if options.MaxLength <= 0 {
+		options.MaxLength = kDefaultMaxLineLength
+	}
+
+	return &LineReader{rw: rw, options: options}
+}
+
+// Write writes b to the port, arranging for its echo to be discarded if
+// StripEcho is set.
+func (l *LineReader) Write(b []byte) (int, error) {
+	if l.options.StripEcho {
+		l.mu.Lock()
+		if len(l.echo) == 0 {
+			l.echoPos = len(l.buf)
+		}
+
+		l.echo = append(l.echo, b...)
+		l.mu.Unlock()
+	}
+
+	return l.rw.Write(b)
+}
+
+// WriteLine writes s followed by the first of the configured terminators.
+func (l *LineReader) WriteLine(s string) error {
+	_, err := l.Write([]byte(s + l.options.Terminators[0]))
+	return err
+}
+
+// ReadLine returns the next line, without its terminator.
+//
+// If the line is longer than MaxLength, its first MaxLength bytes are
+// returned with ErrLineTooLong and the rest is returned by later calls. If
+// Timeout passes first, whatever has been received of the line is returned
+// along with ErrLineTimeout; later calls start a new line. At the end of the
+// input, any final unterminated line is returned before the error from the
+// port.
+func (l *LineReader) ReadLine() (string, error) {
+	var deadline time.Time
+	if l.options.Timeout > 0 {
+		deadline = time.Now().Add(l.options.Timeout)
+	}
+
+	chunk := make([]byte, 256)
+	for {
+		l.mu.Lock()
+		line, ok, err := l.nextLine()
+		l.mu.Unlock()
+
+		if ok {
+			if line == "" && err == nil && l.options.SkipEmptyLines {
+				continue
+			}
+
+			return line, err
+		}
+
+		if !deadline.IsZero() && !time.Now().Before(deadline) {
+			l.mu.Lock()
+			partial := string(l.buf)
+			l.buf = l.buf[:0]
+			l.echo = nil
+			l.mu.Unlock()
+
+			return partial, ErrLineTimeout
+		}
+
+		n, err := l.rw.Read(chunk)
+
+		l.mu.Lock()
+		l.buf = append(l.buf, chunk[:n]...)
+		l.err = err
+		l.mu.Unlock()
+	}
+}
+
+// nextLine removes the next line from buf, if there is one, having first
+// discarded any leftover terminator bytes and echo.
+func (l *LineReader) nextLine() (line string, ok bool, err error) {
+	l.skipPending()
+	l.stripEcho()
+
+	if i, term := l.findTerminator(); i >= 0 && i <= l.options.MaxLength {
+		line = string(l.buf[:i])
+		l.consume(i + len(term))
+		l.pending = l.longerTerminators(term)
+		return line, true, nil
+	}
+
+	if len(l.buf) > l.options.MaxLength {
+		line = string(l.buf[:l.options.MaxLength])
+		l.consume(l.options.MaxLength)
+		return line, true, ErrLineTooLong
+	}
+
+	if l.err != nil {
+		err, l.err = l.err, nil
+		line = string(l.buf)
+		l.consume(len(l.buf))
+
+		// Return the final line now and the error on the next call.
+		if line != "" {
+			l.err = err
+			return line, true, nil
+		}
+
+		return "", true, err
+	}
+
+	return "", false, nil
+}
+
+// stripEcho discards the expected echo once it has all arrived. If the device
+// sends something else instead, it is assumed not to echo.
+func (l *LineReader) stripEcho() {
+	if len(l.echo) == 0 {
+		return
+	}
+
+	received := l.buf[l.echoPos:]
+	if len(received) > len(l.echo) {
+		received = received[:len(l.echo)]
+	}
+
+	switch {
+	case !bytes.HasPrefix(l.echo, received):
+		l.echo = nil
+
+	case len(received) == len(l.echo):
+		l.buf = append(l.buf[:l.echoPos], l.buf[l.echoPos+len(l.echo):]...)
+		l.echo = nil
+	}
+}
+
+// skipPending discards the rest of a terminator split across reads.
+func (l *LineReader) skipPending() {
+	for len(l.pending) > 0 && len(l.buf) > 0 {
+		var next []string
+		for _, p := range l.pending {
+			if p[0] == l.buf[0] {
+				next = append(next, p[1:])
+			}
+		}
+
+		if len(next) == 0 {
+			l.pending = nil
+			return
+		}
+
+		l.consume(1)
+		l.pending = next
+		for _, p := range next {
+			if p == "" {
+				l.pending = nil
+				return
+			}
+		}
+	}
+}
+
+// findTerminator returns the index in buf of the first terminator and the
+// longest terminator found there, or -1 if there is none.
+func (l *LineReader) findTerminator() (int, string) {
+	for i := range l.buf {
+		// Leave the echo alone until it has been stripped.
+		if len(l.echo) > 0 && i >= l.echoPos {
+			return -1, ""
+		}
+
+		term := ""
+		for _, t := range l.options.Terminators {
+			if len(t) > len(term) && bytes.HasPrefix(l.buf[i:], []byte(t)) {
+				term = t
+			}
+		}
+
+		if term != "" {
+			return i, term
+		}
+	}
+
+	return -1, ""
+}
+
+// longerTerminators returns what remains of each terminator that begins with
+// term, for skipping if it arrives next.
+func (l *LineReader) longerTerminators(term string) []string {
+	var rest []string
+	for _, t := range l.options.Terminators {
+		if len(t) > len(term) && t[:len(term)] == term {
+			rest = append(rest, t[len(term):])
+		}
+	}
+
+	return rest
+}
+
+// consume removes n bytes from the front of buf.
+func (l *LineReader) consume(n int) {
+	l.buf = l.buf[:copy(l.buf, l.buf[n:])]
+	l.echoPos -= n
+	if l.echoPos < 0 {
+		l.echoPos = 0
+	}
+}
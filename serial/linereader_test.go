@@ -0,0 +1,201 @@
+package serial
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+)
+
+// chunkedPort returns its chunks from successive reads, then io.EOF.
+// Everything written to it is discarded.
+type chunkedPort struct {
+	chunks []string
+}
+
+func (c *chunkedPort) Read(b []byte) (int, error) {
+	if len(c.chunks) == 0 {
+		return 0, io.EOF
+	}
+
+	n := copy(b, c.chunks[0])
+	c.chunks = c.chunks[1:]
+	return n, nil
+}
+
+func (c *chunkedPort) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func TestLineReader(t *testing.T) {
+	testCases := []struct {
+		Options  LineReaderOptions
+		Chunks   []string
+		Expected []string
+		Err      error
+	}{
+		{LineReaderOptions{}, []string{"one\r\ntwo\nthree\r"}, []string{"one", "two", "three"}, nil},
+		{LineReaderOptions{}, []string{"one\r", "\ntwo\r\n"}, []string{"one", "two"}, nil},
+		{LineReaderOptions{}, []string{"o", "ne", "\r", "", "\n", "two"}, []string{"one", "two"}, nil},
+		{LineReaderOptions{}, []string{"one\r\r\n"}, []string{"one", ""}, nil},
+		{LineReaderOptions{}, []string{"one\n\r"}, []string{"one", ""}, nil},
+		{LineReaderOptions{SkipEmptyLines: true}, []string{"\r\none\n\r\n\rtwo\r"}, []string{"one", "two"}, nil},
+		{LineReaderOptions{Terminators: []string{";"}}, []string{"a\r;b\n", ";"}, []string{"a\r", "b\n"}, nil},
+		{LineReaderOptions{Terminators: []string{"\r\n"}}, []string{"a\rb\r", "\nc"}, []string{"a\rb", "c"}, nil},
+		{LineReaderOptions{Terminators: []string{"END"}}, []string{"aE", "N", "Db"}, []string{"a", "b"}, nil},
+		{LineReaderOptions{MaxLength: 4}, []string{"abcd\r\nabcdefghij\n"}, []string{"abcd", "abcd"}, ErrLineTooLong},
+	}
+
+	for _, testCase := range testCases {
+		l := NewLineReader(&chunkedPort{chunks: testCase.Chunks}, testCase.Options)
+
+		var lines []string
+		var err error
+		for {
+			var line string
+			line, err = l.ReadLine()
+			if err != nil && line == "" {
+				break
+			}
+
+			lines = append(lines, line)
+			if err != nil {
+				break
+			}
+		}
+
+		if err == io.EOF {
+			err = nil
+		}
+
+		if err != testCase.Err {
+			t.Errorf("%q: expected error %v, got %v", testCase.Chunks, testCase.Err, err)
+		}
+
+		if len(lines) != len(testCase.Expected) {
+			t.Errorf("%q: expected %q, got %q", testCase.Chunks, testCase.Expected, lines)
+			continue
+		}
+
+		for i := range lines {
+			if lines[i] != testCase.Expected[i] {
+				t.Errorf("%q: expected %q, got %q", testCase.Chunks, testCase.Expected, lines)
+				break
+			}
+		}
+	}
+}
+
+func TestLineReaderTooLong(t *testing.T) {
+	l := NewLineReader(&chunkedPort{chunks: []string{"abcdefghij\r\n"}}, LineReaderOptions{MaxLength: 4})
+
+	for _, expected := range []struct {
+		line string
+		err  error
+	}{
+		{"abcd", ErrLineTooLong},
+		{"efgh", ErrLineTooLong},
+		{"ij", nil},
+		{"", io.EOF},
+	} {
+		line, err := l.ReadLine()
+		if line != expected.line || err != expected.err {
+			t.Errorf("expected %q, %v; got %q, %v", expected.line, expected.err, line, err)
+		}
+	}
+}
+
+func TestLineReaderTimeout(t *testing.T) {
+	port, err := OpenPort(OpenOptions{
+		PortName:    "loop://",
+		BaudRate:    9600,
+		DataBits:    8,
+		StopBits:    1,
+		ReadTimeout: 10 * time.Millisecond,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer port.Close()
+
+	l := NewLineReader(port, LineReaderOptions{Timeout: 100 * time.Millisecond})
+
+	port.Write([]byte("partial"))
+	start := time.Now()
+	line, err := l.ReadLine()
+	if line != "partial" || err != ErrLineTimeout {
+		t.Errorf("expected %q, ErrLineTimeout; got %q, %v", "partial", line, err)
+	}
+
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond || elapsed > time.Second {
+		t.Errorf("timeout took %v", elapsed)
+	}
+
+	// The partial line is discarded, and the timeout restarts for each line.
+	port.Write([]byte("whole\r\n"))
+	if line, err := l.ReadLine(); line != "whole" || err != nil {
+		t.Errorf("expected %q, nil; got %q, %v", "whole", line, err)
+	}
+}
+
+func TestLineReaderStripEcho(t *testing.T) {
+	// The loop port echoes everything written to it, like a device with echo
+	// turned on.
+	port, err := OpenPort(OpenOptions{
+		PortName:    "loop://",
+		BaudRate:    9600,
+		DataBits:    8,
+		StopBits:    1,
+		ReadTimeout: 10 * time.Millisecond,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer port.Close()
+
+	l := NewLineReader(port, LineReaderOptions{
+		Timeout:        time.Second,
+		StripEcho:      true,
+		SkipEmptyLines: true,
+	})
+
+	if err := l.WriteLine("AT"); err != nil {
+		t.Fatal(err)
+	}
+
+	port.Write([]byte("\r\nOK\r\n"))
+	if line, err := l.ReadLine(); line != "OK" || err != nil {
+		t.Errorf("expected %q, nil; got %q, %v", "OK", line, err)
+	}
+
+	// Without StripEcho the command comes back.
+	l = NewLineReader(port, LineReaderOptions{Timeout: time.Second})
+	l.WriteLine("ATI")
+	if line, err := l.ReadLine(); line != "ATI" || err != nil {
+		t.Errorf("expected %q, nil; got %q, %v", "ATI", line, err)
+	}
+}
+
+func TestLineReaderNoEcho(t *testing.T) {
+	// A device that doesn't echo replies with something else, which is kept.
+	var written bytes.Buffer
+	l := NewLineReader(
+		struct {
+			io.Reader
+			io.Writer
+		}{&chunkedPort{chunks: []string{"O", "K\r\n"}}, &written},
+		LineReaderOptions{StripEcho: true})
+
+	l.WriteLine("OFF")
+	if line, err := l.ReadLine(); line != "OK" || err != nil {
+		t.Errorf("expected %q, nil; got %q, %v", "OK", line, err)
+	}
+
+	if written.String() != "OFF\r\n" {
+		t.Errorf("expected %q written, got %q", "OFF\r\n", written.String())
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright 2011 Aaron Jacobs. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package serial
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// loopPort is the in-memory port opened for loop:// URLs. Everything written
+// to it can be read back, as if the TX and RX pins were wired together.
+type loopPort struct {
+	timeouts readTimeouts
+
+	mu     sync.Mutex
+	buf    []byte
+	closed bool
+
+	// Signalled (without blocking) whenever data is written or the port is
+	// closed.
+	ready chan struct{}
+}
+
+func newLoopPort(options OpenOptions) *loopPort {
+	return &loopPort{
+		timeouts: readTimeoutsFor(options),
+		ready:    make(chan struct{}, 1),
+	}
+}
+
+func (l *loopPort) signal() {
+	select {
+	case l.ready <- struct{}{}:
+	default:
+	}
+}
+
+func (l *loopPort) Read(b []byte) (int, error) {
+	return l.timeouts.read(b, l.readUntil)
+}
+
+func (l *loopPort) readUntil(b []byte, deadline time.Time) (int, error) {
+	var timeout <-chan time.Time
+	if !deadline.IsZero() {
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	for {
+		l.mu.Lock()
+		if l.closed {
+			// Wake any other blocked reader too.
+			l.signal()
+			l.mu.Unlock()
+			return 0, os.ErrClosed
+		}
+
+		if len(l.buf) > 0 || len(b) == 0 {
+			n := copy(b, l.buf)
+			l.buf = l.buf[n:]
+			if len(l.buf) > 0 {
+				// Let any other reader have the rest.
+				l.signal()
+			}
+
+			l.mu.Unlock()
+			return n, nil
+		}
+		l.mu.Unlock()
+
+		select {
+		case <-l.ready:
+		case <-timeout:
+			return 0, nil
+		}
+	}
+}
+
+func (l *loopPort) Write(b []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return 0, os.ErrClosed
+	}
+
+	l.buf = append(l.buf, b...)
+	l.signal()
+
+	return len(b), nil
+}
+
+func (l *loopPort) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return os.ErrClosed
+	}
+
+	l.closed = true
+	l.signal()
+
+	return nil
+}
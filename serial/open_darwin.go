@@ -27,6 +27,7 @@ package serial
 
 import (
 	"errors"
+	"fmt"
 	"io"
 )
 import "os"
@@ -53,6 +54,7 @@ const (
 	kCCTS_OFLOW = 0x00010000
 	kCRTS_IFLOW = 0x00020000
 	kCRTSCTS    = kCCTS_OFLOW | kCRTS_IFLOW
+	kHUPCL      = 0x00004000
 
 	kNCCS = 20
 
@@ -68,6 +70,18 @@ const (
 
 	// IOKit: serial/ioss.h
 	kIOSSIOSPEED = 0x80045402
+
+	// sys/ttycom.h
+	kTIOCMGET = 0x4004746A
+	kTIOCMBIS = 0x8004746C
+	kTIOCMBIC = 0x8004746B
+
+	kTIOCM_DTR = 0x002
+	kTIOCM_RTS = 0x004
+	kTIOCM_CTS = 0x020
+	kTIOCM_CAR = 0x040
+	kTIOCM_RNG = 0x080
+	kTIOCM_DSR = 0x100
 )
 
 // sys/termios.h
@@ -119,22 +133,28 @@ func convertOptions(options OpenOptions) (*termios, error) {
 	// seems to imply that it shouldn't really exist.
 	result.c_cflag |= kCREAD
 
-	// Sanity check inter-character timeout and minimum read size options.
+	// Sanity check inter-character timeout and minimum read size options. If
+	// both are zero, VMIN and VTIME are left at zero and the port waits for
+	// data with poll instead.
 	vtime := uint(round(float64(options.InterCharacterTimeout)/100.0) * 100)
 	vmin := options.MinimumReadSize
 
-	if vmin == 0 && vtime < 100 {
-		return nil, errors.New("Invalid values for InterCharacterTimeout and MinimumReadSize.")
+	if options.usesVMINVTIME() && vmin == 0 && vtime < 100 {
+		return nil, ErrInvalidTimeout
 	}
 
 	if vtime > 25500 {
-		return nil, errors.New("Invalid value for InterCharacterTimeout.")
+		return nil, ErrInvalidTimeout
 	}
 
 	// Set VMIN and VTIME. Make sure to convert to tenths of seconds for VTIME.
 	result.c_cc[kVTIME] = cc_t(vtime / 100)
 	result.c_cc[kVMIN] = cc_t(vmin)
 
+	if options.BaudRate == 0 {
+		return nil, ErrUnsupportedBaudRate
+	}
+
 	if !IsStandardBaudRate(options.BaudRate) {
 		// Non-standard baud-rates cannot be set via the standard IOCTL.
 		//
@@ -146,6 +166,15 @@ func convertOptions(options OpenOptions) (*termios, error) {
 		result.c_ospeed = speed_t(options.BaudRate)
 	}
 
+	// IOSSIOSPEED sets both speeds, so split rates must be standard ones.
+	if options.InputBaudRate != 0 && options.InputBaudRate != options.BaudRate {
+		if !IsStandardBaudRate(options.BaudRate) || !IsStandardBaudRate(options.InputBaudRate) {
+			return nil, ErrUnsupportedBaudRate
+		}
+
+		result.c_ispeed = speed_t(options.InputBaudRate)
+	}
+
 	// Data bits
 	switch options.DataBits {
 	case 5:
@@ -157,17 +186,24 @@ func convertOptions(options OpenOptions) (*termios, error) {
 	case 8:
 		result.c_cflag |= kCS8
 	default:
-		return nil, errors.New("Invalid setting for DataBits.")
+		return nil, ErrInvalidDataBits
 	}
 
 	// Stop bits
 	switch options.StopBits {
-	case 1:
+	case OneStop:
 		// Nothing to do; CSTOPB is already cleared.
-	case 2:
+	case TwoStop:
+		result.c_cflag |= kCSTOPB
+	case OnePointFiveStop:
+		// UARTs send 1.5 stop bits for CSTOPB when the word length is 5.
+		if options.DataBits != 5 {
+			return nil, errOnePointFiveStop
+		}
+
 		result.c_cflag |= kCSTOPB
 	default:
-		return nil, errors.New("Invalid setting for StopBits.")
+		return nil, ErrInvalidStopBits
 	}
 
 	// Parity mode
@@ -186,17 +222,28 @@ func convertOptions(options OpenOptions) (*termios, error) {
 		// not setting INPCK). Leave out PARODD to use even mode.
 		result.c_cflag |= kPARENB
 	default:
-		return nil, errors.New("Invalid setting for ParityMode.")
+		// This includes PARITY_MARK and PARITY_SPACE, since OS X has no
+		// equivalent of Linux's CMSPAR.
+		return nil, ErrInvalidParityMode
 	}
 
 	if options.RTSCTSFlowControl {
 		result.c_cflag |= kCRTSCTS
 	}
 
+	if options.HangupOnClose {
+		result.c_cflag |= kHUPCL
+	}
+
 	return &result, nil
 }
 
-func openInternal(options OpenOptions) (io.ReadWriteCloser, error) {
+func openInternal(options OpenOptions) (port io.ReadWriteCloser, err error) {
+	// RS485 direction control is not available through the OS X drivers.
+	if options.Rs485Enable {
+		return nil, ErrRS485Unsupported
+	}
+
 	// Open the serial port in non-blocking mode, since otherwise the OS will
 	// wait for the CARRIER line to be asserted.
 	file, err :=
@@ -209,6 +256,13 @@ func openInternal(options OpenOptions) (io.ReadWriteCloser, error) {
 		return nil, err
 	}
 
+	// Don't leak the file if configuring it fails.
+	defer func() {
+		if err != nil {
+			file.Close()
+		}
+	}()
+
 	// We want to do blocking I/O, so clear the non-blocking flag set above.
 	r1, _, errno :=
 		syscall.Syscall(
@@ -245,7 +299,10 @@ func openInternal(options OpenOptions) (io.ReadWriteCloser, error) {
 			uintptr(unsafe.Pointer(&options.BaudRate)))
 
 		if errno2 != 0 {
-			return nil, os.NewSyscallError("SYS_IOCTL", errno2)
+			return nil, &PortError{
+				Kind: ErrUnsupportedBaudRate,
+				Err:  os.NewSyscallError("SYS_IOCTL", errno2),
+			}
 		}
 
 		if r2 != 0 {
@@ -253,6 +310,124 @@ func openInternal(options OpenOptions) (io.ReadWriteCloser, error) {
 		}
 	}
 
+	err = setInitialLines(file.Fd(), options)
+	if err != nil {
+		return nil, err
+	}
+
+	if !options.usesVMINVTIME() {
+		return &pollPort{File: file, timeouts: readTimeoutsFor(options)}, nil
+	}
+
 	// We're done.
 	return file, nil
 }
+
+// getBaudRates returns the speeds reported by the TIOCGETA ioctl.
+func getBaudRates(port io.ReadWriteCloser) (BaudRates, error) {
+	file, ok := port.(fder)
+	if !ok {
+		return BaudRates{}, fmt.Errorf("baud rates are not available for this port: %w", errors.ErrUnsupported)
+	}
+
+	var t termios
+	_, _, errno := syscall.Syscall(
+		syscall.SYS_IOCTL,
+		file.Fd(),
+		uintptr(kTIOCGETA),
+		uintptr(unsafe.Pointer(&t)))
+
+	if errno != 0 {
+		return BaudRates{}, os.NewSyscallError("SYS_IOCTL", errno)
+	}
+
+	return BaudRates{Input: uint(t.c_ispeed), Output: uint(t.c_ospeed)}, nil
+}
+
+// setInitialLines sets the DTR and RTS lines as requested by the options,
+// with the TIOCMBIS and TIOCMBIC ioctls.
+func setInitialLines(fd uintptr, options OpenOptions) error {
+	var set, clear int32
+	for _, l := range []struct {
+		state LineState
+		bit   int32
+	}{{options.InitialDTR, kTIOCM_DTR}, {options.InitialRTS, kTIOCM_RTS}} {
+		switch l.state {
+		case LINE_ON:
+			set |= l.bit
+		case LINE_OFF:
+			clear |= l.bit
+		}
+	}
+
+	for _, op := range []struct {
+		request uintptr
+		bits    int32
+	}{{kTIOCMBIS, set}, {kTIOCMBIC, clear}} {
+		if op.bits == 0 {
+			continue
+		}
+
+		_, _, errno := syscall.Syscall(
+			syscall.SYS_IOCTL,
+			fd,
+			op.request,
+			uintptr(unsafe.Pointer(&op.bits)))
+
+		if errno != 0 {
+			return os.NewSyscallError("SYS_IOCTL", errno)
+		}
+	}
+
+	return nil
+}
+
+// getModemLines returns the state of the port's modem lines, as reported by
+// the TIOCMGET ioctl.
+func getModemLines(port io.ReadWriteCloser) (ModemLines, error) {
+	file, ok := port.(fder)
+	if !ok {
+		return 0, errors.New("Modem lines are not available for this port.")
+	}
+
+	var status int32
+	r1, _, errno :=
+		syscall.Syscall(
+			syscall.SYS_IOCTL,
+			file.Fd(),
+			uintptr(kTIOCMGET),
+			uintptr(unsafe.Pointer(&status)))
+
+	if errno != 0 {
+		return 0, os.NewSyscallError("SYS_IOCTL", errno)
+	}
+
+	if r1 != 0 {
+		return 0, errors.New("Unknown error from SYS_IOCTL.")
+	}
+
+	var lines ModemLines
+	for _, m := range []struct {
+		bit  int32
+		line ModemLines
+	}{
+		{kTIOCM_DTR, MODEM_DTR},
+		{kTIOCM_RTS, MODEM_RTS},
+		{kTIOCM_CTS, MODEM_CTS},
+		{kTIOCM_DSR, MODEM_DSR},
+		{kTIOCM_CAR, MODEM_DCD},
+		{kTIOCM_RNG, MODEM_RI},
+	} {
+		if status&m.bit != 0 {
+			lines |= m.line
+		}
+	}
+
+	return lines, nil
+}
+
+// getKernelCounters returns nil, since the driver's error counters are not
+// available on this platform.
+func getKernelCounters(port io.ReadWriteCloser) (*KernelCounters, error) {
+	return nil, nil
+}
@@ -2,6 +2,7 @@ package serial
 
 import (
 	"errors"
+	"fmt"
 	"io"
 	"os"
 	"syscall"
@@ -19,14 +20,18 @@ import (
 //
 // int main(int argc, const char **argv) {
 //   printf("TCSETS2 = 0x%08X\n", TCSETS2);
+//   printf("TCGETS2 = 0x%08X\n", TCGETS2);
 //   printf("BOTHER  = 0x%08X\n", BOTHER);
+//   printf("IBSHIFT = %d\n",     IBSHIFT);
 //   printf("NCCS    = %d\n",     NCCS);
 //   return 0;
 // }
 //
 const (
 	kTCSETS2 = 0x402C542B
+	kTCGETS2 = 0x802C542A
 	kBOTHER  = 0x1000
+	kIBSHIFT = 16
 	kNCCS    = 19
 )
 
@@ -58,6 +63,22 @@ const (
 	tIOCSRS485               = 0x542F
 )
 
+// Constants and types for reading the driver's interrupt counters, from
+// asm-generic/ioctls.h and linux/serial.h.
+
+const (
+	tIOCGICOUNT = 0x545D
+)
+
+type serial_icounter_struct struct {
+	cts, dsr, rng, dcd int32
+	rx, tx             int32
+	frame, overrun     int32
+	parity, brk        int32
+	buf_overrun        int32
+	reserved           [9]int32
+}
+
 type serial_rs485 struct {
 	flags                 uint32
 	delay_rts_before_send uint32
@@ -72,17 +93,23 @@ type serial_rs485 struct {
 //
 func makeTermios2(options OpenOptions) (*termios2, error) {
 
-	// Sanity check inter-character timeout and minimum read size options.
+	// Sanity check inter-character timeout and minimum read size options. If
+	// both are zero, VMIN and VTIME are left at zero and the port waits for
+	// data with poll instead.
 
 	vtime := uint(round(float64(options.InterCharacterTimeout)/100.0) * 100)
 	vmin := options.MinimumReadSize
 
-	if vmin == 0 && vtime < 100 {
-		return nil, errors.New("invalid values for InterCharacterTimeout and MinimumReadSize")
+	if options.usesVMINVTIME() && vmin == 0 && vtime < 100 {
+		return nil, ErrInvalidTimeout
 	}
 
 	if vtime > 25500 {
-		return nil, errors.New("invalid value for InterCharacterTimeout")
+		return nil, ErrInvalidTimeout
+	}
+
+	if options.BaudRate == 0 {
+		return nil, ErrUnsupportedBaudRate
 	}
 
 	ccOpts := [kNCCS]cc_t{}
@@ -96,13 +123,28 @@ func makeTermios2(options OpenOptions) (*termios2, error) {
 		c_cc:     ccOpts,
 	}
 
+	// The input speed follows the output speed unless the CIBAUD bits are
+	// set too.
+	if options.InputBaudRate != 0 && options.InputBaudRate != options.BaudRate {
+		t2.c_cflag |= kBOTHER << kIBSHIFT
+		t2.c_ispeed = speed_t(options.InputBaudRate)
+	}
+
 	switch options.StopBits {
-	case 1:
-	case 2:
+	case OneStop:
+	case TwoStop:
+		t2.c_cflag |= syscall.CSTOPB
+
+	case OnePointFiveStop:
+		// UARTs send 1.5 stop bits for CSTOPB when the word length is 5.
+		if options.DataBits != 5 {
+			return nil, errOnePointFiveStop
+		}
+
 		t2.c_cflag |= syscall.CSTOPB
 
 	default:
-		return nil, errors.New("invalid setting for StopBits")
+		return nil, ErrInvalidStopBits
 	}
 
 	switch options.ParityMode {
@@ -114,8 +156,17 @@ func makeTermios2(options OpenOptions) (*termios2, error) {
 	case PARITY_EVEN:
 		t2.c_cflag |= syscall.PARENB
 
+	case PARITY_MARK:
+		t2.c_cflag |= syscall.PARENB
+		t2.c_cflag |= unix.CMSPAR
+		t2.c_cflag |= syscall.PARODD
+
+	case PARITY_SPACE:
+		t2.c_cflag |= syscall.PARENB
+		t2.c_cflag |= unix.CMSPAR
+
 	default:
-		return nil, errors.New("invalid setting for ParityMode")
+		return nil, ErrInvalidParityMode
 	}
 
 	switch options.DataBits {
@@ -128,17 +179,21 @@ func makeTermios2(options OpenOptions) (*termios2, error) {
 	case 8:
 		t2.c_cflag |= syscall.CS8
 	default:
-		return nil, errors.New("invalid setting for DataBits")
+		return nil, ErrInvalidDataBits
 	}
 
 	if options.RTSCTSFlowControl {
 		t2.c_cflag |= unix.CRTSCTS
 	}
 
+	if options.HangupOnClose {
+		t2.c_cflag |= syscall.HUPCL
+	}
+
 	return t2, nil
 }
 
-func openInternal(options OpenOptions) (io.ReadWriteCloser, error) {
+func openInternal(options OpenOptions) (port io.ReadWriteCloser, err error) {
 
 	file, openErr :=
 		os.OpenFile(
@@ -149,6 +204,13 @@ func openInternal(options OpenOptions) (io.ReadWriteCloser, error) {
 		return nil, openErr
 	}
 
+	// Don't leak the file if configuring it fails.
+	defer func() {
+		if err != nil {
+			file.Close()
+		}
+	}()
+
 	// Clear the non-blocking flag set above.
 	nonblockErr := syscall.SetNonblock(int(file.Fd()), false)
 	if nonblockErr != nil {
@@ -196,6 +258,13 @@ func openInternal(options OpenOptions) (io.ReadWriteCloser, error) {
 			uintptr(tIOCSRS485),
 			uintptr(unsafe.Pointer(&rs485)))
 
+		if errno == syscall.ENOTTY || errno == syscall.EINVAL {
+			return nil, &PortError{
+				Kind: ErrRS485Unsupported,
+				Err:  os.NewSyscallError("SYS_IOCTL (RS485)", errno),
+			}
+		}
+
 		if errno != 0 {
 			return nil, os.NewSyscallError("SYS_IOCTL (RS485)", errno)
 		}
@@ -205,5 +274,141 @@ func openInternal(options OpenOptions) (io.ReadWriteCloser, error) {
 		}
 	}
 
+	if err := setInitialLines(int(file.Fd()), options); err != nil {
+		return nil, err
+	}
+
+	if !options.usesVMINVTIME() {
+		return &pollPort{File: file, timeouts: readTimeoutsFor(options)}, nil
+	}
+
 	return file, nil
 }
+
+// setInitialLines sets the DTR and RTS lines as requested by the options,
+// with the TIOCMBIS and TIOCMBIC ioctls.
+func setInitialLines(fd int, options OpenOptions) error {
+	var set, clear int
+	for _, l := range []struct {
+		state LineState
+		bit   int
+	}{{options.InitialDTR, unix.TIOCM_DTR}, {options.InitialRTS, unix.TIOCM_RTS}} {
+		switch l.state {
+		case LINE_ON:
+			set |= l.bit
+		case LINE_OFF:
+			clear |= l.bit
+		}
+	}
+
+	if set != 0 {
+		if err := unix.IoctlSetPointerInt(fd, unix.TIOCMBIS, set); err != nil {
+			return os.NewSyscallError("SYS_IOCTL (TIOCMBIS)", err)
+		}
+	}
+
+	if clear != 0 {
+		if err := unix.IoctlSetPointerInt(fd, unix.TIOCMBIC, clear); err != nil {
+			return os.NewSyscallError("SYS_IOCTL (TIOCMBIC)", err)
+		}
+	}
+
+	return nil
+}
+
+// getBaudRates returns the speeds reported by the TCGETS2 ioctl, which the
+// driver updates to those it actually achieved.
+func getBaudRates(port io.ReadWriteCloser) (BaudRates, error) {
+	file, ok := port.(fder)
+	if !ok {
+		return BaudRates{}, fmt.Errorf("baud rates are not available for this port: %w", errors.ErrUnsupported)
+	}
+
+	var t2 termios2
+	_, _, errno := syscall.Syscall(
+		syscall.SYS_IOCTL,
+		file.Fd(),
+		uintptr(kTCGETS2),
+		uintptr(unsafe.Pointer(&t2)))
+
+	if errno != 0 {
+		return BaudRates{}, os.NewSyscallError("SYS_IOCTL (TCGETS2)", errno)
+	}
+
+	return BaudRates{Input: uint(t2.c_ispeed), Output: uint(t2.c_ospeed)}, nil
+}
+
+// getModemLines returns the state of the port's modem lines, as reported by
+// the TIOCMGET ioctl.
+func getModemLines(port io.ReadWriteCloser) (ModemLines, error) {
+	file, ok := port.(fder)
+	if !ok {
+		return 0, errors.New("modem lines are not available for this port")
+	}
+
+	status, err := unix.IoctlGetInt(int(file.Fd()), unix.TIOCMGET)
+	if err != nil {
+		return 0, os.NewSyscallError("SYS_IOCTL (TIOCMGET)", err)
+	}
+
+	var lines ModemLines
+	for _, m := range tiocmLines {
+		if status&m.bit != 0 {
+			lines |= m.line
+		}
+	}
+
+	return lines, nil
+}
+
+// The TIOCM_* bit corresponding to each modem line.
+var tiocmLines = []struct {
+	bit  int
+	line ModemLines
+}{
+	{unix.TIOCM_DTR, MODEM_DTR},
+	{unix.TIOCM_RTS, MODEM_RTS},
+	{unix.TIOCM_CTS, MODEM_CTS},
+	{unix.TIOCM_DSR, MODEM_DSR},
+	{unix.TIOCM_CD, MODEM_DCD},
+	{unix.TIOCM_RI, MODEM_RI},
+}
+
+// getKernelCounters returns the driver's interrupt counters for the port, or
+// nil if the driver does not keep them (as is the case for ptys and some USB
+// adapters).
+func getKernelCounters(port io.ReadWriteCloser) (*KernelCounters, error) {
+	file, ok := port.(fder)
+	if !ok {
+		return nil, nil
+	}
+
+	var ic serial_icounter_struct
+	_, _, errno := syscall.Syscall(
+		syscall.SYS_IOCTL,
+		uintptr(file.Fd()),
+		uintptr(tIOCGICOUNT),
+		uintptr(unsafe.Pointer(&ic)))
+
+	switch errno {
+	case 0:
+	case syscall.ENOTTY, syscall.EINVAL:
+		return nil, nil
+	default:
+		return nil, os.NewSyscallError("SYS_IOCTL (TIOCGICOUNT)", errno)
+	}
+
+	return &KernelCounters{
+		CTS:        uint64(uint32(ic.cts)),
+		DSR:        uint64(uint32(ic.dsr)),
+		RNG:        uint64(uint32(ic.rng)),
+		DCD:        uint64(uint32(ic.dcd)),
+		RX:         uint64(uint32(ic.rx)),
+		TX:         uint64(uint32(ic.tx)),
+		Frame:      uint64(uint32(ic.frame)),
+		Overrun:    uint64(uint32(ic.overrun)),
+		Parity:     uint64(uint32(ic.parity)),
+		Brk:        uint64(uint32(ic.brk)),
+		BufOverrun: uint64(uint32(ic.buf_overrun)),
+	}, nil
+}
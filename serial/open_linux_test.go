@@ -0,0 +1,112 @@
+package serial
+
+import (
+	"errors"
+	"syscall"
+	"testing"
+)
+
+func TestMakeTermios2Errors(t *testing.T) {
+	valid := OpenOptions{
+		BaudRate:        9600,
+		DataBits:        8,
+		StopBits:        1,
+		MinimumReadSize: 1,
+	}
+
+	testCases := []struct {
+		Name   string
+		Modify func(*OpenOptions)
+		Err    error
+	}{
+		{"data bits", func(o *OpenOptions) { o.DataBits = 9 }, ErrInvalidDataBits},
+		{"stop bits", func(o *OpenOptions) { o.StopBits = 4 }, ErrInvalidStopBits},
+		{"1.5 stop bits", func(o *OpenOptions) { o.StopBits = OnePointFiveStop }, ErrInvalidStopBits},
+		{"parity", func(o *OpenOptions) { o.ParityMode = 7 }, ErrInvalidParityMode},
+		{"timeout", func(o *OpenOptions) { o.MinimumReadSize, o.InterCharacterTimeout = 0, 40 }, ErrInvalidTimeout},
+		{"baud rate", func(o *OpenOptions) { o.BaudRate = 0 }, ErrUnsupportedBaudRate},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			options := valid
+			testCase.Modify(&options)
+
+			_, err := makeTermios2(options)
+			if !errors.Is(err, testCase.Err) {
+				t.Errorf("expected %v, got %v", testCase.Err, err)
+			}
+		})
+	}
+
+	if _, err := makeTermios2(valid); err != nil {
+		t.Errorf("unexpected error for valid options: %v", err)
+	}
+}
+
+func TestMakeTermios2HangupOnClose(t *testing.T) {
+	options := OpenOptions{
+		BaudRate:        9600,
+		DataBits:        8,
+		StopBits:        1,
+		MinimumReadSize: 1,
+	}
+
+	t2, err := makeTermios2(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if t2.c_cflag&syscall.HUPCL != 0 {
+		t.Error("HUPCL set by default")
+	}
+
+	options.HangupOnClose = true
+	t2, err = makeTermios2(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if t2.c_cflag&syscall.HUPCL == 0 {
+		t.Error("HUPCL not set for HangupOnClose")
+	}
+}
+
+func TestMakeTermios2InputBaudRate(t *testing.T) {
+	t2, err := makeTermios2(OpenOptions{
+		BaudRate:        9600,
+		InputBaudRate:   1200,
+		DataBits:        8,
+		StopBits:        1,
+		MinimumReadSize: 1,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if t2.c_ospeed != 9600 || t2.c_ispeed != 1200 {
+		t.Errorf("expected speeds 1200/9600, got %d/%d", t2.c_ispeed, t2.c_ospeed)
+	}
+
+	if t2.c_cflag&(kBOTHER<<kIBSHIFT) == 0 {
+		t.Error("input speed not enabled with CIBAUD")
+	}
+}
+
+func TestMakeTermios2OnePointFiveStop(t *testing.T) {
+	t2, err := makeTermios2(OpenOptions{
+		BaudRate:        9600,
+		DataBits:        5,
+		StopBits:        OnePointFiveStop,
+		MinimumReadSize: 1,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if t2.c_cflag&syscall.CSTOPB == 0 || t2.c_cflag&syscall.CSIZE != syscall.CS5 {
+		t.Errorf("expected CSTOPB with CS5, got c_cflag %#x", t2.c_cflag)
+	}
+}
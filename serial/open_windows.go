@@ -15,11 +15,13 @@
 package serial
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"sync"
 	"syscall"
+	"time"
 	"unsafe"
 )
 
@@ -50,6 +52,15 @@ type structTimeouts struct {
 }
 
 func openInternal(options OpenOptions) (io.ReadWriteCloser, error) {
+	if options.Rs485Enable {
+		return nil, ErrRS485Unsupported
+	}
+
+	// The DCB has a single speed for both directions.
+	if options.InputBaudRate != 0 && options.InputBaudRate != options.BaudRate {
+		return nil, ErrUnsupportedBaudRate
+	}
+
 	if len(options.PortName) > 0 && options.PortName[0] != '\\' {
 		options.PortName = "\\\\.\\" + options.PortName
 	}
@@ -61,6 +72,10 @@ func openInternal(options OpenOptions) (io.ReadWriteCloser, error) {
 		syscall.OPEN_EXISTING,
 		syscall.FILE_ATTRIBUTE_NORMAL|syscall.FILE_FLAG_OVERLAPPED,
 		0)
+	if err == syscall.ERROR_ACCESS_DENIED {
+		// This is what Windows reports when another process has the port open.
+		return nil, &PortError{Kind: ErrPortBusy, Err: err}
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -101,6 +116,10 @@ func openInternal(options OpenOptions) (io.ReadWriteCloser, error) {
 	return port, nil
 }
 
+func (p *serialPort) file() *os.File {
+	return p.f
+}
+
 func (p *serialPort) Close() error {
 	return p.f.Close()
 }
@@ -141,9 +160,11 @@ func (p *serialPort) Read(buf []byte) (int, error) {
 
 var (
 	nSetCommState,
+	nGetCommState,
 	nSetCommTimeouts,
 	nSetCommMask,
 	nSetupComm,
+	nGetCommModemStatus,
 	nGetOverlappedResult,
 	nCreateEvent,
 	nResetEvent uintptr
@@ -157,9 +178,11 @@ func init() {
 	defer syscall.FreeLibrary(k32)
 
 	nSetCommState = getProcAddr(k32, "SetCommState")
+	nGetCommState = getProcAddr(k32, "GetCommState")
 	nSetCommTimeouts = getProcAddr(k32, "SetCommTimeouts")
 	nSetCommMask = getProcAddr(k32, "SetCommMask")
 	nSetupComm = getProcAddr(k32, "SetupComm")
+	nGetCommModemStatus = getProcAddr(k32, "GetCommModemStatus")
 	nGetOverlappedResult = getProcAddr(k32, "GetOverlappedResult")
 	nCreateEvent = getProcAddr(k32, "CreateEventW")
 	nResetEvent = getProcAddr(k32, "ResetEvent")
@@ -177,18 +200,42 @@ func setCommState(h syscall.Handle, options OpenOptions) error {
 	var params structDCB
 	params.DCBlength = uint32(unsafe.Sizeof(params))
 
-	params.flags[0] = 0x01  // fBinary
-	params.flags[0] |= 0x10 // Assert DSR
+	params.flags[0] = 0x01 // fBinary
+	if options.InitialDTR != LINE_OFF {
+		params.flags[0] |= 0x10 // fDtrControl = DTR_CONTROL_ENABLE (0x1)
+	}
+
+	if options.InitialRTS == LINE_ON {
+		params.flags[1] |= 0x10 // fRtsControl = RTS_CONTROL_ENABLE (0x1)
+	}
 
 	if options.ParityMode != PARITY_NONE {
 		params.flags[0] |= 0x03 // fParity
 		params.Parity = byte(options.ParityMode)
 	}
 
-	if options.StopBits == 1 {
-		params.StopBits = 0
-	} else if options.StopBits == 2 {
-		params.StopBits = 2
+	// Windows rejects 1.5 stop bits with more than 5 data bits, and 2 stop
+	// bits with 5 data bits.
+	switch options.StopBits {
+	case OneStop:
+		params.StopBits = 0 // ONESTOPBIT
+	case OnePointFiveStop:
+		if options.DataBits != 5 {
+			return errOnePointFiveStop
+		}
+
+		params.StopBits = 1 // ONE5STOPBITS
+	case TwoStop:
+		if options.DataBits == 5 {
+			return &PortError{
+				Kind: ErrInvalidStopBits,
+				Err:  errors.New("2 stop bits cannot be used with 5 data bits"),
+			}
+		}
+
+		params.StopBits = 2 // TWOSTOPBITS
+	default:
+		return ErrInvalidStopBits
 	}
 
 	params.BaudRate = uint32(options.BaudRate)
@@ -212,7 +259,26 @@ func setCommTimeouts(h syscall.Handle, options OpenOptions) error {
 	timeoutConstant := uint32(round(float64(options.InterCharacterTimeout) / 100.0))
 	readIntervalTimeout := uint32(options.MinimumReadSize)
 
-	if timeoutConstant > 0 && readIntervalTimeout == 0 {
+	if !options.usesVMINVTIME() {
+		// ReadTimeout and InterByteTimeout map directly onto the total and
+		// interval timeouts, which are in milliseconds. A zero total timeout
+		// waits forever for the first byte.
+		total := millis(options.ReadTimeout)
+		if options.InterByteTimeout == 0 {
+			// Return as soon as any data arrives, as described below.
+			if total == 0 {
+				total = MAXDWORD - 1
+			}
+
+			timeouts.ReadIntervalTimeout = MAXDWORD
+			timeouts.ReadTotalTimeoutMultiplier = MAXDWORD
+			timeouts.ReadTotalTimeoutConstant = total
+		} else {
+			// The interval timer starts with the first byte received.
+			timeouts.ReadIntervalTimeout = millis(options.InterByteTimeout)
+			timeouts.ReadTotalTimeoutConstant = total
+		}
+	} else if timeoutConstant > 0 && readIntervalTimeout == 0 {
 		//Assume we're setting for non blocking IO.
 		timeouts.ReadIntervalTimeout = MAXDWORD
 		timeouts.ReadTotalTimeoutMultiplier = MAXDWORD
@@ -273,6 +339,18 @@ func setCommTimeouts(h syscall.Handle, options OpenOptions) error {
 	return nil
 }
 
+// millis converts d to whole milliseconds for COMMTIMEOUTS, rounding up and
+// clamping to the largest value that doesn't have a special meaning.
+func millis(d time.Duration) uint32 {
+	const limit = 1<<32 - 2
+	ms := (d + time.Millisecond - 1) / time.Millisecond
+	if ms > limit {
+		return limit
+	}
+
+	return uint32(ms)
+}
+
 func setupComm(h syscall.Handle, in, out int) error {
 	r, _, err := syscall.Syscall(nSetupComm, 3, uintptr(h), uintptr(in), uintptr(out))
 	if r == 0 {
@@ -290,6 +368,62 @@ func setCommMask(h syscall.Handle) error {
 	return nil
 }
 
+// getBaudRates returns the speed recorded in the port's DCB by
+// GetCommState. Windows uses the same speed in both directions.
+func getBaudRates(port io.ReadWriteCloser) (BaudRates, error) {
+	p, ok := port.(*serialPort)
+	if !ok {
+		return BaudRates{}, fmt.Errorf("baud rates are not available for this port: %w", errors.ErrUnsupported)
+	}
+
+	var params structDCB
+	params.DCBlength = uint32(unsafe.Sizeof(params))
+	r, _, err := syscall.Syscall(nGetCommState, 2, uintptr(p.fd), uintptr(unsafe.Pointer(&params)), 0)
+	if r == 0 {
+		return BaudRates{}, err
+	}
+
+	return BaudRates{Input: uint(params.BaudRate), Output: uint(params.BaudRate)}, nil
+}
+
+// getModemLines returns the state of the port's input modem lines, as
+// reported by GetCommModemStatus. Windows does not report the output lines.
+func getModemLines(port io.ReadWriteCloser) (ModemLines, error) {
+	const (
+		MS_CTS_ON  = 0x0010
+		MS_DSR_ON  = 0x0020
+		MS_RING_ON = 0x0040
+		MS_RLSD_ON = 0x0080
+	)
+
+	p, ok := port.(*serialPort)
+	if !ok {
+		return 0, fmt.Errorf("modem lines are not available for this port")
+	}
+
+	var status uint32
+	r, _, err := syscall.Syscall(nGetCommModemStatus, 2, uintptr(p.fd), uintptr(unsafe.Pointer(&status)), 0)
+	if r == 0 {
+		return 0, err
+	}
+
+	var lines ModemLines
+	if status&MS_CTS_ON != 0 {
+		lines |= MODEM_CTS
+	}
+	if status&MS_DSR_ON != 0 {
+		lines |= MODEM_DSR
+	}
+	if status&MS_RING_ON != 0 {
+		lines |= MODEM_RI
+	}
+	if status&MS_RLSD_ON != 0 {
+		lines |= MODEM_DCD
+	}
+
+	return lines, nil
+}
+
 func resetEvent(h syscall.Handle) error {
 	r, _, err := syscall.Syscall(nResetEvent, 1, uintptr(h), 0, 0)
 	if r == 0 {
@@ -320,3 +454,9 @@ func getOverlappedResult(h syscall.Handle, overlapped *syscall.Overlapped) (int,
 
 	return n, nil
 }
+
+// getKernelCounters returns nil, since the driver's error counters are not
+// available on this platform.
+func getKernelCounters(port io.ReadWriteCloser) (*KernelCounters, error) {
+	return nil, nil
+}
@@ -0,0 +1,50 @@
+// Copyright 2011 Aaron Jacobs. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package serial
+
+import (
+	"os"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// waitReadable waits up to the supplied time for fd to become readable. It
+// returns false if the time passes first or the wait is interrupted by a
+// signal.
+//
+// OS X's poll(2) does not support terminal devices, returning POLLNVAL for
+// them, so this uses select(2) instead. A line that has hung up is reported
+// as readable, and the read that follows returns end of file.
+func waitReadable(fd int, wait time.Duration) (bool, error) {
+	if fd >= unix.FD_SETSIZE {
+		return false, os.NewSyscallError("select", unix.EINVAL)
+	}
+
+	var fds unix.FdSet
+	fds.Set(fd)
+
+	timeout := unix.NsecToTimeval(wait.Nanoseconds())
+	n, err := unix.Select(fd+1, &fds, nil, nil, &timeout)
+	if err == unix.EINTR {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, os.NewSyscallError("select", err)
+	}
+
+	return n > 0 && fds.IsSet(fd), nil
+}
@@ -0,0 +1,60 @@
+// Copyright 2011 Aaron Jacobs. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package serial
+
+import (
+	"io"
+	"os"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// waitReadable waits up to the supplied time for fd to become readable, using
+// poll(2). It returns false if the time passes first or the wait is
+// interrupted by a signal, and io.EOF if the line has hung up with no data
+// left to read.
+func waitReadable(fd int, wait time.Duration) (bool, error) {
+	fds := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}}
+
+	// Round up, so as not to wake just before the deadline.
+	ms := int((wait + time.Millisecond - 1) / time.Millisecond)
+
+	n, err := unix.Poll(fds, ms)
+	if err == unix.EINTR || (err == nil && n == 0) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, os.NewSyscallError("poll", err)
+	}
+
+	revents := fds[0].Revents
+	switch {
+	case revents&unix.POLLNVAL != 0:
+		return false, os.NewSyscallError("poll", unix.EBADF)
+
+	case revents&unix.POLLIN != 0:
+		return true, nil
+
+	case revents&unix.POLLERR != 0:
+		return false, os.NewSyscallError("poll", unix.EIO)
+
+	case revents&unix.POLLHUP != 0:
+		return false, io.EOF
+	}
+
+	return false, nil
+}
@@ -0,0 +1,43 @@
+package serial
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestWaitReadable(t *testing.T) {
+	var p [2]int
+	if err := unix.Pipe(p[:]); err != nil {
+		t.Fatal(err)
+	}
+
+	r, w := p[0], p[1]
+	defer unix.Close(r)
+
+	if ready, err := waitReadable(r, 10*time.Millisecond); ready || err != nil {
+		t.Errorf("empty pipe: expected false, nil; got %v, %v", ready, err)
+	}
+
+	unix.Write(w, []byte("x"))
+	unix.Close(w)
+
+	// Data left after the hang-up is still readable.
+	if ready, err := waitReadable(r, 10*time.Millisecond); !ready || err != nil {
+		t.Errorf("pending data: expected true, nil; got %v, %v", ready, err)
+	}
+
+	unix.Read(r, make([]byte, 1))
+
+	if _, err := waitReadable(r, 10*time.Millisecond); err != io.EOF {
+		t.Errorf("hung up: expected io.EOF, got %v", err)
+	}
+
+	// A descriptor that isn't open gives POLLNVAL.
+	if _, err := waitReadable(w, 10*time.Millisecond); !errors.Is(err, unix.EBADF) {
+		t.Errorf("closed descriptor: expected EBADF, got %v", err)
+	}
+}
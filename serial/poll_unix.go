@@ -0,0 +1,127 @@
+// Copyright 2011 Aaron Jacobs. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux || darwin
+
+package serial
+
+import (
+	"io"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// The longest a single waitReadable call waits, so that a blocked Read notices
+// within this time that the port has been closed.
+const kMaxPollWait = 100 * time.Millisecond
+
+// fder is implemented by *os.File and by *pollPort, giving the OS-specific
+// code access to the port's file descriptor.
+type fder interface {
+	Fd() uintptr
+}
+
+// pollPort is a terminal configured with VMIN = VTIME = 0, so that read never
+// blocks, which waits for data with waitReadable in order to implement
+// ReadTimeout and InterByteTimeout with millisecond precision.
+type pollPort struct {
+	*os.File
+	timeouts readTimeouts
+	closed   atomic.Bool
+}
+
+func (p *pollPort) Read(b []byte) (int, error) {
+	if len(b) == 0 {
+		return 0, nil
+	}
+
+	return p.timeouts.read(b, p.readUntil)
+}
+
+func (p *pollPort) readUntil(b []byte, deadline time.Time) (n int, err error) {
+	conn, err := p.File.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+
+	var readErr error
+	err = conn.Control(func(fd uintptr) {
+		n, readErr = p.pollRead(int(fd), b, deadline)
+	})
+
+	if err == nil {
+		err = readErr
+	}
+
+	return n, err
+}
+
+// pollRead waits until fd is readable or the deadline passes, then reads
+// whatever is available.
+func (p *pollPort) pollRead(fd int, b []byte, deadline time.Time) (int, error) {
+	for {
+		if p.closed.Load() {
+			return 0, os.ErrClosed
+		}
+
+		wait := kMaxPollWait
+		if !deadline.IsZero() {
+			remaining := time.Until(deadline)
+			if remaining <= 0 {
+				return 0, nil
+			}
+
+			if remaining < wait {
+				wait = remaining
+			}
+		}
+
+		ready, err := waitReadable(fd, wait)
+		if err != nil {
+			return 0, err
+		}
+
+		if !ready {
+			continue
+		}
+
+		n, err := unix.Read(fd, b)
+		if err == unix.EINTR || err == unix.EAGAIN {
+			continue
+		}
+
+		if err != nil {
+			return 0, os.NewSyscallError("read", err)
+		}
+
+		// Readable but empty means the line has hung up.
+		if n == 0 {
+			return 0, io.EOF
+		}
+
+		return n, nil
+	}
+}
+
+func (p *pollPort) file() *os.File {
+	return p.File
+}
+
+func (p *pollPort) Close() error {
+	p.closed.Store(true)
+	return p.File.Close()
+}
@@ -0,0 +1,242 @@
+// Copyright 2011 Aaron Jacobs. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package serial
+
+import (
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// How often the modem lines of a tapped port are polled for changes.
+const kModemPollInterval = 100 * time.Millisecond
+
+// Port is an open serial port, as returned by OpenPort. It wraps the
+// OS-specific implementation, keeping traffic counters and reporting every
+// operation to OpenOptions.Tap if one was set.
+type Port struct {
+	f        io.ReadWriteCloser
+	tap      Tap
+	name     string
+	peerName string
+
+	bytesRead    atomic.Uint64
+	bytesWritten atomic.Uint64
+	reads        atomic.Uint64
+	writes       atomic.Uint64
+	readErrors   atomic.Uint64
+	writeErrors  atomic.Uint64
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+func newPort(f io.ReadWriteCloser, tap Tap) *Port {
+	p := &Port{
+		f:    f,
+		tap:  tap,
+		done: make(chan struct{}),
+	}
+
+	// Only poll if someone is listening and the device can report its modem
+	// lines at all.
+	if tap != nil {
+		if lines, err := p.modemLines(); err == nil {
+			go p.pollModemLines(lines)
+		}
+	}
+
+	return p
+}
+
+func (p *Port) pollModemLines(last ModemLines) {
+	ticker := time.NewTicker(kModemPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.done:
+			return
+
+		case <-ticker.C:
+			lines, err := p.modemLines()
+			if err != nil {
+				// The port has probably been closed or unplugged; the error will
+				// surface through Read or Write.
+				return
+			}
+
+			if lines != last {
+				p.tap.OnModemLines(last, lines)
+				last = lines
+			}
+		}
+	}
+}
+
+// modemLines returns the state of the modem lines, asking the backend for
+// them if it is not an OS device.
+func (p *Port) modemLines() (ModemLines, error) {
+	if m, ok := p.f.(interface{ modemLines() (ModemLines, error) }); ok {
+		return m.modemLines()
+	}
+
+	return getModemLines(p.f)
+}
+
+// PeerName returns the path of the other end of the pseudo-terminal for a
+// port opened with a pty:// URL, suitable for passing to Open. For other
+// ports it returns the empty string.
+func (p *Port) PeerName() string {
+	return p.peerName
+}
+
+// File returns the *os.File behind the port, for callers that need its file
+// descriptor or handle, or nil if there is none, as for loop:// and
+// rfc2217:// ports. Reading from, writing to or closing it directly bypasses
+// the Port's counters and Tap.
+func (p *Port) File() *os.File {
+	switch f := p.f.(type) {
+	case *os.File:
+		return f
+
+	case interface{ file() *os.File }:
+		return f.file()
+	}
+
+	return nil
+}
+
+// Read reads up to len(b) bytes from the port, subject to the timeouts set
+// in OpenOptions.
+func (p *Port) Read(b []byte) (int, error) {
+	n, err := p.f.Read(b)
+
+	p.reads.Add(1)
+	p.bytesRead.Add(uint64(n))
+	if err != nil && err != io.EOF {
+		p.readErrors.Add(1)
+	}
+
+	if p.tap != nil {
+		if n > 0 {
+			p.tap.OnRead(b[:n])
+		}
+
+		if err != nil && err != io.EOF {
+			p.tap.OnError("read", err)
+		}
+	}
+
+	return n, err
+}
+
+// Write writes b to the port.
+func (p *Port) Write(b []byte) (int, error) {
+	n, err := p.f.Write(b)
+
+	p.writes.Add(1)
+	p.bytesWritten.Add(uint64(n))
+	if err != nil {
+		p.writeErrors.Add(1)
+	}
+
+	if p.tap != nil {
+		if n > 0 {
+			p.tap.OnWrite(b[:n])
+		}
+
+		if err != nil {
+			p.tap.OnError("write", err)
+		}
+	}
+
+	return n, err
+}
+
+// Close closes the port.
+func (p *Port) Close() error {
+	p.closeOnce.Do(func() { close(p.done) })
+
+	err := p.f.Close()
+	if err != nil && p.tap != nil {
+		p.tap.OnError("close", err)
+	}
+
+	return err
+}
+
+// Stats holds counters for an open port.
+type Stats struct {
+	// Totals for the calls to Read and Write made through the port, counting
+	// every call whether or not it transferred any data.
+	BytesRead    uint64
+	BytesWritten uint64
+	Reads        uint64
+	Writes       uint64
+	ReadErrors   uint64
+	WriteErrors  uint64
+
+	// Counters maintained by the driver, or nil if the platform or device
+	// does not provide them. Currently these are available only on Linux.
+	Kernel *KernelCounters
+}
+
+// KernelCounters holds the interrupt counters that the Linux serial drivers
+// keep for a port, as returned by the TIOCGICOUNT ioctl. They count events
+// since the driver was loaded, not since the port was opened, so compare
+// successive values rather than looking at absolute numbers.
+type KernelCounters struct {
+	// Transitions of the modem status lines.
+	CTS uint64
+	DSR uint64
+	RNG uint64
+	DCD uint64
+
+	// Bytes received and transmitted by the UART.
+	RX uint64
+	TX uint64
+
+	// Receive errors.
+	Frame      uint64 // Framing errors
+	Overrun    uint64 // Hardware FIFO overruns
+	Parity     uint64 // Parity errors
+	Brk        uint64 // Break conditions
+	BufOverrun uint64 // Data dropped because the tty buffer was full
+}
+
+// Stats returns the port's traffic counters, along with the driver's error
+// and line-event counters where available. An error is returned only if the
+// driver claims to support the counters but fails to report them.
+func (p *Port) Stats() (Stats, error) {
+	s := Stats{
+		BytesRead:    p.bytesRead.Load(),
+		BytesWritten: p.bytesWritten.Load(),
+		Reads:        p.reads.Load(),
+		Writes:       p.writes.Load(),
+		ReadErrors:   p.readErrors.Load(),
+		WriteErrors:  p.writeErrors.Load(),
+	}
+
+	kernel, err := getKernelCounters(p.f)
+	if err != nil {
+		return s, err
+	}
+
+	s.Kernel = kernel
+	return s, nil
+}
@@ -0,0 +1,54 @@
+package serial
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPortStats(t *testing.T) {
+	fake := &fakePort{In: strings.NewReader("hello")}
+	port := newPort(fake, nil)
+
+	port.Write([]byte("abc"))
+	port.Write([]byte("de"))
+
+	buf := make([]byte, 3)
+	port.Read(buf)
+	port.Read(buf)
+	port.Read(buf)
+
+	fake.WriteErr = errors.New("unplugged")
+	port.Write([]byte("f"))
+
+	stats, err := port.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := Stats{
+		BytesRead:    5,
+		BytesWritten: 5,
+		Reads:        3,
+		Writes:       3,
+		ReadErrors:   0,
+		WriteErrors:  1,
+	}
+
+	if stats != expected {
+		t.Errorf("expected %+v, got %+v", expected, stats)
+	}
+}
+
+func TestPortFile(t *testing.T) {
+	port, err := OpenURL("loop://")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer port.Close()
+
+	if f := port.File(); f != nil {
+		t.Errorf("expected no file behind a loop:// port, got %v", f)
+	}
+}
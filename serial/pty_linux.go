@@ -0,0 +1,114 @@
+// Copyright 2011 Aaron Jacobs. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package serial
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// ptyPort is the master side of a pseudo-terminal opened for a pty:// URL.
+type ptyPort struct {
+	master   *os.File
+	timeouts readTimeouts
+
+	// The slave side is held open for the life of the port. This keeps the
+	// raw line settings in place and stops reads from the master failing with
+	// EIO whenever nobody else has the slave open.
+	slave io.ReadWriteCloser
+}
+
+// openPTY creates a pseudo-terminal, configures its slave side according to
+// the options and returns its master side along with the slave's path.
+func openPTY(options OpenOptions) (port io.ReadWriteCloser, peerName string, err error) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, "", err
+	}
+
+	defer func() {
+		if err != nil {
+			master.Close()
+		}
+	}()
+
+	// Use the raw connection rather than master.Fd(), which would take the
+	// file out of the runtime poller and lose support for read deadlines.
+	conn, err := master.SyscallConn()
+	if err != nil {
+		return nil, "", err
+	}
+
+	var n int
+	var ioctlErr error
+	err = conn.Control(func(fd uintptr) {
+		ioctlErr = unix.IoctlSetPointerInt(int(fd), unix.TIOCSPTLCK, 0)
+		if ioctlErr == nil {
+			n, ioctlErr = unix.IoctlGetInt(int(fd), unix.TIOCGPTN)
+		}
+	})
+
+	if err == nil {
+		err = ioctlErr
+	}
+
+	if err != nil {
+		return nil, "", os.NewSyscallError("SYS_IOCTL (pty)", err)
+	}
+
+	slaveOptions := options
+	slaveOptions.PortName = fmt.Sprintf("/dev/pts/%d", n)
+
+	slave, err := openInternal(slaveOptions)
+	if err != nil {
+		return nil, "", err
+	}
+
+	p := &ptyPort{
+		master:   master,
+		timeouts: readTimeoutsFor(options),
+		slave:    slave,
+	}
+
+	return p, slaveOptions.PortName, nil
+}
+
+func (p *ptyPort) Read(b []byte) (int, error) {
+	return p.timeouts.read(b, func(b []byte, deadline time.Time) (int, error) {
+		return readWithDeadline(p.master, deadline, b)
+	})
+}
+
+func (p *ptyPort) Write(b []byte) (int, error) {
+	return p.master.Write(b)
+}
+
+func (p *ptyPort) file() *os.File {
+	return p.master
+}
+
+func (p *ptyPort) Close() error {
+	slaveErr := p.slave.Close()
+	if err := p.master.Close(); err != nil {
+		return err
+	}
+
+	return slaveErr
+}
@@ -0,0 +1,298 @@
+package serial
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPTY(t *testing.T) {
+	master, err := OpenURL("pty://?baud=115200&timeout=500ms&minread=0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer master.Close()
+
+	if master.PeerName() == "" {
+		t.Fatal("expected a peer name")
+	}
+
+	slave, err := Open(OpenOptions{
+		PortName:              master.PeerName(),
+		BaudRate:              115200,
+		DataBits:              8,
+		StopBits:              1,
+		InterCharacterTimeout: 500,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer slave.Close()
+
+	// Send bytes that a cooked terminal would mangle, in both directions.
+	data := []byte{0x00, '\r', '\n', 0x03, 0x7F, 0xFF}
+
+	if _, err := master.Write(data); err != nil {
+		t.Fatal(err)
+	}
+
+	got := make([]byte, 0, len(data))
+	buf := make([]byte, 16)
+	for len(got) < len(data) {
+		n, err := slave.Read(buf)
+		if err != nil || n == 0 {
+			t.Fatalf("read from slave: %d, %v", n, err)
+		}
+
+		got = append(got, buf[:n]...)
+	}
+
+	if string(got) != string(data) {
+		t.Errorf("slave got %x, expected %x", got, data)
+	}
+
+	if _, err := slave.Write(data); err != nil {
+		t.Fatal(err)
+	}
+
+	got = got[:0]
+	for len(got) < len(data) {
+		n, err := master.Read(buf)
+		if err != nil || n == 0 {
+			t.Fatalf("read from master: %d, %v", n, err)
+		}
+
+		got = append(got, buf[:n]...)
+	}
+
+	if string(got) != string(data) {
+		t.Errorf("master got %x, expected %x", got, data)
+	}
+}
+
+func TestPTYReadTimeouts(t *testing.T) {
+	master, err := OpenURL("pty://?baud=115200")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer master.Close()
+
+	// With neither InterCharacterTimeout nor MinimumReadSize, the slave waits
+	// for data with poll.
+	slave, err := Open(OpenOptions{
+		PortName:         master.PeerName(),
+		BaudRate:         115200,
+		DataBits:         8,
+		StopBits:         1,
+		ReadTimeout:      150 * time.Millisecond,
+		InterByteTimeout: 50 * time.Millisecond,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer slave.Close()
+
+	// Nothing to read, so the read should time out.
+	buf := make([]byte, 16)
+	start := time.Now()
+	n, err := slave.Read(buf)
+	if n != 0 || err != nil {
+		t.Errorf("expected 0, nil; got %d, %v", n, err)
+	}
+
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond || elapsed > time.Second {
+		t.Errorf("read returned after %v", elapsed)
+	}
+
+	// Bytes arriving close together are returned by one read.
+	go func() {
+		master.Write([]byte("ab"))
+		time.Sleep(10 * time.Millisecond)
+		master.Write([]byte("cd"))
+	}()
+
+	n, err = slave.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(buf[:n]) != "abcd" {
+		t.Errorf("expected %q, got %q", "abcd", buf[:n])
+	}
+}
+
+func TestPTYCloseUnblocksRead(t *testing.T) {
+	master, err := OpenURL("pty://?baud=115200")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer master.Close()
+
+	slave, err := Open(OpenOptions{
+		PortName: master.PeerName(),
+		BaudRate: 115200,
+		DataBits: 8,
+		StopBits: 1,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error)
+	go func() {
+		_, err := slave.Read(make([]byte, 16))
+		done <- err
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	slave.Close()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("expected an error from Read after Close")
+		}
+
+	case <-time.After(time.Second):
+		t.Fatal("Read still blocked after Close")
+	}
+}
+
+func TestPTYBaudRates(t *testing.T) {
+	master, err := OpenURL("pty://?baud=115200")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer master.Close()
+
+	if _, err := master.BaudRates(); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("expected errors.ErrUnsupported for the master, got %v", err)
+	}
+
+	options := OpenOptions{
+		PortName:          master.PeerName(),
+		BaudRate:          9600,
+		InputBaudRate:     4800,
+		BaudRateTolerance: 0.01,
+		DataBits:          8,
+		StopBits:          1,
+	}
+
+	slave, err := OpenPort(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer slave.Close()
+
+	rates, err := slave.BaudRates()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := (BaudRates{Input: 4800, Output: 9600}); rates != expected {
+		t.Errorf("expected %+v, got %+v", expected, rates)
+	}
+
+	// Pretend that 10000 baud was requested, which the port is far from.
+	options.BaudRate = 10000
+	options.InputBaudRate = 0
+	err = checkBaudRates(slave.f, options)
+	if !errors.Is(err, ErrUnsupportedBaudRate) {
+		t.Errorf("expected ErrUnsupportedBaudRate, got %v", err)
+	}
+
+	options.BaudRateTolerance = 0.6
+	if err := checkBaudRates(slave.f, options); err != nil {
+		t.Errorf("unexpected error within tolerance: %v", err)
+	}
+}
+
+func TestPTYLatencyUnsupported(t *testing.T) {
+	master, err := OpenURL("pty://?baud=115200")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer master.Close()
+
+	options := OpenOptions{
+		PortName: master.PeerName(),
+		BaudRate: 115200,
+		DataBits: 8,
+		StopBits: 1,
+	}
+
+	slave, err := OpenPort(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer slave.Close()
+
+	// The pty driver has neither serial_struct nor a latency timer, so custom
+	// divisors are unsupported too.
+	if err := slave.SetLowLatency(true); !errors.Is(err, ErrLatencyUnsupported) {
+		t.Errorf("SetLowLatency: expected ErrLatencyUnsupported, got %v", err)
+	}
+
+	if err := slave.SetCustomDivisor(24); !errors.Is(err, ErrLatencyUnsupported) {
+		t.Errorf("SetCustomDivisor: expected ErrLatencyUnsupported, got %v", err)
+	}
+
+	if err := slave.SetCustomDivisor(-1); !errors.Is(err, ErrUnsupportedBaudRate) {
+		t.Errorf("SetCustomDivisor(-1): expected ErrUnsupportedBaudRate, got %v", err)
+	}
+
+	if _, err := slave.LatencyTimer(); !errors.Is(err, ErrLatencyUnsupported) {
+		t.Errorf("LatencyTimer: expected ErrLatencyUnsupported, got %v", err)
+	}
+
+	if err := master.SetLatencyTimer(time.Millisecond); !errors.Is(err, ErrLatencyUnsupported) {
+		t.Errorf("SetLatencyTimer: expected ErrLatencyUnsupported, got %v", err)
+	}
+
+	options.LowLatency = true
+	if _, err := Open(options); !errors.Is(err, ErrLatencyUnsupported) {
+		t.Errorf("Open: expected ErrLatencyUnsupported, got %v", err)
+	}
+}
+
+func TestPTYFile(t *testing.T) {
+	master, err := OpenURL("pty://?baud=115200")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer master.Close()
+
+	if master.File() == nil {
+		t.Error("expected a file behind the pty master")
+	}
+
+	// Both read modes have a file behind them.
+	for _, options := range []OpenOptions{
+		{PortName: master.PeerName(), BaudRate: 115200, DataBits: 8, StopBits: 1, InterCharacterTimeout: 100},
+		{PortName: master.PeerName(), BaudRate: 115200, DataBits: 8, StopBits: 1, ReadTimeout: time.Second},
+	} {
+		slave, err := OpenPort(options)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if f := slave.File(); f == nil || f.Name() != master.PeerName() {
+			t.Errorf("%+v: unexpected file %v", options, f)
+		}
+
+		slave.Close()
+	}
+}
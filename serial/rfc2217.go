@@ -0,0 +1,398 @@
+// Copyright 2011 Aaron Jacobs. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// This file implements the client side of RFC 2217, the Telnet Com Port
+// Control Option, for ports opened with rfc2217:// URLs:
+//
+//     https://www.rfc-editor.org/rfc/rfc2217
+//
+
+package serial
+
+import (
+	"bufio"
+	"encoding/binary"
+	"net"
+	"sync"
+	"time"
+)
+
+// Telnet protocol bytes (RFC 854).
+const (
+	kIAC  = 255
+	kDONT = 254
+	kDO   = 253
+	kWONT = 252
+	kWILL = 251
+	kSB   = 250
+	kSE   = 240
+
+	kOptBinary  = 0
+	kOptSGA     = 3
+	kOptComPort = 44
+)
+
+// RFC 2217 commands sent by the client. The server's replies use the same
+// codes plus 100.
+const (
+	kCPSetBaudRate       = 1
+	kCPSetDataSize       = 2
+	kCPSetParity         = 3
+	kCPSetStopSize       = 4
+	kCPSetControl        = 5
+	kCPNotifyModemState  = 7
+	kCPSetModemStateMask = 11
+
+	kCPServerOffset = 100
+
+	// SET-CONTROL values.
+	kCPControlNoFlow   = 1
+	kCPControlHardware = 3
+)
+
+// SET-STOPSIZE values for each StopBits setting.
+var rfc2217StopSizes = map[StopBits]byte{
+	OneStop:          1,
+	TwoStop:          2,
+	OnePointFiveStop: 3,
+}
+
+// How long to wait for the TCP connection to the server.
+const kRFC2217DialTimeout = 10 * time.Second
+
+// rfc2217Port is a port on a network serial server.
+type rfc2217Port struct {
+	conn     net.Conn
+	r        *bufio.Reader
+	timeouts readTimeouts
+
+	// Guards writes to conn, which come both from Write and from replies to
+	// the server's option negotiation made while reading.
+	wl sync.Mutex
+
+	// Guards reads, and with them the options we have agreed to, keyed by
+	// option code, so that we don't answer the same request twice.
+	rl      sync.Mutex
+	willing map[byte]bool
+	doing   map[byte]bool
+
+	// The last modem state reported by the server.
+	ml         sync.Mutex
+	modemState byte
+}
+
+func openRFC2217(address string, options OpenOptions) (*rfc2217Port, error) {
+	conn, err := net.DialTimeout("tcp", address, kRFC2217DialTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &rfc2217Port{
+		conn:     conn,
+		r:        bufio.NewReader(conn),
+		timeouts: readTimeoutsFor(options),
+		willing:  make(map[byte]bool),
+		doing:    make(map[byte]bool),
+	}
+
+	if err := p.configure(options); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// configure offers the options we need and sends the line settings. The
+// server's replies are processed as they arrive during later reads.
+func (p *rfc2217Port) configure(options OpenOptions) error {
+	var out []byte
+	for _, opt := range []byte{kOptBinary, kOptSGA, kOptComPort} {
+		out = append(out, kIAC, kWILL, opt)
+		p.willing[opt] = true
+	}
+
+	for _, opt := range []byte{kOptBinary, kOptSGA} {
+		out = append(out, kIAC, kDO, opt)
+		p.doing[opt] = true
+	}
+
+	var baud [4]byte
+	binary.BigEndian.PutUint32(baud[:], uint32(options.BaudRate))
+	out = appendComPortCommand(out, kCPSetBaudRate, baud[:]...)
+	out = appendComPortCommand(out, kCPSetDataSize, byte(options.DataBits))
+
+	// RFC 2217 numbers the parity modes from 1 in the same order as
+	// ParityMode: NONE=1, ODD=2, EVEN=3, MARK=4, SPACE=5.
+	out = appendComPortCommand(out, kCPSetParity, byte(options.ParityMode)+1)
+	out = appendComPortCommand(out, kCPSetStopSize, rfc2217StopSizes[options.StopBits])
+
+	control := byte(kCPControlNoFlow)
+	if options.RTSCTSFlowControl {
+		control = kCPControlHardware
+	}
+	out = appendComPortCommand(out, kCPSetControl, control)
+
+	// Ask to be told about changes to all of the modem status lines.
+	out = appendComPortCommand(out, kCPSetModemStateMask, 0xFF)
+
+	return p.writeRaw(out)
+}
+
+// appendComPortCommand appends an IAC SB COM-PORT-OPTION subnegotiation to
+// out, escaping any IAC bytes in the value.
+func appendComPortCommand(out []byte, command byte, value ...byte) []byte {
+	out = append(out, kIAC, kSB, kOptComPort, command)
+	out = appendEscaped(out, value)
+	return append(out, kIAC, kSE)
+}
+
+// appendEscaped appends b to out, doubling IAC bytes as Telnet requires.
+func appendEscaped(out []byte, b []byte) []byte {
+	for _, c := range b {
+		if c == kIAC {
+			out = append(out, kIAC)
+		}
+
+		out = append(out, c)
+	}
+
+	return out
+}
+
+func (p *rfc2217Port) writeRaw(b []byte) error {
+	p.wl.Lock()
+	defer p.wl.Unlock()
+
+	_, err := p.conn.Write(b)
+	return err
+}
+
+func (p *rfc2217Port) Write(b []byte) (int, error) {
+	if err := p.writeRaw(appendEscaped(nil, b)); err != nil {
+		// We can't tell how much of the data made it.
+		return 0, err
+	}
+
+	return len(b), nil
+}
+
+// Read returns the data bytes received from the server, handling any Telnet
+// commands mixed in with them.
+func (p *rfc2217Port) Read(b []byte) (int, error) {
+	if len(b) == 0 {
+		return 0, nil
+	}
+
+	return p.timeouts.read(b, p.readUntil)
+}
+
+func (p *rfc2217Port) readUntil(b []byte, deadline time.Time) (int, error) {
+	if err := p.conn.SetReadDeadline(deadline); err != nil {
+		return 0, err
+	}
+
+	p.rl.Lock()
+	defer p.rl.Unlock()
+
+	n := 0
+	for n == 0 || (n < len(b) && p.r.Buffered() > 0) {
+		c, err := p.r.ReadByte()
+		if err != nil {
+			if isTimeout(err) {
+				err = nil
+			}
+
+			return n, err
+		}
+
+		if c != kIAC {
+			b[n] = c
+			n++
+			continue
+		}
+
+		// Finish reading the command even if the deadline passes meanwhile, so
+		// as not to lose track of where the commands are in the stream.
+		if !deadline.IsZero() {
+			p.conn.SetReadDeadline(time.Time{})
+		}
+
+		data, isData, err := p.handleCommand()
+
+		if !deadline.IsZero() {
+			p.conn.SetReadDeadline(deadline)
+		}
+
+		if err != nil {
+			return n, err
+		}
+
+		if isData {
+			b[n] = data
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+func isTimeout(err error) bool {
+	netErr, ok := err.(net.Error)
+	return ok && netErr.Timeout()
+}
+
+// handleCommand processes the Telnet command following an IAC byte. If the
+// command is an escaped IAC data byte, it is returned with isData set.
+func (p *rfc2217Port) handleCommand() (data byte, isData bool, err error) {
+	cmd, err := p.r.ReadByte()
+	if err != nil {
+		return 0, false, err
+	}
+
+	switch cmd {
+	case kIAC:
+		return kIAC, true, nil
+
+	case kDO, kDONT, kWILL, kWONT:
+		opt, err := p.r.ReadByte()
+		if err != nil {
+			return 0, false, err
+		}
+
+		return 0, false, p.negotiate(cmd, opt)
+
+	case kSB:
+		body, err := p.readSubnegotiation()
+		if err != nil {
+			return 0, false, err
+		}
+
+		p.handleSubnegotiation(body)
+		return 0, false, nil
+
+	default:
+		// NOP, GA and friends carry no meaning for us.
+		return 0, false, nil
+	}
+}
+
+// readSubnegotiation reads up to and including IAC SE, unescaping doubled
+// IAC bytes, and returns what came before.
+func (p *rfc2217Port) readSubnegotiation() ([]byte, error) {
+	var body []byte
+	for {
+		c, err := p.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+
+		if c != kIAC {
+			body = append(body, c)
+			continue
+		}
+
+		c, err = p.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+
+		if c == kSE {
+			return body, nil
+		}
+
+		body = append(body, c)
+	}
+}
+
+func (p *rfc2217Port) handleSubnegotiation(body []byte) {
+	if len(body) < 3 || body[0] != kOptComPort {
+		return
+	}
+
+	if body[1] == kCPNotifyModemState+kCPServerOffset {
+		p.ml.Lock()
+		p.modemState = body[2]
+		p.ml.Unlock()
+	}
+}
+
+// negotiate answers the server's DO, DONT, WILL or WONT for an option,
+// agreeing to the options we offered and refusing everything else.
+func (p *rfc2217Port) negotiate(cmd byte, opt byte) error {
+	switch cmd {
+	case kDO:
+		agree := opt == kOptBinary || opt == kOptSGA || opt == kOptComPort
+		return p.answer(p.willing, opt, agree, kWILL, kWONT)
+	case kDONT:
+		return p.answer(p.willing, opt, false, kWILL, kWONT)
+	case kWILL:
+		agree := opt == kOptBinary || opt == kOptSGA
+		return p.answer(p.doing, opt, agree, kDO, kDONT)
+	default:
+		return p.answer(p.doing, opt, false, kDO, kDONT)
+	}
+}
+
+// answer records whether we agree to an option and tells the server, unless
+// that would merely repeat what we said before. Acknowledging only changes
+// stops the two sides from acknowledging each other forever (RFC 854).
+func (p *rfc2217Port) answer(state map[byte]bool, opt byte, agree bool, yes, no byte) error {
+	if known, ok := state[opt]; ok && known == agree {
+		return nil
+	}
+
+	state[opt] = agree
+
+	reply := no
+	if agree {
+		reply = yes
+	}
+
+	return p.writeRaw([]byte{kIAC, reply, opt})
+}
+
+// modemLines returns the modem status last reported by the server. Output
+// lines are not reported by RFC 2217.
+func (p *rfc2217Port) modemLines() (ModemLines, error) {
+	const (
+		kCTS = 0x10
+		kDSR = 0x20
+		kRI  = 0x40
+		kDCD = 0x80
+	)
+
+	p.ml.Lock()
+	defer p.ml.Unlock()
+
+	var lines ModemLines
+	if p.modemState&kCTS != 0 {
+		lines |= MODEM_CTS
+	}
+	if p.modemState&kDSR != 0 {
+		lines |= MODEM_DSR
+	}
+	if p.modemState&kRI != 0 {
+		lines |= MODEM_RI
+	}
+	if p.modemState&kDCD != 0 {
+		lines |= MODEM_DCD
+	}
+
+	return lines, nil
+}
+
+func (p *rfc2217Port) Close() error {
+	return p.conn.Close()
+}
@@ -18,17 +18,43 @@
 package serial
 
 import (
+	"errors"
+	"fmt"
 	"io"
 	"math"
+	"time"
 )
 
 // Valid parity values.
 type ParityMode int
 
 const (
-	PARITY_NONE ParityMode = 0
-	PARITY_ODD  ParityMode = 1
-	PARITY_EVEN ParityMode = 2
+	PARITY_NONE  ParityMode = 0
+	PARITY_ODD   ParityMode = 1
+	PARITY_EVEN  ParityMode = 2
+	PARITY_MARK  ParityMode = 3 // Parity bit always 1; not supported on OS X
+	PARITY_SPACE ParityMode = 4 // Parity bit always 0; not supported on OS X
+)
+
+// Valid stop bit settings. The values of OneStop and TwoStop are the
+// corresponding numbers of stop bits, so plain 1 and 2 may be used too.
+// OnePointFiveStop is 15, which can't be mistaken for a number of stop bits;
+// other values, including 3, are rejected.
+type StopBits uint
+
+const (
+	OneStop          StopBits = 1
+	TwoStop          StopBits = 2
+	OnePointFiveStop StopBits = 15 // Available only with 5 data bits
+)
+
+// States for the DTR and RTS modem control lines when a port is opened.
+type LineState int
+
+const (
+	LINE_DEFAULT LineState = 0 // Leave the line as the OS sets it on open
+	LINE_ON      LineState = 1 // Assert the line
+	LINE_OFF     LineState = 2 // Deassert the line
 )
 
 var (
@@ -67,26 +93,45 @@ func IsStandardBaudRate(baudRate uint) bool { return StandardBaudRates[baudRate]
 
 // OpenOptions is the struct containing all of the options necessary for
 // opening a serial port.
+//
+// The struct tags give each field a stable name for use in JSON and YAML
+// configuration files, and ParityMode is written as a name such as "even",
+// so that options can be stored alongside other service configuration.
 type OpenOptions struct {
-	// The name of the port, e.g. "/dev/tty.usbserial-A8008HlV".
-	PortName string
+	// The name of the port, e.g. "/dev/tty.usbserial-A8008HlV". This may
+	// also be one of the non-device URLs returned by ParseURL, such as
+	// "rfc2217://host:port".
+	PortName string `json:"port_name" yaml:"port_name"`
 
 	// The baud rate for the port.
-	BaudRate uint
+	BaudRate uint `json:"baud_rate" yaml:"baud_rate"`
+
+	// If non-zero, the baud rate for receiving, with BaudRate used only for
+	// transmitting. Split rates are supported only on Linux and, for standard
+	// rates, OS X.
+	InputBaudRate uint `json:"input_baud_rate,omitempty" yaml:"input_baud_rate,omitempty"`
+
+	// If non-zero, Open reads back the speeds set by the driver and fails with
+	// ErrUnsupportedBaudRate if either deviates from that requested by more
+	// than this fraction, e.g. 0.02 for 2%. Ports whose driver cannot report
+	// its speeds are not checked; see Port.BaudRates.
+	BaudRateTolerance float64 `json:"baud_rate_tolerance,omitempty" yaml:"baud_rate_tolerance,omitempty"`
 
 	// The number of data bits per frame. Legal values are 5, 6, 7, and 8.
-	DataBits uint
+	DataBits uint `json:"data_bits" yaml:"data_bits"`
 
-	// The number of stop bits per frame. Legal values are 1 and 2.
-	StopBits uint
+	// The number of stop bits per frame. 1.5 stop bits may be used only with
+	// 5 data bits, and are not available on all devices. In JSON and YAML
+	// this is written as a number: 1, 1.5 or 2.
+	StopBits StopBits `json:"stop_bits" yaml:"stop_bits"`
 
 	// The type of parity bits to use for the connection. Currently parity errors
 	// are simply ignored; that is, bytes are delivered to the user no matter
 	// whether they were received with a parity error or not.
-	ParityMode ParityMode
+	ParityMode ParityMode `json:"parity" yaml:"parity"`
 
 	// Enable RTS/CTS (hardware) flow control.
-	RTSCTSFlowControl bool
+	RTSCTSFlowControl bool `json:"rtscts_flow_control,omitempty" yaml:"rtscts_flow_control,omitempty"`
 
 	// An inter-character timeout value, in milliseconds, and a minimum number of
 	// bytes to block for on each read. A call to Read() that otherwise may block
@@ -104,9 +149,8 @@ type OpenOptions struct {
 	//     http://www.unixwiz.net/techtips/termios-vmin-vtime.html
 	//
 	// InterCharacterTimeout = 0 and MinimumReadSize = 0 (the default):
-	//     This arrangement is not legal; you must explicitly set at least one of
-	//     these fields to a positive number. (If MinimumReadSize is zero then
-	//     InterCharacterTimeout must be at least 100.)
+	//     VMIN and VTIME are not used; ReadTimeout and InterByteTimeout below
+	//     apply instead.
 	//
 	// InterCharacterTimeout > 0 and MinimumReadSize = 0
 	//     If data is already available on the read queue, it is transferred to
@@ -137,32 +181,294 @@ type OpenOptions struct {
 	//			exceeded OR there is character data to return from the port.
 	//
 
-	InterCharacterTimeout uint
-	MinimumReadSize       uint
+	InterCharacterTimeout uint `json:"inter_character_timeout_ms" yaml:"inter_character_timeout_ms"`
+	MinimumReadSize       uint `json:"minimum_read_size" yaml:"minimum_read_size"`
+
+	// ReadTimeout and InterByteTimeout control how long Read waits for data
+	// when InterCharacterTimeout and MinimumReadSize are both zero. They have
+	// millisecond precision and no upper limit, and may not be combined with
+	// the fields above.
+	//
+	// Read waits up to ReadTimeout for the first byte, or forever if it is
+	// zero. Once some data has arrived, Read returns immediately if
+	// InterByteTimeout is zero. Otherwise it continues to read until the
+	// buffer is full, InterByteTimeout passes without another byte arriving
+	// or ReadTimeout expires. A Read that times out returns no data and a nil
+	// error.
+	//
+	// On Linux and OS X these are implemented with poll(2). In JSON they are
+	// given in nanoseconds; YAML also accepts strings such as "1.5s".
+	ReadTimeout      time.Duration `json:"read_timeout,omitempty" yaml:"read_timeout,omitempty"`
+	InterByteTimeout time.Duration `json:"inter_byte_timeout,omitempty" yaml:"inter_byte_timeout,omitempty"`
 
 	// Use to enable RS485 mode -- probably only valid on some Linux platforms
-	Rs485Enable bool
+	Rs485Enable bool `json:"rs485_enable,omitempty" yaml:"rs485_enable,omitempty"`
 
 	// Set to true for logic level high during send
-	Rs485RtsHighDuringSend bool
+	Rs485RtsHighDuringSend bool `json:"rs485_rts_high_during_send,omitempty" yaml:"rs485_rts_high_during_send,omitempty"`
 
 	// Set to true for logic level high after send
-	Rs485RtsHighAfterSend bool
+	Rs485RtsHighAfterSend bool `json:"rs485_rts_high_after_send,omitempty" yaml:"rs485_rts_high_after_send,omitempty"`
 
 	// set to receive data during sending
-	Rs485RxDuringTx bool
+	Rs485RxDuringTx bool `json:"rs485_rx_during_tx,omitempty" yaml:"rs485_rx_during_tx,omitempty"`
 
 	// RTS delay before send
-	Rs485DelayRtsBeforeSend int
+	Rs485DelayRtsBeforeSend int `json:"rs485_delay_rts_before_send,omitempty" yaml:"rs485_delay_rts_before_send,omitempty"`
 
 	// RTS delay after send
-	Rs485DelayRtsAfterSend int
+	Rs485DelayRtsAfterSend int `json:"rs485_delay_rts_after_send,omitempty" yaml:"rs485_delay_rts_after_send,omitempty"`
+
+	// The states to put the DTR and RTS lines in once the port is open.
+	// InitialRTS cannot be set together with RTSCTSFlowControl or
+	// Rs485Enable, which drive RTS themselves.
+	//
+	// Boards such as the Arduino reset when DTR is asserted. On Linux and OS
+	// X, open(2) asserts DTR before these settings can be applied, so setting
+	// InitialDTR to LINE_OFF does not prevent the reset; it also leaves DTR
+	// deasserted, so that the next open resets the board again. What avoids
+	// the reset is leaving HangupOnClose off: the first open after boot or
+	// after plugging the board in still asserts DTR and resets it, but DTR
+	// then stays asserted across Close and later opens don't toggle it.
+	//
+	// On Windows, DTR is asserted and RTS deasserted by default.
+	InitialDTR LineState `json:"initial_dtr,omitempty" yaml:"initial_dtr,omitempty"`
+	InitialRTS LineState `json:"initial_rts,omitempty" yaml:"initial_rts,omitempty"`
+
+	// Drop DTR and RTS when the port is closed (HUPCL). This is off by
+	// default, and Open clears HUPCL, so that lines asserted while the port
+	// is open stay asserted and the next Open doesn't reset a board attached
+	// to them; see InitialDTR. It has no effect on Windows, where the driver
+	// decides.
+	HangupOnClose bool `json:"hangup_on_close,omitempty" yaml:"hangup_on_close,omitempty"`
+
+	// Reduce the delay between data arriving and Read returning it, at some
+	// cost in CPU time. LowLatency sets the ASYNC_LOW_LATENCY flag with the
+	// TIOCSSERIAL ioctl, which makes the driver pass data on immediately
+	// rather than batching it. LatencyTimer, if non-zero, sets how long a
+	// USB adapter such as an FTDI chip waits to fill a packet before sending
+	// it to the host (16ms by default), in whole milliseconds from 1 to 255.
+	//
+	// Both are supported only on Linux, and only by some drivers; Open fails
+	// with ErrLatencyUnsupported if they cannot be applied. Setting
+	// LatencyTimer usually requires write access to the port's sysfs
+	// directory. See also Port.SetLowLatency and Port.SetLatencyTimer, and
+	// Port.SetCustomDivisor for the other use of TIOCSSERIAL.
+	LowLatency   bool          `json:"low_latency,omitempty" yaml:"low_latency,omitempty"`
+	LatencyTimer time.Duration `json:"latency_timer,omitempty" yaml:"latency_timer,omitempty"`
+
+	// If non-nil, called with every chunk of data read from or written to the
+	// port, every change of the modem lines and every error, including a
+	// failure to open the port. See the Tap interface for details.
+	Tap Tap `json:"-" yaml:"-"`
+}
+
+// Validate checks the options for invalid values and conflicting settings,
+// returning an error that lists every problem found, or nil if there are
+// none. Each problem wraps one of the Err* values defined by this package,
+// so errors.Is can be used to test for particular kinds of problem.
+//
+// Open calls Validate before touching the port. Options that are valid here
+// may still be rejected by a particular platform or device.
+func (o OpenOptions) Validate() error {
+	var errs []error
+	problem := func(kind error, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s: %w", fmt.Sprintf(format, args...), kind))
+	}
+
+	if o.BaudRate == 0 {
+		problem(ErrUnsupportedBaudRate, "BaudRate must be positive")
+	}
+
+	if o.BaudRateTolerance < 0 || o.BaudRateTolerance >= 1 {
+		problem(
+			ErrUnsupportedBaudRate,
+			"BaudRateTolerance is %v; must be at least 0 and less than 1",
+			o.BaudRateTolerance)
+	}
+
+	switch o.DataBits {
+	case 5, 6, 7, 8:
+	default:
+		problem(ErrInvalidDataBits, "DataBits is %d; must be 5, 6, 7 or 8", o.DataBits)
+	}
+
+	switch o.StopBits {
+	case OneStop, TwoStop:
+	case OnePointFiveStop:
+		if o.DataBits != 5 {
+			problem(ErrInvalidStopBits, "1.5 stop bits require 5 data bits, not %d", o.DataBits)
+		}
+
+	case 3:
+		problem(ErrInvalidStopBits, "StopBits is 3; use OnePointFiveStop for 1.5 stop bits")
+	default:
+		problem(ErrInvalidStopBits, "StopBits is %d; must be OneStop, OnePointFiveStop or TwoStop", uint(o.StopBits))
+	}
+
+	switch o.ParityMode {
+	case PARITY_NONE, PARITY_ODD, PARITY_EVEN, PARITY_MARK, PARITY_SPACE:
+	default:
+		problem(ErrInvalidParityMode, "ParityMode is %d", o.ParityMode)
+	}
+
+	if o.ReadTimeout < 0 {
+		problem(ErrInvalidTimeout, "ReadTimeout is %v; must not be negative", o.ReadTimeout)
+	}
+
+	if o.InterByteTimeout < 0 {
+		problem(ErrInvalidTimeout, "InterByteTimeout is %v; must not be negative", o.InterByteTimeout)
+	}
+
+	if o.LatencyTimer != 0 && (o.LatencyTimer < time.Millisecond || o.LatencyTimer > kMaxLatencyTimer) {
+		problem(ErrInvalidTimeout, "LatencyTimer is %v; must be between 1ms and 255ms", o.LatencyTimer)
+	}
+
+	if o.usesVMINVTIME() && (o.ReadTimeout != 0 || o.InterByteTimeout != 0) {
+		problem(
+			ErrConflictingOptions,
+			"ReadTimeout and InterByteTimeout cannot be used with InterCharacterTimeout and MinimumReadSize")
+	}
+
+	// These mirror the limits of VMIN and VTIME; see the field documentation.
+	vtime := uint(round(float64(o.InterCharacterTimeout)/100.0) * 100)
+	if o.usesVMINVTIME() && o.MinimumReadSize == 0 && vtime < 100 {
+		problem(
+			ErrInvalidTimeout,
+			"InterCharacterTimeout must be at least 100 when MinimumReadSize is 0")
+	}
+
+	if vtime > 25500 {
+		problem(
+			ErrInvalidTimeout,
+			"InterCharacterTimeout is %d; must be at most 25500",
+			o.InterCharacterTimeout)
+	}
+
+	if o.MinimumReadSize > 255 {
+		problem(
+			ErrInvalidTimeout,
+			"MinimumReadSize is %d; must be at most 255",
+			o.MinimumReadSize)
+	}
+
+	if o.Rs485DelayRtsBeforeSend < 0 {
+		problem(
+			ErrInvalidRS485,
+			"Rs485DelayRtsBeforeSend is %d; must not be negative",
+			o.Rs485DelayRtsBeforeSend)
+	}
+
+	if o.Rs485DelayRtsAfterSend < 0 {
+		problem(
+			ErrInvalidRS485,
+			"Rs485DelayRtsAfterSend is %d; must not be negative",
+			o.Rs485DelayRtsAfterSend)
+	}
+
+	if !o.Rs485Enable {
+		var set []string
+		if o.Rs485RtsHighDuringSend {
+			set = append(set, "Rs485RtsHighDuringSend")
+		}
+		if o.Rs485RtsHighAfterSend {
+			set = append(set, "Rs485RtsHighAfterSend")
+		}
+		if o.Rs485RxDuringTx {
+			set = append(set, "Rs485RxDuringTx")
+		}
+		if o.Rs485DelayRtsBeforeSend != 0 {
+			set = append(set, "Rs485DelayRtsBeforeSend")
+		}
+		if o.Rs485DelayRtsAfterSend != 0 {
+			set = append(set, "Rs485DelayRtsAfterSend")
+		}
+
+		for _, field := range set {
+			problem(ErrConflictingOptions, "%s is set but Rs485Enable is false", field)
+		}
+	}
+
+	for _, line := range []struct {
+		name  string
+		state LineState
+	}{{"InitialDTR", o.InitialDTR}, {"InitialRTS", o.InitialRTS}} {
+		switch line.state {
+		case LINE_DEFAULT, LINE_ON, LINE_OFF:
+		default:
+			problem(ErrInvalidLineState, "%s is %d", line.name, line.state)
+		}
+	}
+
+	if o.InitialRTS != LINE_DEFAULT && (o.RTSCTSFlowControl || o.Rs485Enable) {
+		problem(
+			ErrConflictingOptions,
+			"InitialRTS cannot be used with RTSCTSFlowControl or Rs485Enable, which drive RTS themselves")
+	}
+
+	if o.Rs485Enable && o.RTSCTSFlowControl {
+		problem(
+			ErrConflictingOptions,
+			"RTSCTSFlowControl cannot be used with Rs485Enable, which drives RTS itself")
+	}
+
+	return errors.Join(errs...)
 }
 
 // Open creates an io.ReadWriteCloser based on the supplied options struct.
+//
+// Errors are of type *PortError; use errors.Is to test for specific causes
+// such as ErrPortNotFound or ErrPermissionDenied. The returned value is a
+// *Port; use OpenPort to get at its additional methods without a type
+// assertion.
+//
+// Earlier versions returned an *os.File on Linux and OS X. Code that
+// type-asserts the result to *os.File, e.g. for its file descriptor, now
+// fails at run time; use OpenPort and Port.File instead.
 func Open(options OpenOptions) (io.ReadWriteCloser, error) {
-	// Redirect to the OS-specific function.
-	return openInternal(options)
+	port, err := OpenPort(options)
+	if err != nil {
+		return nil, err
+	}
+
+	return port, nil
+}
+
+// OpenPort is like Open, but returns the concrete *Port.
+func OpenPort(options OpenOptions) (*Port, error) {
+	var f io.ReadWriteCloser
+	var peerName string
+	err := options.Validate()
+	if err == nil {
+		// Redirect to the URL backend or OS-specific function.
+		f, peerName, err = openBackend(options)
+	}
+
+	if err == nil {
+		err = checkBaudRates(f, options)
+		if err == nil {
+			err = applyLatencyOptions(f, options)
+		}
+
+		if err != nil {
+			f.Close()
+		}
+	}
+
+	if err != nil {
+		portErr := newPortError("open", options.PortName, err)
+		if options.Tap != nil {
+			options.Tap.OnError("open", portErr)
+		}
+
+		return nil, portErr
+	}
+
+	port := newPort(f, options.Tap)
+	port.name = options.PortName
+	port.peerName = peerName
+
+	return port, nil
 }
 
 // Rounds a float to the nearest integer.
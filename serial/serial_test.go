@@ -1,8 +1,10 @@
 package serial
 
 import (
+	"errors"
 	"fmt"
 	"testing"
+	"time"
 )
 
 func TestIsStandardBaudRate(t *testing.T) {
@@ -48,3 +50,153 @@ func TestIsStandardBaudRate(t *testing.T) {
 		})
 	}
 }
+
+func TestValidate(t *testing.T) {
+	valid := OpenOptions{
+		PortName:        "/dev/ttyUSB0",
+		BaudRate:        9600,
+		DataBits:        8,
+		StopBits:        1,
+		MinimumReadSize: 1,
+	}
+
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("unexpected error for valid options: %v", err)
+	}
+
+	// With neither VMIN/VTIME field set, reads simply block.
+	blocking := valid
+	blocking.MinimumReadSize = 0
+	if err := blocking.Validate(); err != nil {
+		t.Fatalf("unexpected error for blocking options: %v", err)
+	}
+
+	testCases := []struct {
+		Name     string
+		Modify   func(*OpenOptions)
+		Expected []error
+	}{
+		{
+			"single field",
+			func(o *OpenOptions) { o.DataBits = 9 },
+			[]error{ErrInvalidDataBits},
+		},
+		{
+			"several fields",
+			func(o *OpenOptions) {
+				o.BaudRate = 0
+				o.StopBits = 4
+				o.ParityMode = 5
+				o.MinimumReadSize = 0
+				o.InterCharacterTimeout = 30000
+			},
+			[]error{ErrUnsupportedBaudRate, ErrInvalidStopBits, ErrInvalidParityMode, ErrInvalidTimeout},
+		},
+		{
+			"negative timeouts",
+			func(o *OpenOptions) {
+				o.MinimumReadSize = 0
+				o.ReadTimeout = -time.Second
+				o.InterByteTimeout = -time.Millisecond
+			},
+			[]error{ErrInvalidTimeout, ErrInvalidTimeout},
+		},
+		{
+			"timeouts mixed with VMIN and VTIME",
+			func(o *OpenOptions) { o.ReadTimeout = time.Second },
+			[]error{ErrConflictingOptions},
+		},
+		{
+			"1.5 stop bits with 8 data bits",
+			func(o *OpenOptions) { o.StopBits = OnePointFiveStop },
+			[]error{ErrInvalidStopBits},
+		},
+		{
+			"3 stop bits",
+			func(o *OpenOptions) {
+				o.DataBits = 5
+				o.StopBits = 3
+			},
+			[]error{ErrInvalidStopBits},
+		},
+		{
+			"latency timer",
+			func(o *OpenOptions) { o.LatencyTimer = 300 * time.Millisecond },
+			[]error{ErrInvalidTimeout},
+		},
+		{
+			"baud rate tolerance",
+			func(o *OpenOptions) { o.BaudRateTolerance = -0.1 },
+			[]error{ErrUnsupportedBaudRate},
+		},
+		{
+			"invalid line states",
+			func(o *OpenOptions) {
+				o.InitialDTR = 3
+				o.InitialRTS = -1
+			},
+			[]error{ErrInvalidLineState, ErrInvalidLineState},
+		},
+		{
+			"RTS with RTS/CTS",
+			func(o *OpenOptions) {
+				o.InitialRTS = LINE_OFF
+				o.RTSCTSFlowControl = true
+			},
+			[]error{ErrConflictingOptions},
+		},
+		{
+			"negative RS485 delays",
+			func(o *OpenOptions) {
+				o.Rs485Enable = true
+				o.Rs485DelayRtsBeforeSend = -1
+				o.Rs485DelayRtsAfterSend = -1
+			},
+			[]error{ErrInvalidRS485, ErrInvalidRS485},
+		},
+		{
+			"RS485 fields without RS485",
+			func(o *OpenOptions) {
+				o.Rs485RtsHighDuringSend = true
+				o.Rs485DelayRtsAfterSend = 10
+			},
+			[]error{ErrConflictingOptions, ErrConflictingOptions},
+		},
+		{
+			"RTS/CTS with RS485",
+			func(o *OpenOptions) {
+				o.Rs485Enable = true
+				o.RTSCTSFlowControl = true
+			},
+			[]error{ErrConflictingOptions},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			options := valid
+			testCase.Modify(&options)
+
+			err := options.Validate()
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+
+			joined, ok := err.(interface{ Unwrap() []error })
+			if !ok {
+				t.Fatalf("expected a joined error, got %T", err)
+			}
+
+			problems := joined.Unwrap()
+			if len(problems) != len(testCase.Expected) {
+				t.Fatalf("expected %d problems, got %d: %v", len(testCase.Expected), len(problems), err)
+			}
+
+			for i, expected := range testCase.Expected {
+				if !errors.Is(problems[i], expected) {
+					t.Errorf("problem %d: expected %v, got %v", i, expected, problems[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,423 @@
+// Copyright 2011 Aaron Jacobs. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package serial
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// The letters used for each parity mode in settings strings.
+var parityLetters = map[ParityMode]byte{
+	PARITY_NONE:  'N',
+	PARITY_ODD:   'O',
+	PARITY_EVEN:  'E',
+	PARITY_MARK:  'M',
+	PARITY_SPACE: 'S',
+}
+
+// The names used for each parity mode by MarshalText and UnmarshalText.
+var parityNames = map[ParityMode]string{
+	PARITY_NONE:  "none",
+	PARITY_ODD:   "odd",
+	PARITY_EVEN:  "even",
+	PARITY_MARK:  "mark",
+	PARITY_SPACE: "space",
+}
+
+// String returns the name of the parity mode, e.g. "even".
+func (p ParityMode) String() string {
+	if name, ok := parityNames[p]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("ParityMode(%d)", int(p))
+}
+
+// MarshalText implements encoding.TextMarshaler, so that parity modes appear
+// by name in JSON, YAML and similar formats.
+func (p ParityMode) MarshalText() ([]byte, error) {
+	name, ok := parityNames[p]
+	if !ok {
+		return nil, fmt.Errorf("%d: %w", int(p), ErrInvalidParityMode)
+	}
+
+	return []byte(name), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler. It accepts the names
+// returned by MarshalText and the letters used by ParseSettings, in any case.
+func (p *ParityMode) UnmarshalText(text []byte) error {
+	s := string(text)
+	for mode, name := range parityNames {
+		if strings.EqualFold(s, name) || strings.EqualFold(s, string(parityLetters[mode])) {
+			*p = mode
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%q: %w", s, ErrInvalidParityMode)
+}
+
+// The names used for each line state by MarshalText and UnmarshalText.
+var lineStateNames = map[LineState]string{
+	LINE_DEFAULT: "default",
+	LINE_ON:      "on",
+	LINE_OFF:     "off",
+}
+
+// String returns the name of the line state, e.g. "on".
+func (l LineState) String() string {
+	if name, ok := lineStateNames[l]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("LineState(%d)", int(l))
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (l LineState) MarshalText() ([]byte, error) {
+	name, ok := lineStateNames[l]
+	if !ok {
+		return nil, fmt.Errorf("%d: %w", int(l), ErrInvalidLineState)
+	}
+
+	return []byte(name), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler. It accepts the names
+// returned by MarshalText in any case.
+func (l *LineState) UnmarshalText(text []byte) error {
+	s := string(text)
+	for state, name := range lineStateNames {
+		if strings.EqualFold(s, name) {
+			*l = state
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%q: %w", s, ErrInvalidLineState)
+}
+
+// The notation used for each stop bit setting in settings strings, JSON and
+// YAML.
+var stopBitsNames = map[StopBits]string{
+	OneStop:          "1",
+	OnePointFiveStop: "1.5",
+	TwoStop:          "2",
+}
+
+// String returns the number of stop bits, e.g. "1.5".
+func (b StopBits) String() string {
+	if name, ok := stopBitsNames[b]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("StopBits(%d)", uint(b))
+}
+
+// MarshalText implements encoding.TextMarshaler. The zero value, meaning
+// that the stop bits have not been set, is written as the empty string.
+func (b StopBits) MarshalText() ([]byte, error) {
+	if b == 0 {
+		return []byte{}, nil
+	}
+
+	name, ok := stopBitsNames[b]
+	if !ok {
+		return nil, fmt.Errorf("%d: %w", uint(b), ErrInvalidStopBits)
+	}
+
+	return []byte(name), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, accepting "1", "1.5"
+// and "2", and the empty string for the zero value.
+func (b *StopBits) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*b = 0
+		return nil
+	}
+
+	for bits, name := range stopBitsNames {
+		if string(text) == name {
+			*b = bits
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%q: %w", text, ErrInvalidStopBits)
+}
+
+// MarshalJSON writes the stop bits as a JSON number, e.g. 1.5, or null for
+// the zero value.
+func (b StopBits) MarshalJSON() ([]byte, error) {
+	if b == 0 {
+		return []byte("null"), nil
+	}
+
+	return b.MarshalText()
+}
+
+// MarshalYAML implements yaml.Marshaler, so that the stop bits are written
+// as a YAML number rather than the quoted string MarshalText would give, or
+// as null for the zero value.
+func (b StopBits) MarshalYAML() (interface{}, error) {
+	if b == 0 {
+		return nil, nil
+	}
+
+	name, err := b.MarshalText()
+	if err != nil {
+		return nil, err
+	}
+
+	return strconv.ParseFloat(string(name), 64)
+}
+
+// UnmarshalJSON accepts a JSON number or string giving the number of stop
+// bits. null leaves the stop bits unchanged, as for other types.
+func (b *StopBits) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+
+	if unquoted, err := strconv.Unquote(string(data)); err == nil {
+		data = []byte(unquoted)
+	}
+
+	return b.UnmarshalText(data)
+}
+
+// ParseSettings parses port settings in the conventional compact notation
+// used by terminal programs and configuration files, e.g. "115200,8N1" or
+// "9600 7E2 rtscts". The string consists of the following tokens, separated
+// by commas or white space and matched case-insensitively:
+//
+//   - A baud rate, which is required.
+//   - A frame format made up of the data bits (5-8), a parity letter (N for
+//     none, E for even, O for odd, M for mark or S for space) and the stop
+//     bits (1, 1.5 or 2). Defaults to 8N1.
+//   - "rtscts" to enable RTS/CTS flow control.
+//   - "timeout=<n>ms" to set InterCharacterTimeout.
+//   - "minread=<n>" to set MinimumReadSize.
+//   - "read_timeout=<duration>" to set ReadTimeout, e.g. read_timeout=1.5s.
+//   - "inter_byte=<duration>" to set InterByteTimeout, e.g. inter_byte=20ms.
+//
+// If none of timeout, minread, read_timeout and inter_byte is given,
+// MinimumReadSize is set to 1 so that reads block until some data arrives.
+// Combinations that Validate rejects, such as timeout with read_timeout, are
+// parsed but fail when the port is opened.
+//
+// The returned options have no PortName; set it before calling Open.
+func ParseSettings(s string) (OpenOptions, error) {
+	options := OpenOptions{
+		DataBits:   8,
+		StopBits:   OneStop,
+		ParityMode: PARITY_NONE,
+	}
+
+	var sawBaud, sawFrame, sawFlow, sawTimeout, sawMinRead, sawReadTimeout, sawInterByte bool
+
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
+
+	for _, field := range fields {
+		token := strings.ToLower(field)
+		key, value, isKeyValue := strings.Cut(token, "=")
+
+		switch {
+		case isKeyValue && key == "timeout":
+			if sawTimeout {
+				return OpenOptions{}, settingsError(field, "timeout given twice")
+			}
+
+			ms, ok := strings.CutSuffix(value, "ms")
+			n, err := strconv.ParseUint(ms, 10, strconv.IntSize)
+			if !ok || err != nil {
+				return OpenOptions{}, settingsError(field, "timeout must be a whole number of milliseconds, e.g. timeout=100ms")
+			}
+
+			options.InterCharacterTimeout = uint(n)
+			sawTimeout = true
+
+		case isKeyValue && key == "minread":
+			if sawMinRead {
+				return OpenOptions{}, settingsError(field, "minread given twice")
+			}
+
+			n, err := strconv.ParseUint(value, 10, strconv.IntSize)
+			if err != nil {
+				return OpenOptions{}, settingsError(field, "minread must be a number of bytes")
+			}
+
+			options.MinimumReadSize = uint(n)
+			sawMinRead = true
+
+		case isKeyValue && (key == "read_timeout" || key == "inter_byte"):
+			d, saw := &options.ReadTimeout, &sawReadTimeout
+			if key == "inter_byte" {
+				d, saw = &options.InterByteTimeout, &sawInterByte
+			}
+
+			if *saw {
+				return OpenOptions{}, settingsError(field, key+" given twice")
+			}
+
+			var err error
+			*d, err = time.ParseDuration(value)
+			if err != nil || *d < 0 {
+				return OpenOptions{}, settingsError(field, key+" must be a non-negative duration, e.g. "+key+"=500ms")
+			}
+
+			*saw = true
+
+		case isKeyValue:
+			return OpenOptions{}, settingsError(field, "unknown setting")
+
+		case token == "rtscts":
+			if sawFlow {
+				return OpenOptions{}, settingsError(field, "flow control given twice")
+			}
+
+			options.RTSCTSFlowControl = true
+			sawFlow = true
+
+		case token[0] >= '0' && token[0] <= '9' && strings.IndexFunc(token, isLetter) < 0:
+			if sawBaud {
+				return OpenOptions{}, settingsError(field, "baud rate given twice")
+			}
+
+			n, err := strconv.ParseUint(token, 10, strconv.IntSize)
+			if err != nil || n == 0 {
+				return OpenOptions{}, fmt.Errorf("%q: %w", field, ErrUnsupportedBaudRate)
+			}
+
+			options.BaudRate = uint(n)
+			sawBaud = true
+
+		default:
+			if sawFrame {
+				return OpenOptions{}, settingsError(field, "frame format given twice")
+			}
+
+			if err := parseFrame(field, token, &options); err != nil {
+				return OpenOptions{}, err
+			}
+
+			sawFrame = true
+		}
+	}
+
+	if !sawBaud {
+		return OpenOptions{}, fmt.Errorf("%q: no baud rate: %w", s, ErrInvalidSettings)
+	}
+
+	if !sawTimeout && !sawMinRead && !sawReadTimeout && !sawInterByte {
+		options.MinimumReadSize = 1
+	}
+
+	return options, nil
+}
+
+func isLetter(r rune) bool {
+	return r >= 'a' && r <= 'z'
+}
+
+func settingsError(field string, msg string) error {
+	return fmt.Errorf("%q: %s: %w", field, msg, ErrInvalidSettings)
+}
+
+// parseFrame parses a frame format such as "8n1" into options.
+func parseFrame(field string, token string, options *OpenOptions) error {
+	if len(token) < 3 {
+		return settingsError(field, "unknown setting")
+	}
+
+	switch token[0] {
+	case '5', '6', '7', '8':
+		options.DataBits = uint(token[0] - '0')
+	default:
+		if token[0] >= '0' && token[0] <= '9' {
+			return fmt.Errorf("%q: %w", field, ErrInvalidDataBits)
+		}
+
+		return settingsError(field, "unknown setting")
+	}
+
+	found := false
+	for mode, letter := range parityLetters {
+		if strings.EqualFold(string(letter), token[1:2]) {
+			options.ParityMode = mode
+			found = true
+		}
+	}
+
+	if !found {
+		return fmt.Errorf("%q: unknown parity letter: %w", field, ErrInvalidParityMode)
+	}
+
+	if err := options.StopBits.UnmarshalText([]byte(token[2:])); err != nil {
+		return fmt.Errorf("%q: %w", field, ErrInvalidStopBits)
+	}
+
+	return nil
+}
+
+// String formats the options' line settings and timeouts in the notation
+// accepted by ParseSettings, e.g. "115200 8N1 rtscts", such that
+// ParseSettings returns the same settings. The port name, RS485 settings and
+// Tap are not included.
+func (o OpenOptions) String() string {
+	parity, ok := parityLetters[o.ParityMode]
+	if !ok {
+		parity = '?'
+	}
+
+	s := fmt.Sprintf("%d %d%c%v", o.BaudRate, o.DataBits, parity, o.StopBits)
+
+	if o.RTSCTSFlowControl {
+		s += " rtscts"
+	}
+
+	if o.InterCharacterTimeout != 0 {
+		s += fmt.Sprintf(" timeout=%dms", o.InterCharacterTimeout)
+	}
+
+	if o.ReadTimeout != 0 {
+		s += fmt.Sprintf(" read_timeout=%v", o.ReadTimeout)
+	}
+
+	if o.InterByteTimeout != 0 {
+		s += fmt.Sprintf(" inter_byte=%v", o.InterByteTimeout)
+	}
+
+	// Leave out MinimumReadSize if ParseSettings would arrive at the same
+	// value without it.
+	var impliedMinRead uint
+	if o.InterCharacterTimeout == 0 && o.ReadTimeout == 0 && o.InterByteTimeout == 0 {
+		impliedMinRead = 1
+	}
+
+	if o.MinimumReadSize != impliedMinRead {
+		s += fmt.Sprintf(" minread=%d", o.MinimumReadSize)
+	}
+
+	return s
+}
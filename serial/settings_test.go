@@ -0,0 +1,452 @@
+package serial
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestParseSettings(t *testing.T) {
+	testCases := []struct {
+		Settings string
+		Expected OpenOptions
+	}{
+		{
+			"115200,8N1",
+			OpenOptions{BaudRate: 115200, DataBits: 8, StopBits: 1, MinimumReadSize: 1},
+		},
+		{
+			"9600 7e2 RTSCTS",
+			OpenOptions{
+				BaudRate:          9600,
+				DataBits:          7,
+				StopBits:          2,
+				ParityMode:        PARITY_EVEN,
+				RTSCTSFlowControl: true,
+				MinimumReadSize:   1,
+			},
+		},
+		{
+			"19200",
+			OpenOptions{BaudRate: 19200, DataBits: 8, StopBits: 1, MinimumReadSize: 1},
+		},
+		{
+			"300, 5O1, timeout=500ms",
+			OpenOptions{BaudRate: 300, DataBits: 5, StopBits: 1, ParityMode: PARITY_ODD, InterCharacterTimeout: 500},
+		},
+		{
+			"38400 8M1 timeout=200ms minread=4",
+			OpenOptions{
+				BaudRate:              38400,
+				DataBits:              8,
+				StopBits:              1,
+				ParityMode:            PARITY_MARK,
+				InterCharacterTimeout: 200,
+				MinimumReadSize:       4,
+			},
+		},
+		{
+			"110 5N1.5",
+			OpenOptions{BaudRate: 110, DataBits: 5, StopBits: OnePointFiveStop, MinimumReadSize: 1},
+		},
+		{
+			"1200 6s2 minread=0",
+			OpenOptions{BaudRate: 1200, DataBits: 6, StopBits: 2, ParityMode: PARITY_SPACE},
+		},
+		{
+			"57600 8N1 read_timeout=1.5s inter_byte=20ms",
+			OpenOptions{
+				BaudRate:         57600,
+				DataBits:         8,
+				StopBits:         1,
+				ReadTimeout:      1500 * time.Millisecond,
+				InterByteTimeout: 20 * time.Millisecond,
+			},
+		},
+		{
+			"9600 inter_byte=5ms",
+			OpenOptions{BaudRate: 9600, DataBits: 8, StopBits: 1, InterByteTimeout: 5 * time.Millisecond},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Settings, func(t *testing.T) {
+			options, err := ParseSettings(testCase.Settings)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if options != testCase.Expected {
+				t.Errorf("expected %+v, got %+v", testCase.Expected, options)
+			}
+		})
+	}
+}
+
+func TestParseSettingsErrors(t *testing.T) {
+	testCases := []struct {
+		Settings string
+		Err      error
+	}{
+		{"", ErrInvalidSettings},
+		{"8N1", ErrInvalidSettings},
+		{"9600 9600", ErrInvalidSettings},
+		{"9600 8N1 8N1", ErrInvalidSettings},
+		{"9600 xonxoff", ErrInvalidSettings},
+		{"9600 timeout=1.5ms", ErrInvalidSettings},
+		{"9600 colour=blue", ErrInvalidSettings},
+		{"9600 read_timeout=100", ErrInvalidSettings},
+		{"9600 read_timeout=-1s", ErrInvalidSettings},
+		{"9600 inter_byte=1ms inter_byte=2ms", ErrInvalidSettings},
+		{"0 8N1", ErrUnsupportedBaudRate},
+		{"9600 9N1", ErrInvalidDataBits},
+		{"9600 8X1", ErrInvalidParityMode},
+		{"9600 8N3", ErrInvalidStopBits},
+		{"9600 8N1.25", ErrInvalidStopBits},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Settings, func(t *testing.T) {
+			_, err := ParseSettings(testCase.Settings)
+			if !errors.Is(err, testCase.Err) {
+				t.Errorf("expected %v, got %v", testCase.Err, err)
+			}
+		})
+	}
+}
+
+func TestOpenOptionsString(t *testing.T) {
+	testCases := []struct {
+		Options  OpenOptions
+		Expected string
+	}{
+		{
+			OpenOptions{BaudRate: 115200, DataBits: 8, StopBits: 1, MinimumReadSize: 1},
+			"115200 8N1",
+		},
+		{
+			OpenOptions{BaudRate: 9600, DataBits: 7, StopBits: 2, ParityMode: PARITY_EVEN, RTSCTSFlowControl: true, MinimumReadSize: 1},
+			"9600 7E2 rtscts",
+		},
+		{
+			OpenOptions{BaudRate: 300, DataBits: 8, StopBits: 1, InterCharacterTimeout: 500},
+			"300 8N1 timeout=500ms",
+		},
+		{
+			OpenOptions{BaudRate: 300, DataBits: 8, StopBits: 1},
+			"300 8N1 minread=0",
+		},
+		{
+			OpenOptions{BaudRate: 110, DataBits: 5, StopBits: OnePointFiveStop, MinimumReadSize: 1},
+			"110 5N1.5",
+		},
+		{
+			OpenOptions{BaudRate: 57600, DataBits: 8, StopBits: 1, ReadTimeout: 1500 * time.Millisecond, InterByteTimeout: 20 * time.Millisecond},
+			"57600 8N1 read_timeout=1.5s inter_byte=20ms",
+		},
+	}
+
+	for _, testCase := range testCases {
+		if s := testCase.Options.String(); s != testCase.Expected {
+			t.Errorf("expected %q, got %q", testCase.Expected, s)
+		}
+	}
+}
+
+func TestParityModeText(t *testing.T) {
+	testCases := []struct {
+		Text     string
+		Expected ParityMode
+	}{
+		{"none", PARITY_NONE},
+		{"odd", PARITY_ODD},
+		{"EVEN", PARITY_EVEN},
+		{"Mark", PARITY_MARK},
+		{"space", PARITY_SPACE},
+		{"N", PARITY_NONE},
+		{"e", PARITY_EVEN},
+	}
+
+	for _, testCase := range testCases {
+		var mode ParityMode
+		if err := mode.UnmarshalText([]byte(testCase.Text)); err != nil {
+			t.Errorf("%q: %v", testCase.Text, err)
+			continue
+		}
+
+		if mode != testCase.Expected {
+			t.Errorf("%q: expected %v, got %v", testCase.Text, testCase.Expected, mode)
+		}
+	}
+
+	var mode ParityMode
+	if err := mode.UnmarshalText([]byte("parity")); !errors.Is(err, ErrInvalidParityMode) {
+		t.Errorf("expected ErrInvalidParityMode, got %v", err)
+	}
+
+	if _, err := ParityMode(17).MarshalText(); !errors.Is(err, ErrInvalidParityMode) {
+		t.Errorf("expected ErrInvalidParityMode, got %v", err)
+	}
+}
+
+func TestLineStateText(t *testing.T) {
+	for _, state := range []LineState{LINE_DEFAULT, LINE_ON, LINE_OFF} {
+		text, err := state.MarshalText()
+		if err != nil {
+			t.Fatalf("%v: %v", state, err)
+		}
+
+		var decoded LineState
+		if err := decoded.UnmarshalText(text); err != nil {
+			t.Fatalf("%q: %v", text, err)
+		}
+
+		if decoded != state {
+			t.Errorf("%q: expected %v, got %v", text, state, decoded)
+		}
+	}
+
+	var state LineState
+	if err := state.UnmarshalText([]byte("high")); !errors.Is(err, ErrInvalidLineState) {
+		t.Errorf("expected ErrInvalidLineState, got %v", err)
+	}
+}
+
+func TestStopBitsJSON(t *testing.T) {
+	testCases := []struct {
+		JSON     string
+		Expected StopBits
+	}{
+		{`1`, OneStop},
+		{`1.5`, OnePointFiveStop},
+		{`2`, TwoStop},
+		{`"1.5"`, OnePointFiveStop},
+	}
+
+	for _, testCase := range testCases {
+		var bits StopBits
+		if err := json.Unmarshal([]byte(testCase.JSON), &bits); err != nil {
+			t.Errorf("%s: %v", testCase.JSON, err)
+			continue
+		}
+
+		if bits != testCase.Expected {
+			t.Errorf("%s: expected %v, got %v", testCase.JSON, testCase.Expected, bits)
+		}
+	}
+
+	b, err := json.Marshal(OnePointFiveStop)
+	if err != nil || string(b) != "1.5" {
+		t.Errorf("expected 1.5, got %s, %v", b, err)
+	}
+
+	b, err = json.Marshal(StopBits(0))
+	if err != nil || string(b) != "null" {
+		t.Errorf("expected null, got %s, %v", b, err)
+	}
+
+	var bits StopBits
+	if err := json.Unmarshal([]byte(`3`), &bits); !errors.Is(err, ErrInvalidStopBits) {
+		t.Errorf("expected ErrInvalidStopBits, got %v", err)
+	}
+}
+
+func TestZeroOpenOptionsRoundTrip(t *testing.T) {
+	var options OpenOptions
+
+	b, err := json.Marshal(options)
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+
+	if !strings.Contains(string(b), `"stop_bits":null`) {
+		t.Errorf("expected null stop bits in %s", b)
+	}
+
+	var decoded OpenOptions
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatalf("json.Unmarshal(%s): %v", b, err)
+	}
+
+	if decoded != options {
+		t.Errorf("JSON: expected %+v, got %+v", options, decoded)
+	}
+
+	b, err = yaml.Marshal(options)
+	if err != nil {
+		t.Fatalf("yaml.Marshal: %v", err)
+	}
+
+	if !strings.Contains(string(b), "\nstop_bits: null\n") {
+		t.Errorf("expected null stop bits in:\n%s", b)
+	}
+
+	decoded = OpenOptions{}
+	if err := yaml.Unmarshal(b, &decoded); err != nil {
+		t.Fatalf("yaml.Unmarshal(%q): %v", b, err)
+	}
+
+	if decoded != options {
+		t.Errorf("YAML: expected %+v, got %+v", options, decoded)
+	}
+
+	// Text, as used by flag.TextVar, round-trips too.
+	text, err := options.StopBits.MarshalText()
+	if err != nil || len(text) != 0 {
+		t.Errorf("MarshalText: expected empty text, got %q, %v", text, err)
+	}
+
+	bits := TwoStop
+	if err := bits.UnmarshalText(text); err != nil || bits != 0 {
+		t.Errorf("UnmarshalText: expected 0, got %v, %v", bits, err)
+	}
+}
+
+func TestOpenOptionsJSON(t *testing.T) {
+	options := OpenOptions{
+		PortName:              "/dev/ttyUSB0",
+		BaudRate:              9600,
+		DataBits:              7,
+		StopBits:              2,
+		ParityMode:            PARITY_EVEN,
+		RTSCTSFlowControl:     true,
+		InterCharacterTimeout: 100,
+		Tap:                   &recordingTap{},
+	}
+
+	b, err := json.Marshal(options)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	expected := `{"port_name":"/dev/ttyUSB0","baud_rate":9600,"data_bits":7,"stop_bits":2,` +
+		`"parity":"even","rtscts_flow_control":true,"inter_character_timeout_ms":100,"minimum_read_size":0}`
+	if string(b) != expected {
+		t.Errorf("expected %s, got %s", expected, b)
+	}
+
+	var decoded OpenOptions
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+
+	options.Tap = nil
+	if decoded != options {
+		t.Errorf("expected %+v, got %+v", options, decoded)
+	}
+
+	err = json.Unmarshal([]byte(`{"parity":"sideways"}`), &decoded)
+	if !errors.Is(err, ErrInvalidParityMode) {
+		t.Errorf("expected ErrInvalidParityMode, got %v", err)
+	}
+}
+
+func TestOpenOptionsYAML(t *testing.T) {
+	config := `
+port_name: /dev/ttyS1
+baud_rate: 19200
+data_bits: 5
+stop_bits: 1.5
+parity: odd
+minimum_read_size: 1
+rs485_enable: true
+rs485_delay_rts_before_send: 2
+`
+
+	var options OpenOptions
+	if err := yaml.Unmarshal([]byte(config), &options); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+
+	expected := OpenOptions{
+		PortName:                "/dev/ttyS1",
+		BaudRate:                19200,
+		DataBits:                5,
+		StopBits:                OnePointFiveStop,
+		ParityMode:              PARITY_ODD,
+		MinimumReadSize:         1,
+		Rs485Enable:             true,
+		Rs485DelayRtsBeforeSend: 2,
+	}
+
+	if options != expected {
+		t.Errorf("expected %+v, got %+v", expected, options)
+	}
+
+	b, err := yaml.Marshal(options)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	if !strings.Contains(string(b), "\nstop_bits: 1.5\n") {
+		t.Errorf("expected stop bits as a number in:\n%s", b)
+	}
+
+	var decoded OpenOptions
+	if err := yaml.Unmarshal(b, &decoded); err != nil {
+		t.Fatalf("Unmarshal(%q): %v", b, err)
+	}
+
+	if decoded != expected {
+		t.Errorf("expected %+v, got %+v", expected, decoded)
+	}
+}
+
+func FuzzParseSettings(f *testing.F) {
+	f.Add("115200,8N1")
+	f.Add("9600 7E2 rtscts")
+	f.Add("300 5O1 timeout=500ms minread=0")
+	f.Add("1200 8s2 minread=7")
+	f.Add("57600 8N1 read_timeout=1.5s inter_byte=20ms")
+	f.Add("4800 7O2 inter_byte=250us")
+
+	f.Fuzz(func(t *testing.T, s string) {
+		options, err := ParseSettings(s)
+		if err != nil {
+			return
+		}
+
+		formatted := options.String()
+		reparsed, err := ParseSettings(formatted)
+		if err != nil {
+			t.Fatalf("ParseSettings(%q) (from %q): %v", formatted, s, err)
+		}
+
+		if reparsed != options {
+			t.Fatalf("%q -> %+v -> %q -> %+v", s, options, formatted, reparsed)
+		}
+	})
+}
+
+func FuzzOpenOptionsString(f *testing.F) {
+	f.Add(uint(115200), uint8(8), uint8(0), uint8(1), false, uint(0), uint(1), int64(0), int64(0))
+	f.Add(uint(9600), uint8(7), uint8(2), uint8(2), true, uint(100), uint(0), int64(0), int64(0))
+	f.Add(uint(57600), uint8(8), uint8(0), uint8(1), false, uint(0), uint(0), int64(time.Second), int64(20*time.Millisecond))
+
+	f.Fuzz(func(t *testing.T, baud uint, dataBits, parity, stopBits uint8, rtscts bool, timeout, minRead uint, readTimeout, interByte int64) {
+		options := OpenOptions{
+			BaudRate:              baud%4000000 + 1,
+			DataBits:              uint(dataBits%4 + 5),
+			StopBits:              []StopBits{OneStop, OnePointFiveStop, TwoStop}[stopBits%3],
+			ParityMode:            ParityMode(parity % 5),
+			RTSCTSFlowControl:     rtscts,
+			InterCharacterTimeout: timeout,
+			MinimumReadSize:       minRead,
+			ReadTimeout:           time.Duration(max(readTimeout, 0)),
+			InterByteTimeout:      time.Duration(max(interByte, 0)),
+		}
+
+		parsed, err := ParseSettings(options.String())
+		if err != nil {
+			t.Fatalf("ParseSettings(%q): %v", options.String(), err)
+		}
+
+		if parsed != options {
+			t.Fatalf("%+v -> %q -> %+v", options, options.String(), parsed)
+		}
+	})
+}
@@ -0,0 +1,181 @@
+// Copyright 2011 Aaron Jacobs. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package serial
+
+import (
+	"context"
+	"encoding/hex"
+	"log/slog"
+	"strings"
+)
+
+// ModemLines is a set of modem control and status lines.
+type ModemLines uint
+
+const (
+	MODEM_DTR ModemLines = 1 << iota // Data Terminal Ready (output)
+	MODEM_RTS                        // Request To Send (output)
+	MODEM_CTS                        // Clear To Send (input)
+	MODEM_DSR                        // Data Set Ready (input)
+	MODEM_DCD                        // Data Carrier Detect (input)
+	MODEM_RI                         // Ring Indicator (input)
+)
+
+var modemLineNames = []string{"DTR", "RTS", "CTS", "DSR", "DCD", "RI"}
+
+// String returns the names of the asserted lines separated by "|", e.g.
+// "DTR|RTS|CTS", or "0" if none are asserted.
+func (m ModemLines) String() string {
+	var names []string
+	for i, name := range modemLineNames {
+		if m&(1<<uint(i)) != 0 {
+			names = append(names, name)
+		}
+	}
+
+	if len(names) == 0 {
+		return "0"
+	}
+
+	return strings.Join(names, "|")
+}
+
+// Tap observes the traffic on a port. Set OpenOptions.Tap to have one called
+// for every operation on the port returned by Open.
+//
+// Reads, writes and modem line polling may happen on different goroutines, so
+// implementations must be safe for concurrent use. The slices passed to
+// OnRead and OnWrite must not be retained after the call returns.
+type Tap interface {
+	// OnRead is called with each non-empty chunk of data read from the port.
+	OnRead(b []byte)
+
+	// OnWrite is called with each non-empty chunk of data written to the port.
+	OnWrite(b []byte)
+
+	// OnModemLines is called when the state of the modem lines changes.
+	//
+	// The lines are polled while the port is open, so short pulses may be
+	// missed. On platforms or devices that cannot report modem lines this is
+	// never called.
+	OnModemLines(old, new ModemLines)
+
+	// OnError is called when an operation on the port fails. op is one of
+	// "open", "read", "write" or "close". io.EOF is not reported.
+	OnError(op string, err error)
+}
+
+// MultiTap returns a Tap that forwards every event to each of the supplied
+// taps in turn.
+func MultiTap(taps ...Tap) Tap {
+	return multiTap(taps)
+}
+
+type multiTap []Tap
+
+func (m multiTap) OnRead(b []byte) {
+	for _, t := range m {
+		t.OnRead(b)
+	}
+}
+
+func (m multiTap) OnWrite(b []byte) {
+	for _, t := range m {
+		t.OnWrite(b)
+	}
+}
+
+func (m multiTap) OnModemLines(old, new ModemLines) {
+	for _, t := range m {
+		t.OnModemLines(old, new)
+	}
+}
+
+func (m multiTap) OnError(op string, err error) {
+	for _, t := range m {
+		t.OnError(op, err)
+	}
+}
+
+// LogTap is a Tap that logs every event to a structured logger, with data
+// chunks rendered as hexdumps.
+type LogTap struct {
+	// The logger to write to. If nil, slog.Default() is used. Use Logger.With
+	// to attach the port name or other context to every record.
+	Logger *slog.Logger
+
+	// The level at which reads and writes are logged. Modem line changes are
+	// logged at slog.LevelInfo and errors at slog.LevelError.
+	DataLevel slog.Level
+}
+
+// NewLogTap returns a LogTap that logs data at slog.LevelDebug.
+func NewLogTap(logger *slog.Logger) *LogTap {
+	return &LogTap{
+		Logger:    logger,
+		DataLevel: slog.LevelDebug,
+	}
+}
+
+func (l *LogTap) logger() *slog.Logger {
+	if l.Logger == nil {
+		return slog.Default()
+	}
+
+	return l.Logger
+}
+
+func (l *LogTap) logData(msg string, b []byte) {
+	logger := l.logger()
+	ctx := context.Background()
+
+	// Skip formatting the hexdump when it would be thrown away.
+	if !logger.Enabled(ctx, l.DataLevel) {
+		return
+	}
+
+	logger.LogAttrs(
+		ctx,
+		l.DataLevel,
+		msg,
+		slog.Int("len", len(b)),
+		slog.String("hexdump", hex.Dump(b)))
+}
+
+func (l *LogTap) OnRead(b []byte) {
+	l.logData("serial read", b)
+}
+
+func (l *LogTap) OnWrite(b []byte) {
+	l.logData("serial write", b)
+}
+
+func (l *LogTap) OnModemLines(old, new ModemLines) {
+	l.logger().LogAttrs(
+		context.Background(),
+		slog.LevelInfo,
+		"serial modem lines changed",
+		slog.String("old", old.String()),
+		slog.String("new", new.String()))
+}
+
+func (l *LogTap) OnError(op string, err error) {
+	l.logger().LogAttrs(
+		context.Background(),
+		slog.LevelError,
+		"serial error",
+		slog.String("op", op),
+		slog.Any("error", err))
+}
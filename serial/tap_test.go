@@ -0,0 +1,139 @@
+package serial
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// fakePort is an in-memory port whose reads come from In and whose writes go
+// to Out.
+type fakePort struct {
+	In       io.Reader
+	Out      bytes.Buffer
+	WriteErr error
+	Closed   bool
+}
+
+func (p *fakePort) Read(b []byte) (int, error) { return p.In.Read(b) }
+
+func (p *fakePort) Write(b []byte) (int, error) {
+	if p.WriteErr != nil {
+		return 0, p.WriteErr
+	}
+
+	return p.Out.Write(b)
+}
+
+func (p *fakePort) Close() error {
+	p.Closed = true
+	return nil
+}
+
+type recordingTap struct {
+	Events []string
+}
+
+func (r *recordingTap) OnRead(b []byte)  { r.Events = append(r.Events, "read "+string(b)) }
+func (r *recordingTap) OnWrite(b []byte) { r.Events = append(r.Events, "write "+string(b)) }
+
+func (r *recordingTap) OnModemLines(old, new ModemLines) {
+	r.Events = append(r.Events, "lines "+old.String()+" "+new.String())
+}
+
+func (r *recordingTap) OnError(op string, err error) {
+	r.Events = append(r.Events, op+" error "+err.Error())
+}
+
+func TestTappedPort(t *testing.T) {
+	fake := &fakePort{In: strings.NewReader("pong")}
+	tap := &recordingTap{}
+	port := newPort(fake, tap)
+
+	if _, err := port.Write([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 16)
+	if _, err := port.Read(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	// EOF is not reported as an error.
+	if _, err := port.Read(buf); err != io.EOF {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+
+	fake.WriteErr = errors.New("unplugged")
+	port.Write([]byte("x"))
+
+	if err := port.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !fake.Closed {
+		t.Error("underlying port was not closed")
+	}
+
+	expected := []string{
+		"write ping",
+		"read pong",
+		"write error unplugged",
+	}
+
+	if strings.Join(tap.Events, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected events %q, got %q", expected, tap.Events)
+	}
+}
+
+func TestMultiTap(t *testing.T) {
+	a := &recordingTap{}
+	b := &recordingTap{}
+	tap := MultiTap(a, b)
+
+	tap.OnRead([]byte("x"))
+	tap.OnModemLines(MODEM_DTR, MODEM_DTR|MODEM_CTS)
+
+	for _, r := range []*recordingTap{a, b} {
+		if len(r.Events) != 2 || r.Events[1] != "lines DTR DTR|CTS" {
+			t.Errorf("unexpected events %q", r.Events)
+		}
+	}
+}
+
+func TestModemLinesString(t *testing.T) {
+	testCases := []struct {
+		Lines    ModemLines
+		Expected string
+	}{
+		{0, "0"},
+		{MODEM_DTR, "DTR"},
+		{MODEM_RTS | MODEM_CTS, "RTS|CTS"},
+		{MODEM_DSR | MODEM_DCD | MODEM_RI, "DSR|DCD|RI"},
+	}
+
+	for _, testCase := range testCases {
+		if s := testCase.Lines.String(); s != testCase.Expected {
+			t.Errorf("expected %q, got %q", testCase.Expected, s)
+		}
+	}
+}
+
+func TestLogTap(t *testing.T) {
+	var buf bytes.Buffer
+	handler := slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})
+	tap := NewLogTap(slog.New(handler))
+
+	tap.OnWrite([]byte{0xDE, 0xAD, 0xBE, 0xEF})
+	tap.OnError("read", errors.New("boom"))
+
+	out := buf.String()
+	for _, want := range []string{"serial write", "de ad be ef", "len=4", "level=ERROR", "op=read", "error=boom"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected log output to contain %q:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2011 Aaron Jacobs. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package serial
+
+import (
+	"errors"
+	"io"
+	"os"
+	"time"
+)
+
+// usesVMINVTIME reports whether the options use the legacy
+// InterCharacterTimeout and MinimumReadSize fields, rather than ReadTimeout
+// and InterByteTimeout.
+func (o OpenOptions) usesVMINVTIME() bool {
+	return o.InterCharacterTimeout != 0 || o.MinimumReadSize != 0
+}
+
+// readTimeouts describes how long a Read call waits for data, for backends
+// that implement timeouts themselves rather than with VMIN and VTIME.
+type readTimeouts struct {
+	// How long to wait for the first byte. Zero means forever.
+	total time.Duration
+
+	// Once some data has arrived, how long to wait for each further byte
+	// before returning. Zero means return as soon as some data has arrived.
+	interByte time.Duration
+}
+
+// readTimeoutsFor returns the timeouts given by the options. For the legacy
+// fields, the timeout applies only when MinimumReadSize is zero, as with
+// VTIME; otherwise reads block until data arrives.
+func readTimeoutsFor(options OpenOptions) readTimeouts {
+	if !options.usesVMINVTIME() {
+		return readTimeouts{
+			total:     options.ReadTimeout,
+			interByte: options.InterByteTimeout,
+		}
+	}
+
+	if options.MinimumReadSize != 0 {
+		return readTimeouts{}
+	}
+
+	return readTimeouts{
+		total: time.Duration(options.InterCharacterTimeout) * time.Millisecond,
+	}
+}
+
+// read fills b using readUntil, which must wait no later than the deadline
+// it is given (or forever if it is zero) and return 0, nil if it passes.
+func (t readTimeouts) read(
+	b []byte,
+	readUntil func(b []byte, deadline time.Time) (int, error)) (int, error) {
+	var deadline time.Time
+	if t.total > 0 {
+		deadline = time.Now().Add(t.total)
+	}
+
+	n, err := readUntil(b, deadline)
+	for err == nil && n > 0 && n < len(b) && t.interByte > 0 {
+		next := time.Now().Add(t.interByte)
+		if !deadline.IsZero() && deadline.Before(next) {
+			next = deadline
+		}
+
+		if !next.After(time.Now()) {
+			break
+		}
+
+		var m int
+		m, err = readUntil(b[n:], next)
+		if m == 0 {
+			break
+		}
+
+		n += m
+	}
+
+	return n, err
+}
+
+// deadlineReader is implemented by net.Conn and *os.File.
+type deadlineReader interface {
+	io.Reader
+	SetReadDeadline(t time.Time) error
+}
+
+// readWithDeadline reads from r, giving up and returning 0, nil if nothing
+// arrives by the deadline. A zero deadline blocks indefinitely.
+func readWithDeadline(r deadlineReader, deadline time.Time, b []byte) (int, error) {
+	if err := r.SetReadDeadline(deadline); err != nil {
+		return 0, err
+	}
+
+	n, err := r.Read(b)
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		err = nil
+	}
+
+	return n, err
+}
@@ -0,0 +1,75 @@
+package serial
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReadTimeoutsFor(t *testing.T) {
+	testCases := []struct {
+		Options  OpenOptions
+		Expected readTimeouts
+	}{
+		{OpenOptions{}, readTimeouts{}},
+		{OpenOptions{MinimumReadSize: 1}, readTimeouts{}},
+		{OpenOptions{MinimumReadSize: 4, InterCharacterTimeout: 200}, readTimeouts{}},
+		{OpenOptions{InterCharacterTimeout: 200}, readTimeouts{total: 200 * time.Millisecond}},
+		{
+			OpenOptions{ReadTimeout: 1500 * time.Millisecond, InterByteTimeout: 3 * time.Millisecond},
+			readTimeouts{total: 1500 * time.Millisecond, interByte: 3 * time.Millisecond},
+		},
+	}
+
+	for _, testCase := range testCases {
+		if got := readTimeoutsFor(testCase.Options); got != testCase.Expected {
+			t.Errorf("%+v: expected %+v, got %+v", testCase.Options, testCase.Expected, got)
+		}
+	}
+}
+
+func TestInterByteTimeout(t *testing.T) {
+	port, err := OpenPort(OpenOptions{
+		PortName:         "loop://",
+		BaudRate:         9600,
+		DataBits:         8,
+		StopBits:         1,
+		ReadTimeout:      time.Second,
+		InterByteTimeout: 100 * time.Millisecond,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer port.Close()
+
+	// Bytes arriving less than InterByteTimeout apart are returned together.
+	go func() {
+		for _, s := range []string{"ab", "cd", "ef"} {
+			port.Write([]byte(s))
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+
+	buf := make([]byte, 16)
+	n, err := port.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(buf[:n]) != "abcdef" {
+		t.Errorf("expected %q, got %q", "abcdef", buf[:n])
+	}
+
+	// A full buffer is returned without waiting.
+	port.Write([]byte("0123456789"))
+	start := time.Now()
+	n, err = port.Read(buf[:4])
+	if n != 4 || err != nil {
+		t.Errorf("expected 4, nil; got %d, %v", n, err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("read of a full buffer took %v", elapsed)
+	}
+}
@@ -0,0 +1,271 @@
+// Copyright 2011 Aaron Jacobs. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package serial
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// URL schemes understood by ParseURL. Apart from "serial", the scheme is kept
+// in OpenOptions.PortName and selects the backend used by Open.
+const (
+	kSchemeSerial  = "serial"
+	kSchemeRFC2217 = "rfc2217"
+	kSchemeLoop    = "loop"
+	kSchemePTY     = "pty"
+)
+
+// The baud rate used when a URL does not give one.
+const kDefaultURLBaudRate = 9600
+
+// ParseURL parses a connection string describing a port and its settings,
+// so that a whole port configuration can be kept in a single value. The
+// following forms are accepted:
+//
+//	serial:///dev/ttyUSB0?baud=115200&format=8N1
+//	serial:COM3?baud=9600
+//	rfc2217://host:port?baud=9600
+//	loop://
+//	pty://
+//
+// rfc2217:// connects to a network serial server speaking the Telnet COM
+// port control protocol of RFC 2217. loop:// is an in-memory port on which
+// everything written can be read back, and pty:// creates a new
+// pseudo-terminal (currently Linux only) whose other end is given by
+// Port.PeerName; both are intended for tests.
+//
+// The query may contain the following parameters. The frame format and
+// timeouts use the same notation as ParseSettings:
+//
+//	baud                  baud rate, default 9600
+//	format                frame format such as 8N1, default 8N1
+//	flow                  "rtscts" or "none", default none
+//	timeout               InterCharacterTimeout, e.g. 100ms
+//	minread               MinimumReadSize
+//	read_timeout          ReadTimeout, e.g. 1s
+//	inter_byte            InterByteTimeout, e.g. 20ms
+//	rs485                 1 to enable RS485 mode
+//	rs485_rts_on_send     1 to set Rs485RtsHighDuringSend
+//	rs485_rts_after_send  1 to set Rs485RtsHighAfterSend
+//	rs485_rx_during_tx    1 to set Rs485RxDuringTx
+//	rs485_delay_before    Rs485DelayRtsBeforeSend
+//	rs485_delay_after     Rs485DelayRtsAfterSend
+//	dtr                   InitialDTR: "on", "off" or "default"
+//	rts                   InitialRTS: "on", "off" or "default"
+//	hupcl                 1 to set HangupOnClose
+//
+// For serial URLs the returned PortName is the device path; for the other
+// schemes it is the URL without its query, which Open recognizes.
+func ParseURL(rawURL string) (OpenOptions, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return OpenOptions{}, fmt.Errorf("%w: %v", ErrInvalidSettings, err)
+	}
+
+	query, err := url.ParseQuery(u.RawQuery)
+	if err != nil {
+		return OpenOptions{}, fmt.Errorf("%q: %w: %v", rawURL, ErrInvalidSettings, err)
+	}
+
+	options, err := parseURLQuery(query)
+	if err != nil {
+		return OpenOptions{}, fmt.Errorf("%q: %w", rawURL, err)
+	}
+
+	switch u.Scheme {
+	case kSchemeSerial:
+		name := u.Opaque
+		if name == "" {
+			name = u.Path
+		}
+
+		// Allow serial:///COM3 as well as serial:COM3 on Windows.
+		if runtime.GOOS == "windows" && strings.HasPrefix(name, "/") {
+			name = name[1:]
+		}
+
+		if name == "" {
+			return OpenOptions{}, fmt.Errorf("%q: no device path: %w", rawURL, ErrInvalidSettings)
+		}
+
+		options.PortName = name
+
+	case kSchemeRFC2217:
+		if u.Host == "" || u.Port() == "" {
+			return OpenOptions{}, fmt.Errorf("%q: expected rfc2217://host:port: %w", rawURL, ErrInvalidSettings)
+		}
+
+		options.PortName = kSchemeRFC2217 + "://" + u.Host
+
+	case kSchemeLoop, kSchemePTY:
+		options.PortName = u.Scheme + "://"
+
+	default:
+		return OpenOptions{}, fmt.Errorf("%q: unknown scheme %q: %w", rawURL, u.Scheme, ErrInvalidSettings)
+	}
+
+	return options, nil
+}
+
+// parseURLQuery converts the query parameters of a port URL into options.
+func parseURLQuery(query url.Values) (OpenOptions, error) {
+	baud := strconv.Itoa(kDefaultURLBaudRate)
+	settings := []string{}
+	// Options set directly rather than through ParseSettings.
+	var extra OpenOptions
+
+	for key, values := range query {
+		if len(values) != 1 {
+			return OpenOptions{}, fmt.Errorf("%s given %d times: %w", key, len(values), ErrInvalidSettings)
+		}
+
+		value := values[0]
+
+		var flag *bool
+		var delay *int
+
+		switch key {
+		case "baud":
+			baud = value
+		case "format":
+			settings = append(settings, value)
+		case "timeout":
+			settings = append(settings, "timeout="+value)
+		case "minread":
+			settings = append(settings, "minread="+value)
+		case "read_timeout":
+			settings = append(settings, "read_timeout="+value)
+		case "inter_byte":
+			settings = append(settings, "inter_byte="+value)
+
+		case "flow":
+			switch value {
+			case "rtscts":
+				settings = append(settings, "rtscts")
+			case "none":
+			default:
+				return OpenOptions{}, fmt.Errorf("unknown flow control %q: %w", value, ErrInvalidSettings)
+			}
+
+		case "rs485":
+			flag = &extra.Rs485Enable
+		case "rs485_rts_on_send":
+			flag = &extra.Rs485RtsHighDuringSend
+		case "rs485_rts_after_send":
+			flag = &extra.Rs485RtsHighAfterSend
+		case "rs485_rx_during_tx":
+			flag = &extra.Rs485RxDuringTx
+		case "rs485_delay_before":
+			delay = &extra.Rs485DelayRtsBeforeSend
+		case "rs485_delay_after":
+			delay = &extra.Rs485DelayRtsAfterSend
+		case "hupcl":
+			flag = &extra.HangupOnClose
+
+		case "dtr", "rts":
+			state := &extra.InitialDTR
+			if key == "rts" {
+				state = &extra.InitialRTS
+			}
+
+			if err := state.UnmarshalText([]byte(value)); err != nil {
+				return OpenOptions{}, fmt.Errorf("%s: %w", key, err)
+			}
+
+		default:
+			return OpenOptions{}, fmt.Errorf("unknown parameter %q: %w", key, ErrInvalidSettings)
+		}
+
+		if flag != nil {
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return OpenOptions{}, fmt.Errorf("%s=%s: %w", key, value, ErrInvalidSettings)
+			}
+
+			*flag = b
+		}
+
+		if delay != nil {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return OpenOptions{}, fmt.Errorf("%s=%s: %w", key, value, ErrInvalidSettings)
+			}
+
+			*delay = n
+		}
+	}
+
+	options, err := ParseSettings(strings.Join(append([]string{baud}, settings...), ","))
+	if err != nil {
+		return OpenOptions{}, err
+	}
+
+	options.Rs485Enable = extra.Rs485Enable
+	options.Rs485RtsHighDuringSend = extra.Rs485RtsHighDuringSend
+	options.Rs485RtsHighAfterSend = extra.Rs485RtsHighAfterSend
+	options.Rs485RxDuringTx = extra.Rs485RxDuringTx
+	options.Rs485DelayRtsBeforeSend = extra.Rs485DelayRtsBeforeSend
+	options.Rs485DelayRtsAfterSend = extra.Rs485DelayRtsAfterSend
+	options.InitialDTR = extra.InitialDTR
+	options.InitialRTS = extra.InitialRTS
+	options.HangupOnClose = extra.HangupOnClose
+
+	return options, nil
+}
+
+// OpenURL opens the port described by a URL; see ParseURL for the accepted
+// forms. To set further options such as Tap, use ParseURL and OpenPort.
+func OpenURL(rawURL string) (*Port, error) {
+	options, err := ParseURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return OpenPort(options)
+}
+
+// openBackend opens the port named by options.PortName, dispatching to the
+// backends for the URL schemes recognized by ParseURL and to the OS-specific
+// code for everything else. It also returns the name of the port's peer, for
+// pty:// ports.
+func openBackend(options OpenOptions) (io.ReadWriteCloser, string, error) {
+	scheme, rest, ok := strings.Cut(options.PortName, "://")
+	if !ok {
+		f, err := openInternal(options)
+		return f, "", err
+	}
+
+	switch scheme {
+	case kSchemeRFC2217:
+		f, err := openRFC2217(rest, options)
+		return f, "", err
+
+	case kSchemeLoop:
+		return newLoopPort(options), "", nil
+
+	case kSchemePTY:
+		return openPTY(options)
+
+	default:
+		// Perhaps it really is a file name; let the OS decide.
+		f, err := openInternal(options)
+		return f, "", err
+	}
+}
@@ -0,0 +1,246 @@
+package serial
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestParseURL(t *testing.T) {
+	testCases := []struct {
+		URL      string
+		Expected OpenOptions
+	}{
+		{
+			"serial:///dev/ttyUSB0?baud=115200&format=8N1",
+			OpenOptions{PortName: "/dev/ttyUSB0", BaudRate: 115200, DataBits: 8, StopBits: 1, MinimumReadSize: 1},
+		},
+		{
+			"serial:///dev/ttyS1?baud=9600&format=7E2&flow=rtscts&timeout=200ms&minread=0",
+			OpenOptions{
+				PortName:              "/dev/ttyS1",
+				BaudRate:              9600,
+				DataBits:              7,
+				StopBits:              2,
+				ParityMode:            PARITY_EVEN,
+				RTSCTSFlowControl:     true,
+				InterCharacterTimeout: 200,
+			},
+		},
+		{
+			"serial:///dev/ttyS0?baud=19200&rs485=1&rs485_rts_on_send=true&rs485_delay_after=5",
+			OpenOptions{
+				PortName:               "/dev/ttyS0",
+				BaudRate:               19200,
+				DataBits:               8,
+				StopBits:               1,
+				MinimumReadSize:        1,
+				Rs485Enable:            true,
+				Rs485RtsHighDuringSend: true,
+				Rs485DelayRtsAfterSend: 5,
+			},
+		},
+		{
+			"serial:///dev/ttyACM0?baud=115200&dtr=off&rts=on&hupcl=0",
+			OpenOptions{
+				PortName:        "/dev/ttyACM0",
+				BaudRate:        115200,
+				DataBits:        8,
+				StopBits:        1,
+				MinimumReadSize: 1,
+				InitialDTR:      LINE_OFF,
+				InitialRTS:      LINE_ON,
+			},
+		},
+		{
+			"serial:///dev/ttyUSB1?baud=115200&read_timeout=2s&inter_byte=10ms",
+			OpenOptions{
+				PortName:         "/dev/ttyUSB1",
+				BaudRate:         115200,
+				DataBits:         8,
+				StopBits:         1,
+				ReadTimeout:      2 * time.Second,
+				InterByteTimeout: 10 * time.Millisecond,
+			},
+		},
+		{
+			"rfc2217://localhost:2217?baud=57600",
+			OpenOptions{PortName: "rfc2217://localhost:2217", BaudRate: 57600, DataBits: 8, StopBits: 1, MinimumReadSize: 1},
+		},
+		{
+			"loop://",
+			OpenOptions{PortName: "loop://", BaudRate: 9600, DataBits: 8, StopBits: 1, MinimumReadSize: 1},
+		},
+		{
+			"pty://?format=8E1",
+			OpenOptions{PortName: "pty://", BaudRate: 9600, DataBits: 8, StopBits: 1, ParityMode: PARITY_EVEN, MinimumReadSize: 1},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.URL, func(t *testing.T) {
+			options, err := ParseURL(testCase.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if options != testCase.Expected {
+				t.Errorf("expected %+v, got %+v", testCase.Expected, options)
+			}
+		})
+	}
+}
+
+func TestParseURLErrors(t *testing.T) {
+	testCases := []struct {
+		URL string
+		Err error
+	}{
+		{"ftp://example.com", ErrInvalidSettings},
+		{"serial://?baud=9600", ErrInvalidSettings},
+		{"rfc2217://localhost", ErrInvalidSettings},
+		{"loop://?colour=blue", ErrInvalidSettings},
+		{"loop://?baud=1&baud=2", ErrInvalidSettings},
+		{"loop://?flow=xonxoff", ErrInvalidSettings},
+		{"loop://?rs485=maybe", ErrInvalidSettings},
+		{"loop://?format=9N1", ErrInvalidDataBits},
+		{"loop://?dtr=high", ErrInvalidLineState},
+		{"loop://?read_timeout=soon", ErrInvalidSettings},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.URL, func(t *testing.T) {
+			_, err := ParseURL(testCase.URL)
+			if !errors.Is(err, testCase.Err) {
+				t.Errorf("expected %v, got %v", testCase.Err, err)
+			}
+		})
+	}
+}
+
+func TestLoopPort(t *testing.T) {
+	port, err := OpenURL("loop://?timeout=100ms&minread=0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer port.Close()
+
+	if _, err := port.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 16)
+	n, err := port.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(buf[:n]) != "hello" {
+		t.Errorf("expected %q, got %q", "hello", buf[:n])
+	}
+
+	// Nothing left, so the read should time out.
+	start := time.Now()
+	n, err = port.Read(buf)
+	if n != 0 || err != nil {
+		t.Errorf("expected 0, nil; got %d, %v", n, err)
+	}
+
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("read returned after only %v", elapsed)
+	}
+}
+
+// fakeRFC2217Server accepts one connection, answers with the given bytes and
+// records everything the client sends.
+func fakeRFC2217Server(t *testing.T, reply []byte) (address string, received chan []byte) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	received = make(chan []byte, 1)
+	go func() {
+		defer l.Close()
+
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+
+		defer conn.Close()
+
+		conn.Write(reply)
+
+		b, _ := io.ReadAll(conn)
+		received <- b
+	}()
+
+	return l.Addr().String(), received
+}
+
+func TestRFC2217(t *testing.T) {
+	reply := []byte{
+		kIAC, kDO, kOptComPort, // Acknowledges our WILL; needs no answer.
+		kIAC, kDO, 24, // Terminal type, which we refuse.
+		'a', kIAC, kIAC, 'b', // Data with an escaped 0xFF.
+		kIAC, kSB, kOptComPort, kCPNotifyModemState + kCPServerOffset, 0x10 | 0x80, kIAC, kSE,
+		'c',
+	}
+
+	address, received := fakeRFC2217Server(t, reply)
+
+	port, err := OpenURL("rfc2217://" + address + "?baud=115200&format=7E1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []byte
+	buf := make([]byte, 16)
+	for len(got) < 4 {
+		n, err := port.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got = append(got, buf[:n]...)
+	}
+
+	if !bytes.Equal(got, []byte{'a', 0xFF, 'b', 'c'}) {
+		t.Errorf("unexpected data %x", got)
+	}
+
+	lines, _ := port.modemLines()
+	if lines != MODEM_CTS|MODEM_DCD {
+		t.Errorf("expected CTS|DCD, got %v", lines)
+	}
+
+	if _, err := port.Write([]byte{0x01, 0xFF}); err != nil {
+		t.Fatal(err)
+	}
+
+	port.Close()
+	sent := <-received
+
+	for _, expected := range [][]byte{
+		{kIAC, kWILL, kOptComPort},
+		{kIAC, kSB, kOptComPort, kCPSetBaudRate, 0x00, 0x01, 0xC2, 0x00, kIAC, kSE},
+		{kIAC, kSB, kOptComPort, kCPSetDataSize, 7, kIAC, kSE},
+		{kIAC, kSB, kOptComPort, kCPSetParity, 3, kIAC, kSE},
+		{kIAC, kSB, kOptComPort, kCPSetStopSize, 1, kIAC, kSE},
+		{kIAC, kWONT, 24},
+		{0x01, kIAC, kIAC},
+	} {
+		if !bytes.Contains(sent, expected) {
+			t.Errorf("expected client to send % x; sent % x", expected, sent)
+		}
+	}
+
+	if bytes.Count(sent, []byte{kIAC, kWILL, kOptComPort}) != 1 {
+		t.Errorf("client answered the server's acknowledgement: % x", sent)
+	}
+}
@@ -0,0 +1,189 @@
+// Copyright 2011 Aaron Jacobs. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xmodem
+
+import (
+	"io"
+	"time"
+)
+
+// How many times the receiver asks for CRCs before falling back to
+// checksums, for senders that only support the original protocol.
+const kCRCAttempts = 3
+
+// ReceiveOptions configures Receive.
+type ReceiveOptions struct {
+	// Ask for blocks with the original checksum rather than CRCs. Receive
+	// falls back to checksums anyway if the sender doesn't respond to a
+	// request for CRCs.
+	Checksum bool
+
+	// How long to wait for each block. Defaults to 10 seconds.
+	Timeout time.Duration
+
+	// How many times to ask again for a block that is corrupted or doesn't
+	// arrive in time. Defaults to 10.
+	Retries int
+
+	// If set, called with the number of bytes received, including any
+	// padding, after each block is accepted.
+	Progress func(received int64)
+}
+
+// Receive writes the data received from the sender on the port to w,
+// returning once the sender ends the transfer. If the transfer fails other
+// than by being canceled, the sender is told to cancel it.
+//
+// Both 128-byte and 1024-byte blocks are accepted. The padding of the last
+// block is written to w along with the data.
+func Receive(port io.ReadWriter, w io.Writer, options ReceiveOptions) error {
+	if options.Timeout <= 0 {
+		options.Timeout = kDefaultTimeout
+	}
+
+	if options.Retries <= 0 {
+		options.Retries = kDefaultRetries
+	}
+
+	r := &receiver{conn: newConn(port), options: options}
+	err := r.receive(w)
+	if err != nil && err != ErrCanceled {
+		r.cancel()
+	}
+
+	return err
+}
+
+type receiver struct {
+	*conn
+	options ReceiveOptions
+}
+
+func (r *receiver) receive(w io.Writer) error {
+	useCRC := !r.options.Checksum
+	request := byte(NAK)
+	if useCRC {
+		request = CRC
+	}
+
+	var received int64
+	started := false
+	expected := byte(1)
+	failures := 0
+	buf := make([]byte, 2+1024+2)
+
+	for {
+		if request != 0 {
+			if err := r.write(request); err != nil {
+				return err
+			}
+
+			request = 0
+		}
+
+		b, err := r.readByte(time.Now().Add(r.options.Timeout))
+		switch {
+		case err == ErrTimeout:
+			if failures++; failures > r.options.Retries {
+				return ErrTimeout
+			}
+
+			// Until the first block arrives, repeat the request to start.
+			request = NAK
+			if !started && useCRC {
+				if failures < kCRCAttempts {
+					request = CRC
+				} else {
+					useCRC = false
+				}
+			}
+
+			continue
+
+		case err != nil:
+			return err
+		}
+
+		size := 128
+		switch b {
+		case SOH:
+
+		case STX:
+			size = 1024
+
+		case EOT:
+			return r.write(ACK)
+
+		case CAN:
+			if r.canceled(r.options.Timeout) {
+				return ErrCanceled
+			}
+
+			continue
+
+		default:
+			// Line noise.
+			continue
+		}
+
+		// The block number, its complement, the data and the check value.
+		block := buf[:2+size+checkSize(useCRC)]
+		data := block[2 : 2+size]
+
+		switch err := r.readFull(block, time.Now().Add(r.options.Timeout)); {
+		case err != nil && err != ErrTimeout:
+			return err
+
+		case err == ErrTimeout || block[0] != ^block[1] || string(appendCheck(nil, data, useCRC)) != string(block[2+size:]):
+			if failures++; failures > r.options.Retries {
+				return ErrTooManyRetries
+			}
+
+			if err := r.purge(); err != nil {
+				return err
+			}
+
+			request = NAK
+			continue
+		}
+
+		number := block[0]
+		switch {
+		// A repeat of the last block, whose ACK was lost.
+		case started && number == expected-1:
+			request = ACK
+			continue
+
+		case number != expected:
+			return ErrBlockSequence
+		}
+
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+
+		if err := r.write(ACK); err != nil {
+			return err
+		}
+
+		started = true
+		failures = 0
+		expected++
+		received += int64(size)
+		if r.options.Progress != nil {
+			r.options.Progress(received)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright 2011 Aaron Jacobs. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xmodem
+
+import (
+	"io"
+	"time"
+)
+
+// SendOptions configures Send.
+type SendOptions struct {
+	// Send 1024-byte blocks if the receiver asks for CRCs, as in XMODEM-1K.
+	// The last block is sent as a 128-byte block if the data fits.
+	Use1K bool
+
+	// How long to wait for each response. Defaults to 10 seconds. Send waits
+	// up to Retries times as long for the receiver to start the transfer.
+	Timeout time.Duration
+
+	// How many times to resend a block that is rejected or not acknowledged.
+	// Defaults to 10.
+	Retries int
+
+	// If set, called with the number of bytes sent after each block is
+	// acknowledged.
+	Progress func(sent int64)
+}
+
+// Send sends everything read from r to the receiver on the port, returning
+// once the receiver has acknowledged the end of the transfer. If the
+// transfer fails other than by being canceled, the receiver is told to
+// cancel it.
+func Send(port io.ReadWriter, r io.Reader, options SendOptions) error {
+	if options.Timeout <= 0 {
+		options.Timeout = kDefaultTimeout
+	}
+
+	if options.Retries <= 0 {
+		options.Retries = kDefaultRetries
+	}
+
+	s := &sender{conn: newConn(port), options: options}
+	err := s.send(r)
+	if err != nil && err != ErrCanceled {
+		s.cancel()
+	}
+
+	return err
+}
+
+type sender struct {
+	*conn
+	options SendOptions
+}
+
+func (s *sender) send(r io.Reader) error {
+	useCRC, err := s.awaitStart()
+	if err != nil {
+		return err
+	}
+
+	size := 128
+	if s.options.Use1K && useCRC {
+		size = 1024
+	}
+
+	data := make([]byte, size)
+	block := make([]byte, 0, 3+size+2)
+	var sent int64
+
+	for number := byte(1); ; number++ {
+		n, err := io.ReadFull(r, data)
+		switch {
+		case err == io.EOF:
+			return s.transmit([]byte{EOT})
+
+		case err != nil && err != io.ErrUnexpectedEOF:
+			return err
+		}
+
+		for i := n; i < size; i++ {
+			data[i] = SUB
+		}
+
+		header, payload := byte(SOH), data
+		if size == 1024 {
+			if n <= 128 {
+				payload = data[:128]
+			} else {
+				header = STX
+			}
+		}
+
+		block = append(block[:0], header, number, ^number)
+		block = append(block, payload...)
+		block = appendCheck(block, payload, useCRC)
+
+		if err := s.transmit(block); err != nil {
+			return err
+		}
+
+		sent += int64(n)
+		if s.options.Progress != nil {
+			s.options.Progress(sent)
+		}
+
+		if n < size {
+			return s.transmit([]byte{EOT})
+		}
+	}
+}
+
+// awaitStart waits for the receiver to ask for the first block, returning
+// whether it wants CRCs.
+func (s *sender) awaitStart() (bool, error) {
+	deadline := time.Now().Add(time.Duration(s.options.Retries) * s.options.Timeout)
+	for {
+		b, err := s.readByte(deadline)
+		if err != nil {
+			return false, err
+		}
+
+		switch b {
+		case CRC:
+			return true, nil
+
+		case NAK:
+			return false, nil
+
+		case CAN:
+			if s.canceled(s.options.Timeout) {
+				return false, ErrCanceled
+			}
+		}
+	}
+}
+
+// transmit sends a block, or the EOT that ends the transfer, until it is
+// acknowledged.
+func (s *sender) transmit(block []byte) error {
+	for attempt := 0; attempt <= s.options.Retries; attempt++ {
+		if err := s.write(block...); err != nil {
+			return err
+		}
+
+		ok, err := s.awaitResponse()
+		if ok || err != nil && err != ErrTimeout {
+			return err
+		}
+	}
+
+	return ErrTooManyRetries
+}
+
+// awaitResponse waits for an ACK or NAK, returning true for an ACK.
+// Anything else, such as a receiver's repeated requests to start, is
+// ignored.
+func (s *sender) awaitResponse() (bool, error) {
+	deadline := time.Now().Add(s.options.Timeout)
+	for {
+		b, err := s.readByte(deadline)
+		if err != nil {
+			return false, err
+		}
+
+		switch b {
+		case ACK:
+			return true, nil
+
+		case NAK:
+			return false, nil
+
+		case CAN:
+			if s.canceled(s.options.Timeout) {
+				return false, ErrCanceled
+			}
+		}
+	}
+}
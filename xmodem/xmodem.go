@@ -0,0 +1,167 @@
+// Copyright 2011 Aaron Jacobs. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package xmodem sends and receives files with the XMODEM protocol, as
+// spoken by bootloaders and terminal programs, over a port returned by
+// serial.Open. It supports the original protocol with its one-byte
+// checksum, XMODEM-CRC and XMODEM-1K, as described here:
+//
+//	http://wiki.synchro.net/ref:xmodem
+//
+// The receiver starts a transfer by asking for blocks with checksums or
+// CRCs, and the sender uses whichever it asks for. Data is sent in blocks of
+// 128 bytes, or 1024 bytes with XMODEM-1K, the last padded with SUB (0x1a)
+// bytes; the receiver cannot tell the padding from the data.
+//
+// The port must be opened with serial.OpenOptions.ReadTimeout set, so that
+// timeouts can be enforced. The older InterCharacterTimeout will not do: on
+// Linux a read that times out with it returns io.EOF, which ends the
+// transfer with an error.
+package xmodem
+
+import (
+	"errors"
+	"io"
+	"time"
+
+	"github.com/jacobsa/go-serial/crc"
+)
+
+// The control characters of XMODEM.
+const (
+	SOH = 0x01 // Begins a 128-byte block
+	STX = 0x02 // Begins a 1024-byte block
+	EOT = 0x04
+	ACK = 0x06
+	NAK = 0x15
+	CAN = 0x18
+	SUB = 0x1a // Pads the last block
+	CRC = 'C'  // Asks for blocks with CRCs
+)
+
+var (
+	// ErrCanceled is returned when the other end cancels the transfer.
+	ErrCanceled = errors.New("transfer canceled by peer")
+
+	// ErrTimeout is returned when the other end stops responding.
+	ErrTimeout = errors.New("timed out waiting for peer")
+
+	// ErrTooManyRetries is returned when a block is rejected or received
+	// corrupted more times than allowed.
+	ErrTooManyRetries = errors.New("too many retries")
+
+	// ErrBlockSequence is returned by Receive when a block arrives out of
+	// sequence, which the protocol cannot recover from.
+	ErrBlockSequence = errors.New("block out of sequence")
+)
+
+// The defaults for the Timeout and Retries options.
+const (
+	kDefaultTimeout = 10 * time.Second
+	kDefaultRetries = 10
+)
+
+var crcTable = crc.MakeTable(crc.CRC16_XMODEM)
+
+// conn reads single bytes from the port with deadlines.
+type conn struct {
+	port io.ReadWriter
+	buf  []byte
+	in   []byte
+}
+
+func newConn(port io.ReadWriter) *conn {
+	return &conn{port: port, buf: make([]byte, 1100)}
+}
+
+// readByte returns the next byte received, or ErrTimeout if none arrives by
+// the deadline.
+func (c *conn) readByte(deadline time.Time) (byte, error) {
+	for len(c.in) == 0 {
+		if !time.Now().Before(deadline) {
+			return 0, ErrTimeout
+		}
+
+		n, err := c.port.Read(c.buf)
+		c.in = c.buf[:n]
+		if n == 0 && err != nil {
+			return 0, err
+		}
+	}
+
+	b := c.in[0]
+	c.in = c.in[1:]
+	return b, nil
+}
+
+// readFull fills b with the bytes received, or returns ErrTimeout if they
+// don't all arrive by the deadline.
+func (c *conn) readFull(b []byte, deadline time.Time) error {
+	for i := range b {
+		var err error
+		if b[i], err = c.readByte(deadline); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// purge discards anything received until a read returns no data, such as
+// the rest of a corrupted block.
+func (c *conn) purge() error {
+	c.in = nil
+	for {
+		n, err := c.port.Read(c.buf)
+		if n == 0 {
+			return err
+		}
+	}
+}
+
+// canceled reports whether a CAN just received is followed by another,
+// which cancels the transfer. A single CAN is taken to be line noise.
+func (c *conn) canceled(timeout time.Duration) bool {
+	b, err := c.readByte(time.Now().Add(timeout))
+	return err == nil && b == CAN
+}
+
+func (c *conn) write(b ...byte) error {
+	_, err := c.port.Write(b)
+	return err
+}
+
+// cancel cancels the transfer at the other end.
+func (c *conn) cancel() {
+	c.write(CAN, CAN)
+}
+
+// checkSize returns the size of a block's check value.
+func checkSize(useCRC bool) int {
+	if useCRC {
+		return 2
+	}
+
+	return 1
+}
+
+// appendCheck appends the check value of data to b.
+func appendCheck(b []byte, data []byte, useCRC bool) []byte {
+	if useCRC {
+		sum := crcTable.Checksum(data)
+		return append(b, byte(sum>>8), byte(sum))
+	}
+
+	return append(b, crc.Sum8(data))
+}
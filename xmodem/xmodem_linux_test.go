@@ -0,0 +1,283 @@
+package xmodem
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/jacobsa/go-serial/internal/ptytest"
+)
+
+// expect reads len(expected) bytes from the port and checks that they are
+// as expected.
+func expect(t *testing.T, port io.ReadWriter, expected []byte) {
+	t.Helper()
+
+	c := newConn(port)
+	got := make([]byte, len(expected))
+	if err := c.readFull(got, time.Now().Add(5*time.Second)); err != nil {
+		t.Fatalf("expected % x: %v", expected, err)
+	}
+
+	if !bytes.Equal(got, expected) {
+		t.Fatalf("expected % x, got % x", expected, got)
+	}
+}
+
+// makeBlock returns a 128-byte block.
+func makeBlock(number byte, data string, useCRC bool) []byte {
+	payload := bytes.Repeat([]byte{SUB}, 128)
+	copy(payload, data)
+	block := append([]byte{SOH, number, ^number}, payload...)
+	return appendCheck(block, payload, useCRC)
+}
+
+// padded returns the data as received, padded to a whole number of blocks.
+func padded(data []byte, use1K bool) []byte {
+	var size int
+	for size < len(data) {
+		if use1K && len(data)-size > 128 {
+			size += 1024
+		} else {
+			size += 128
+		}
+	}
+
+	return append(append([]byte(nil), data...), bytes.Repeat([]byte{SUB}, size-len(data))...)
+}
+
+func TestTransfer(t *testing.T) {
+	testCases := []struct {
+		Name     string
+		Checksum bool
+		Use1K    bool
+		Sizes    []int
+	}{
+		{"checksum", true, false, []int{0, 1, 128, 300}},
+		{"CRC", false, false, []int{1, 128, 129, 40000}},
+		{"1K", false, true, []int{1, 1024, 1025, 1100, 3000}},
+		{"1K with checksums", true, true, []int{1100}},
+	}
+
+	for _, testCase := range testCases {
+		for _, size := range testCase.Sizes {
+			data := make([]byte, size)
+			rand.New(rand.NewSource(int64(size))).Read(data)
+
+			sendPort, receivePort := ptytest.OpenPair(t, 8)
+			var sent, received []int64
+			sendErr := make(chan error)
+			go func() {
+				sendErr <- Send(sendPort, bytes.NewReader(data), SendOptions{
+					Use1K:    testCase.Use1K,
+					Timeout:  time.Second,
+					Progress: func(n int64) { sent = append(sent, n) },
+				})
+			}()
+
+			var out bytes.Buffer
+			err := Receive(receivePort, &out, ReceiveOptions{
+				Checksum: testCase.Checksum,
+				Timeout:  time.Second,
+				Progress: func(n int64) { received = append(received, n) },
+			})
+
+			if err != nil {
+				t.Errorf("%s, %d bytes: Receive: %v", testCase.Name, size, err)
+			}
+
+			if err := <-sendErr; err != nil {
+				t.Errorf("%s, %d bytes: Send: %v", testCase.Name, size, err)
+			}
+
+			expected := padded(data, testCase.Use1K && !testCase.Checksum)
+			if !bytes.Equal(out.Bytes(), expected) {
+				t.Errorf("%s, %d bytes: received %d bytes, expected %d", testCase.Name, size, out.Len(), len(expected))
+			}
+
+			if size > 0 && (len(sent) == 0 || sent[len(sent)-1] != int64(size)) {
+				t.Errorf("%s, %d bytes: unexpected send progress %v", testCase.Name, size, sent)
+			}
+
+			if len(sent) != len(received) || size > 0 && received[len(received)-1] != int64(len(expected)) {
+				t.Errorf("%s, %d bytes: unexpected receive progress %v", testCase.Name, size, received)
+			}
+		}
+	}
+}
+
+func TestReceiveFallsBackToChecksum(t *testing.T) {
+	port, sender := ptytest.OpenPair(t, 8)
+	errs := make(chan error)
+	var out bytes.Buffer
+	go func() {
+		errs <- Receive(port, &out, ReceiveOptions{Timeout: 50 * time.Millisecond})
+	}()
+
+	// An old sender ignores the requests for CRCs.
+	expect(t, sender, []byte{CRC, CRC, CRC, NAK})
+	sender.Write(makeBlock(1, "old", false))
+	expect(t, sender, []byte{ACK})
+	sender.Write([]byte{EOT})
+	expect(t, sender, []byte{ACK})
+
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.HasPrefix(out.Bytes(), []byte("old\x1a")) {
+		t.Errorf("unexpected data: %q", out.Bytes())
+	}
+}
+
+func TestReceiveRetries(t *testing.T) {
+	port, sender := ptytest.OpenPair(t, 8)
+	errs := make(chan error)
+	var out bytes.Buffer
+	go func() {
+		errs <- Receive(port, &out, ReceiveOptions{Timeout: 200 * time.Millisecond})
+	}()
+
+	expect(t, sender, []byte{CRC})
+
+	// A corrupted block is asked for again, and a repeated block is
+	// acknowledged but ignored.
+	bad := makeBlock(1, "one", true)
+	bad[10] ^= 0xff
+	sender.Write(bad)
+	expect(t, sender, []byte{NAK})
+
+	for _, block := range [][]byte{makeBlock(1, "one", true), makeBlock(1, "one", true), makeBlock(2, "two", true)} {
+		sender.Write(block)
+		expect(t, sender, []byte{ACK})
+	}
+
+	sender.Write([]byte{EOT})
+	expect(t, sender, []byte{ACK})
+
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := append(makeBlock(1, "one", true)[3:131], makeBlock(2, "two", true)[3:131]...); !bytes.Equal(out.Bytes(), expected) {
+		t.Errorf("unexpected data: %q", out.Bytes())
+	}
+}
+
+func TestReceiveOutOfSequence(t *testing.T) {
+	port, sender := ptytest.OpenPair(t, 8)
+	errs := make(chan error)
+	go func() {
+		errs <- Receive(port, io.Discard, ReceiveOptions{Timeout: 200 * time.Millisecond})
+	}()
+
+	expect(t, sender, []byte{CRC})
+	sender.Write(makeBlock(2, "two", true))
+
+	if err := <-errs; err != ErrBlockSequence {
+		t.Errorf("expected ErrBlockSequence, got %v", err)
+	}
+
+	expect(t, sender, []byte{CAN, CAN})
+}
+
+func TestSendRetries(t *testing.T) {
+	port, receiver := ptytest.OpenPair(t, 8)
+	errs := make(chan error)
+	go func() {
+		errs <- Send(port, bytes.NewReader([]byte("one")), SendOptions{Timeout: 200 * time.Millisecond})
+	}()
+
+	// A rejected block, an unacknowledged block and an EOT rejected as some
+	// receivers do are all sent again.
+	receiver.Write([]byte{CRC})
+	expect(t, receiver, makeBlock(1, "one", true))
+	receiver.Write([]byte{NAK})
+	expect(t, receiver, makeBlock(1, "one", true))
+	expect(t, receiver, makeBlock(1, "one", true))
+	receiver.Write([]byte{ACK})
+	expect(t, receiver, []byte{EOT})
+	receiver.Write([]byte{NAK})
+	expect(t, receiver, []byte{EOT})
+	receiver.Write([]byte{ACK})
+
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	// By the receiver, during the transfer.
+	port, receiver := ptytest.OpenPair(t, 8)
+	errs := make(chan error)
+	go func() {
+		errs <- Send(port, bytes.NewReader(make([]byte, 1000)), SendOptions{Timeout: 200 * time.Millisecond})
+	}()
+
+	receiver.Write([]byte{NAK})
+	expect(t, receiver, makeBlock(1, "", false)[:3])
+	receiver.Write([]byte{CAN, CAN})
+
+	if err := <-errs; err != ErrCanceled {
+		t.Errorf("Send: expected ErrCanceled, got %v", err)
+	}
+
+	// By the sender, before the transfer starts.
+	port, sender := ptytest.OpenPair(t, 8)
+	go func() {
+		errs <- Receive(port, io.Discard, ReceiveOptions{Timeout: 200 * time.Millisecond})
+	}()
+
+	expect(t, sender, []byte{CRC})
+	sender.Write([]byte{CAN, CAN})
+
+	if err := <-errs; err != ErrCanceled {
+		t.Errorf("Receive: expected ErrCanceled, got %v", err)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	port, peer := ptytest.OpenPair(t, 8)
+
+	// Nobody asks for the data; the receiver is told to cancel in case it
+	// turns up.
+	err := Send(port, bytes.NewReader([]byte("data")), SendOptions{Timeout: 20 * time.Millisecond, Retries: 2})
+	if err != ErrTimeout {
+		t.Errorf("Send: expected ErrTimeout, got %v", err)
+	}
+
+	expect(t, peer, []byte{CAN, CAN})
+
+	// Nobody sends any data.
+	err = Receive(port, io.Discard, ReceiveOptions{Timeout: 20 * time.Millisecond, Retries: 4})
+	if err != ErrTimeout {
+		t.Errorf("Receive: expected ErrTimeout, got %v", err)
+	}
+
+	expect(t, peer, []byte{CRC, CRC, CRC, NAK, NAK, CAN, CAN})
+}
+
+func TestWriteError(t *testing.T) {
+	port, sender := ptytest.OpenPair(t, 8)
+	errs := make(chan error)
+	failure := errors.New("disk full")
+	go func() {
+		errs <- Receive(port, failingWriter{failure}, ReceiveOptions{Timeout: 200 * time.Millisecond})
+	}()
+
+	expect(t, sender, []byte{CRC})
+	sender.Write(makeBlock(1, "one", true))
+
+	if err := <-errs; err != failure {
+		t.Errorf("expected %v, got %v", failure, err)
+	}
+
+	expect(t, sender, []byte{CAN, CAN})
+}
+
+type failingWriter struct{ err error }
+
+func (w failingWriter) Write(b []byte) (int, error) { return 0, w.err }
@@ -0,0 +1,19 @@
+package xmodem
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/jacobsa/go-serial/crc"
+)
+
+func TestChecks(t *testing.T) {
+	data := []byte("123456789")
+	if got := appendCheck(nil, data, true); !bytes.Equal(got, []byte{0x31, 0xc3}) {
+		t.Errorf("unexpected CRC % x", got)
+	}
+
+	if got := appendCheck(nil, data, false); !bytes.Equal(got, []byte{crc.Sum8(data)}) || got[0] != 0xdd {
+		t.Errorf("unexpected checksum % x", got)
+	}
+}